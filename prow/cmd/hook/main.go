@@ -149,6 +149,9 @@ func main() {
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting GitHub client.")
 	}
+	if err := plugins.ResolveMaintainersIDs(githubClient, pluginAgent.Config().RepoMilestone); err != nil {
+		logrus.WithError(err).Warn("Error resolving the milestone maintainers team IDs.")
+	}
 	gitClient, err := o.github.GitClientFactory("", &o.config.InRepoConfigCacheDirBase, o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting Git client.")
@@ -231,6 +234,7 @@ func main() {
 		OwnersClient:              ownersClient,
 		BugzillaClient:            bugzillaClient,
 		JiraClient:                jiraClient,
+		TeamMembershipResolver:    plugins.NewTeamMembershipResolver(),
 	}
 
 	promMetrics := githubeventserver.NewMetrics()
@@ -239,7 +243,13 @@ func main() {
 
 	// Expose prometheus metrics
 	metrics.ExposeMetrics("hook", configAgent.Config().PushGateway, o.instrumentationOptions.MetricsPort)
-	pprof.Instrument(o.instrumentationOptions)
+	// Serve the debugging information of the plugins from /plugin-debug/<name>
+	// on the pprof port, as it includes the names of private repos.
+	debugHandlers := map[string]http.Handler{}
+	for name, handler := range plugins.DebugHandlers() {
+		debugHandlers["/plugin-debug/"+name] = handler
+	}
+	pprof.InstrumentWithHandlers(o.instrumentationOptions, debugHandlers)
 
 	server := &hook.Server{
 		ClientAgent:    clientAgent,
@@ -200,7 +200,7 @@ type TeamClient interface {
 	UpdateTeamMembershipBySlug(org, teamSlug, user string, maintainer bool) (*TeamMembership, error)
 	RemoveTeamMembership(org string, id int, user string) error
 	RemoveTeamMembershipBySlug(org, teamSlug, user string) error
-	ListTeamMembersBySlug(org string, id int, role string) ([]TeamMember, error)
+	ListTeamMembers(org string, id int, role string) ([]TeamMember, error)
 	ListTeamMembersBySlug(org, teamSlug, role string) ([]TeamMember, error)
 	ListTeamRepos(org string, id int) ([]Repo, error)
 	ListTeamReposBySlug(org, teamSlug string) ([]Repo, error)
@@ -244,6 +244,8 @@ type MilestoneClient interface {
 	ClearMilestone(org, repo string, num int) error
 	SetMilestone(org, repo string, issueNum, milestoneNum int) error
 	ListMilestones(org, repo string) ([]Milestone, error)
+	ListMilestonesByState(org, repo, state string) ([]Milestone, error)
+	CreateMilestone(org, repo, title string) (int, error)
 }
 
 // RerunClient interface for job rerun access check related API actions
@@ -283,6 +285,7 @@ type Client interface {
 	SetMax404Retries(int)
 
 	WithFields(fields logrus.Fields) Client
+	WithContext(ctx context.Context) Client
 	ForPlugin(plugin string) Client
 	ForSubcomponent(subcomponent string) Client
 	Used() bool
@@ -299,6 +302,9 @@ type client struct {
 	identifier string
 	gqlc       gqlClient
 	used       bool
+	// ctx is used by the requests of the methods which don't take a context,
+	// if non-nil.
+	ctx context.Context
 	*delegate
 }
 
@@ -351,6 +357,7 @@ func (c *client) forKeyValue(key, value string) Client {
 	newClient := &client{
 		identifier: value,
 		logger:     c.logger.WithField(key, value),
+		ctx:        c.ctx,
 		delegate:   c.delegate,
 	}
 	newClient.gqlc = c.gqlc.forUserAgent(newClient.userAgent())
@@ -371,10 +378,33 @@ func (c *client) WithFields(fields logrus.Fields) Client {
 		logger:     c.logger.WithFields(fields),
 		identifier: c.identifier,
 		gqlc:       c.gqlc,
+		ctx:        c.ctx,
 		delegate:   c.delegate,
 	}
 }
 
+// WithContext clones the client, keeping the underlying delegate the same but
+// making the requests of the methods which don't take a context with ctx, so
+// that they are aborted once ctx is done.
+func (c *client) WithContext(ctx context.Context) Client {
+	return &client{
+		logger:     c.logger,
+		identifier: c.identifier,
+		gqlc:       c.gqlc,
+		ctx:        ctx,
+		delegate:   c.delegate,
+	}
+}
+
+// context returns the context of the client, or the background context if it
+// has none.
+func (c *client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 var (
 	teamRe = regexp.MustCompile(`^(.*)/(.*)$`)
 )
@@ -964,6 +994,45 @@ func NewNotFound() error {
 	}
 }
 
+// NewServerError returns an error like the one returned when GitHub fails a
+// request with a 502 status code.
+func NewServerError() error {
+	return requestError{
+		StatusCode:  http.StatusBadGateway,
+		ErrorString: "status code 502",
+	}
+}
+
+// IsServerError returns whether err is due to GitHub failing a request with a
+// 5xx status code, which is usually transient.
+func IsServerError(err error) bool {
+	var requestErr requestError
+	if !errors.As(err, &requestErr) {
+		return false
+	}
+	return requestErr.StatusCode >= http.StatusInternalServerError
+}
+
+// NewForbidden returns an error like the one returned when GitHub forbids a
+// request, e.g. as the token lacks a permission.
+func NewForbidden() error {
+	return requestError{
+		StatusCode:  http.StatusForbidden,
+		ErrorString: "the GitHub API request returns a 403 error: 403 Forbidden",
+	}
+}
+
+// IsForbidden returns whether err is due to GitHub forbidding a request for
+// another reason than rate limiting, usually a missing permission of the
+// token or GitHub App installation.
+func IsForbidden(err error) bool {
+	var requestErr requestError
+	if !errors.As(err, &requestErr) {
+		return false
+	}
+	return requestErr.StatusCode == http.StatusForbidden
+}
+
 func IsNotFound(err error) bool {
 	if err == nil {
 		return false
@@ -986,10 +1055,29 @@ func IsNotFound(err error) bool {
 	return false
 }
 
+// RateLimitError is returned when GitHub rate limits a request for longer
+// than the client is willing to wait before retrying it.
+type RateLimitError struct {
+	// Wait is how long GitHub asked to wait before retrying the request.
+	Wait    time.Duration
+	message string
+}
+
+func (e RateLimitError) Error() string {
+	return e.message
+}
+
+// IsRateLimited returns whether err is due to GitHub rate limiting a request,
+// either the primary rate limit or a secondary (abuse) rate limit.
+func IsRateLimited(err error) bool {
+	var rateLimitErr RateLimitError
+	return errors.As(err, &rateLimitErr)
+}
+
 // Make a request with retries. If ret is not nil, unmarshal the response body
 // into it. Returns an error if the exit code is not one of the provided codes.
 func (c *client) request(r *request, ret interface{}) (int, error) {
-	return c.requestWithContext(context.Background(), r, ret)
+	return c.requestWithContext(c.context(), r, ret)
 }
 
 func (c *client) requestWithContext(ctx context.Context, r *request, ret interface{}) (int, error) {
@@ -1008,7 +1096,7 @@ func (c *client) requestWithContext(ctx context.Context, r *request, ret interfa
 // requestRaw makes a request with retries and returns the response body.
 // Returns an error if the exit code is not one of the provided codes.
 func (c *client) requestRaw(r *request) (int, []byte, error) {
-	return c.requestRawWithContext(context.Background(), r)
+	return c.requestRawWithContext(c.context(), r)
 }
 
 func (c *client) requestRawWithContext(ctx context.Context, r *request) (int, []byte, error) {
@@ -1046,7 +1134,7 @@ func (c *client) requestRawWithContext(ctx context.Context, r *request) (int, []
 // ratelimit exceeded, and retries 404s a couple times.
 // This function closes the response body iff it also returns an error.
 func (c *client) requestRetry(method, path, accept, org string, body interface{}) (*http.Response, error) {
-	return c.requestRetryWithContext(context.Background(), method, path, accept, org, body)
+	return c.requestRetryWithContext(c.context(), method, path, accept, org, body)
 }
 
 func (c *client) requestRetryWithContext(ctx context.Context, method, path, accept, org string, body interface{}) (*http.Response, error) {
@@ -1083,7 +1171,7 @@ func (c *client) requestRetryWithContext(ctx context.Context, method, path, acce
 							c.logger.WithField("backoff", sleepTime.String()).WithField("path", path).Debug("Retrying after token budget reset")
 							c.time.Sleep(sleepTime)
 						} else {
-							err = fmt.Errorf("sleep time for token reset exceeds max sleep time (%v > %v)", sleepTime, c.maxSleepTime)
+							err = RateLimitError{Wait: sleepTime, message: fmt.Sprintf("sleep time for token reset exceeds max sleep time (%v > %v)", sleepTime, c.maxSleepTime)}
 							resp.Body.Close()
 							break
 						}
@@ -1104,7 +1192,7 @@ func (c *client) requestRetryWithContext(ctx context.Context, method, path, acce
 							c.logger.WithField("backoff", sleepTime.String()).WithField("path", path).Debug("Retrying after abuse ratelimit reset")
 							c.time.Sleep(sleepTime)
 						} else {
-							err = fmt.Errorf("sleep time for abuse rate limit exceeds max sleep time (%v > %v)", sleepTime, c.maxSleepTime)
+							err = RateLimitError{Wait: sleepTime, message: fmt.Sprintf("sleep time for abuse rate limit exceeds max sleep time (%v > %v)", sleepTime, c.maxSleepTime)}
 							resp.Body.Close()
 							break
 						}
@@ -1129,10 +1217,16 @@ func (c *client) requestRetryWithContext(ctx context.Context, method, path, acce
 						got = append(got, strings.TrimSpace(authorizedScope))
 					}
 					if acceptedScopes != "" && !want.HasAny(got...) {
-						err = fmt.Errorf("the account is using %s oauth scopes, please make sure you are using at least one of the following oauth scopes: %s", authorizedScopes, acceptedScopes)
+						err = requestError{
+							StatusCode:  http.StatusForbidden,
+							ErrorString: fmt.Sprintf("the account is using %s oauth scopes, please make sure you are using at least one of the following oauth scopes: %s", authorizedScopes, acceptedScopes),
+						}
 					} else {
 						body, _ := io.ReadAll(resp.Body)
-						err = fmt.Errorf("the GitHub API request returns a 403 error: %s", string(body))
+						err = requestError{
+							StatusCode:  http.StatusForbidden,
+							ErrorString: fmt.Sprintf("the GitHub API request returns a 403 error: %s", string(body)),
+						}
 					}
 					resp.Body.Close()
 					break
@@ -1325,7 +1419,7 @@ func (c *client) BotUser() (*UserData, error) {
 }
 
 func (c *client) BotUserChecker() (func(candidate string) bool, error) {
-	return c.BotUserCheckerWithContext(context.Background())
+	return c.BotUserCheckerWithContext(c.context())
 }
 
 func (c *client) BotUserCheckerWithContext(ctx context.Context) (func(candidate string) bool, error) {
@@ -1809,7 +1903,7 @@ func (c *client) RemoveOrgMembership(org, user string) error {
 //
 // See https://developer.github.com/v3/issues/comments/#create-a-comment
 func (c *client) CreateComment(org, repo string, number int, comment string) error {
-	return c.CreateCommentWithContext(context.Background(), org, repo, number, comment)
+	return c.CreateCommentWithContext(c.context(), org, repo, number, comment)
 }
 
 func (c *client) CreateCommentWithContext(ctx context.Context, org, repo string, number int, comment string) error {
@@ -1831,7 +1925,7 @@ func (c *client) CreateCommentWithContext(ctx context.Context, org, repo string,
 //
 // See https://developer.github.com/v3/issues/comments/#delete-a-comment
 func (c *client) DeleteComment(org, repo string, id int) error {
-	return c.DeleteCommentWithContext(context.Background(), org, repo, id)
+	return c.DeleteCommentWithContext(c.context(), org, repo, id)
 }
 
 func (c *client) DeleteCommentWithContext(ctx context.Context, org, repo string, id int) error {
@@ -1849,7 +1943,7 @@ func (c *client) DeleteCommentWithContext(ctx context.Context, org, repo string,
 //
 // See https://developer.github.com/v3/issues/comments/#edit-a-comment
 func (c *client) EditComment(org, repo string, id int, comment string) error {
-	return c.EditCommentWithContext(context.Background(), org, repo, id, comment)
+	return c.EditCommentWithContext(c.context(), org, repo, id, comment)
 }
 
 func (c *client) EditCommentWithContext(ctx context.Context, org, repo string, id int, comment string) error {
@@ -1944,7 +2038,7 @@ func (c *client) CreateIssueReaction(org, repo string, id int, reaction string)
 // DeleteStaleComments iterates over comments on an issue/PR, deleting those which the 'isStale'
 // function identifies as stale. If 'comments' is nil, the comments will be fetched from GitHub.
 func (c *client) DeleteStaleComments(org, repo string, number int, comments []IssueComment, isStale func(IssueComment) bool) error {
-	return c.DeleteStaleCommentsWithContext(context.Background(), org, repo, number, comments, isStale)
+	return c.DeleteStaleCommentsWithContext(c.context(), org, repo, number, comments, isStale)
 }
 
 func (c *client) DeleteStaleCommentsWithContext(ctx context.Context, org, repo string, number int, comments []IssueComment, isStale func(IssueComment) bool) error {
@@ -1972,7 +2066,7 @@ func (c *client) DeleteStaleCommentsWithContext(ctx context.Context, org, repo s
 //
 // Returns an error any call to GitHub or object marshalling fails.
 func (c *client) readPaginatedResults(path, accept, org string, newObj func() interface{}, accumulate func(interface{})) error {
-	return c.readPaginatedResultsWithContext(context.Background(), path, accept, org, newObj, accumulate)
+	return c.readPaginatedResultsWithContext(c.context(), path, accept, org, newObj, accumulate)
 }
 
 func (c *client) readPaginatedResultsWithContext(ctx context.Context, path, accept, org string, newObj func() interface{}, accumulate func(interface{})) error {
@@ -1984,7 +2078,7 @@ func (c *client) readPaginatedResultsWithContext(ctx context.Context, path, acce
 
 // readPaginatedResultsWithValues is an override that allows control over the query string.
 func (c *client) readPaginatedResultsWithValues(path string, values url.Values, accept, org string, newObj func() interface{}, accumulate func(interface{})) error {
-	return c.readPaginatedResultsWithValuesWithContext(context.Background(), path, values, accept, org, newObj, accumulate)
+	return c.readPaginatedResultsWithValuesWithContext(c.context(), path, values, accept, org, newObj, accumulate)
 }
 
 func (c *client) readPaginatedResultsWithValuesWithContext(ctx context.Context, path string, values url.Values, accept, org string, newObj func() interface{}, accumulate func(interface{})) error {
@@ -2047,7 +2141,7 @@ func (c *client) readPaginatedResultsWithValuesWithContext(ctx context.Context,
 //
 // See https://developer.github.com/v3/issues/comments/#list-comments-on-an-issue
 func (c *client) ListIssueComments(org, repo string, number int) ([]IssueComment, error) {
-	return c.ListIssueCommentsWithContext(context.Background(), org, repo, number)
+	return c.ListIssueCommentsWithContext(c.context(), org, repo, number)
 }
 
 func (c *client) ListIssueCommentsWithContext(ctx context.Context, org, repo string, number int) ([]IssueComment, error) {
@@ -2487,7 +2581,7 @@ func (c *client) ListReviews(org, repo string, number int) ([]Review, error) {
 //
 // See https://docs.github.com/en/rest/reference/commits#create-a-commit-status
 func (c *client) CreateStatus(org, repo, SHA string, s Status) error {
-	return c.CreateStatusWithContext(context.Background(), org, repo, SHA, s)
+	return c.CreateStatusWithContext(c.context(), org, repo, SHA, s)
 }
 
 func (c *client) CreateStatusWithContext(ctx context.Context, org, repo, SHA string, s Status) error {
@@ -2893,7 +2987,7 @@ func (c *client) GetIssueLabels(org, repo string, number int) ([]Label, error) {
 //
 // See https://developer.github.com/v3/issues/labels/#add-labels-to-an-issue
 func (c *client) AddLabel(org, repo string, number int, label string) error {
-	return c.AddLabelWithContext(context.Background(), org, repo, number, label)
+	return c.AddLabelWithContext(c.context(), org, repo, number, label)
 }
 
 func (c *client) AddLabelWithContext(ctx context.Context, org, repo string, number int, label string) error {
@@ -2904,7 +2998,7 @@ func (c *client) AddLabelWithContext(ctx context.Context, org, repo string, numb
 //
 // See https://developer.github.com/v3/issues/labels/#add-labels-to-an-issue
 func (c *client) AddLabels(org, repo string, number int, labels ...string) error {
-	return c.AddLabelsWithContext(context.Background(), org, repo, number, labels...)
+	return c.AddLabelsWithContext(c.context(), org, repo, number, labels...)
 }
 
 func (c *client) AddLabelsWithContext(ctx context.Context, org, repo string, number int, labels ...string) error {
@@ -2929,7 +3023,7 @@ type githubError struct {
 //
 // See https://developer.github.com/v3/issues/labels/#remove-a-label-from-an-issue
 func (c *client) RemoveLabel(org, repo string, number int, label string) error {
-	return c.RemoveLabelWithContext(context.Background(), org, repo, number, label)
+	return c.RemoveLabelWithContext(c.context(), org, repo, number, label)
 }
 
 func (c *client) RemoveLabelWithContext(ctx context.Context, org, repo string, number int, label string) error {
@@ -3863,16 +3957,16 @@ func (c *client) RemoveTeamMembershipBySlug(org, teamSlug, user string) error {
 	return err
 }
 
-// ListTeamMembersBySlug gets a list of team members for the given team id
+// ListTeamMembers gets a list of team members for the given team id
 //
 // Role options are "all", "maintainer" and "member"
 //
 // https://developer.github.com/v3/teams/members/#list-team-members
 // Deprecated: please use ListTeamMembersBySlug
-func (c *client) ListTeamMembersBySlug(org string, id int, role string) ([]TeamMember, error) {
-	c.logger.WithField("methodName", "ListTeamMembersBySlug").
+func (c *client) ListTeamMembers(org string, id int, role string) ([]TeamMember, error) {
+	c.logger.WithField("methodName", "ListTeamMembers").
 		Warn("method is deprecated, please use ListTeamMembersBySlug")
-	durationLogger := c.log("ListTeamMembersBySlug", id, role)
+	durationLogger := c.log("ListTeamMembers", id, role)
 	defer durationLogger()
 
 	if c.fake {
@@ -4584,6 +4678,31 @@ func (c *client) SetMilestone(org, repo string, issueNum, milestoneNum int) erro
 	return err
 }
 
+// CreateMilestone creates an open milestone with the given title in a repo and
+// returns its number.
+//
+// See https://docs.github.com/en/rest/issues/milestones#create-a-milestone
+func (c *client) CreateMilestone(org, repo, title string) (int, error) {
+	durationLogger := c.log("CreateMilestone", org, repo, title)
+	defer durationLogger()
+
+	data := struct {
+		Title string `json:"title"`
+	}{Title: title}
+	var milestone Milestone
+	_, err := c.request(&request{
+		method:      http.MethodPost,
+		path:        fmt.Sprintf("/repos/%s/%s/milestones", org, repo),
+		org:         org,
+		requestBody: &data,
+		exitCodes:   []int{201},
+	}, &milestone)
+	if err != nil {
+		return 0, err
+	}
+	return milestone.Number, nil
+}
+
 // ListMilestones list all milestones in a repo
 //
 // See https://developer.github.com/v3/issues/milestones/#list-milestones-for-a-repository/
@@ -4613,6 +4732,41 @@ func (c *client) ListMilestones(org, repo string) ([]Milestone, error) {
 	return milestones, nil
 }
 
+// ListMilestonesByState lists the milestones in a repo with the given state,
+// which is one of MilestoneStateOpen, MilestoneStateClosed or MilestoneStateAll.
+//
+// See https://developer.github.com/v3/issues/milestones/#list-milestones-for-a-repository/
+func (c *client) ListMilestonesByState(org, repo, state string) ([]Milestone, error) {
+	durationLogger := c.log("ListMilestonesByState", org, repo, state)
+	defer durationLogger()
+
+	if c.fake {
+		return nil, nil
+	}
+	path := fmt.Sprintf("/repos/%s/%s/milestones", org, repo)
+	values := url.Values{
+		"per_page": []string{"100"},
+		"state":    []string{state},
+	}
+	var milestones []Milestone
+	err := c.readPaginatedResultsWithValues(
+		path,
+		values,
+		acceptNone,
+		org,
+		func() interface{} {
+			return &[]Milestone{}
+		},
+		func(obj interface{}) {
+			milestones = append(milestones, *(obj.(*[]Milestone))...)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
+
 // ListPRCommits lists the commits in a pull request.
 //
 // GitHub API docs: https://developer.github.com/v3/pulls/#list-commits-on-a-pull-request
@@ -4887,7 +5041,7 @@ func (c *client) TeamHasMember(org string, teamID int, memberLogin string) (bool
 	durationLogger := c.log("TeamHasMember", teamID, memberLogin)
 	defer durationLogger()
 
-	projectMaintainers, err := c.ListTeamMembersBySlug(org, teamID, RoleAll)
+	projectMaintainers, err := c.ListTeamMembers(org, teamID, RoleAll)
 	if err != nil {
 		return false, err
 	}
@@ -5088,7 +5242,7 @@ func (c *client) getAppInstallationToken(installationId int64) (*AppInstallation
 
 // GetApp gets the current app. Will not work with a Personal Access Token.
 func (c *client) GetApp() (*App, error) {
-	return c.GetAppWithContext(context.Background())
+	return c.GetAppWithContext(c.context())
 }
 
 func (c *client) GetAppWithContext(ctx context.Context) (*App, error) {
@@ -131,6 +131,111 @@ func TestAbuseRateLimit(t *testing.T) {
 	}
 }
 
+func TestRateLimitExceedsMaxSleepTime(t *testing.T) {
+	tc := &testTime{now: time.Now()}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	c.time = tc
+	_, err := c.requestRetry(http.MethodGet, "/", "", "", nil)
+	if !IsRateLimited(err) {
+		t.Errorf("Expected a rate limit error, got %v", err)
+	}
+	if tc.slept != 0 {
+		t.Errorf("Expected not to sleep, slept for %v", tc.slept)
+	}
+}
+
+func TestIsRateLimited(t *testing.T) {
+	if IsRateLimited(errors.New("some error")) {
+		t.Error("Expected an arbitrary error not to be a rate limit error")
+	}
+	if !IsRateLimited(fmt.Errorf("wrapped: %w", RateLimitError{Wait: time.Hour})) {
+		t.Error("Expected a wrapped rate limit error to be a rate limit error")
+	}
+}
+
+func TestIsServerError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "server error",
+			err:      requestError{StatusCode: http.StatusServiceUnavailable},
+			expected: true,
+		},
+		{
+			name:     "wrapped server error",
+			err:      fmt.Errorf("wrapped: %w", NewServerError()),
+			expected: true,
+		},
+		{
+			name: "client error",
+			err:  requestError{StatusCode: http.StatusUnprocessableEntity},
+		},
+		{
+			name: "not found",
+			err:  NewNotFound(),
+		},
+		{
+			name: "arbitrary error",
+			err:  errors.New("some error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := IsServerError(tc.err); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsForbidden(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "forbidden",
+			err:      NewForbidden(),
+			expected: true,
+		},
+		{
+			name:     "wrapped forbidden",
+			err:      fmt.Errorf("wrapped: %w", NewForbidden()),
+			expected: true,
+		},
+		{
+			name: "not found",
+			err:  NewNotFound(),
+		},
+		{
+			name: "rate limited",
+			err:  RateLimitError{},
+		},
+		{
+			name: "arbitrary error",
+			err:  errors.New("some error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := IsForbidden(tc.err); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
 func TestRetry404(t *testing.T) {
 	tc := &testTime{now: time.Now()}
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -190,6 +295,8 @@ func TestIncorrectOAuthScopes(t *testing.T) {
 			t.Error("Expected an error from a request with incorrect OAuth scopes, but succeeded!?")
 		} else if diff := cmp.Diff(err.Error(), tc.expectedErr); diff != "" {
 			t.Errorf("Unexpected error message: %s", diff)
+		} else if !IsForbidden(err) {
+			t.Errorf("Expected the error to be recognized as forbidden: %v", err)
 		}
 	}
 }
@@ -286,6 +393,31 @@ func TestCreateComment(t *testing.T) {
 	}
 }
 
+func TestWithContext(t *testing.T) {
+	received := make(chan struct{})
+	release := make(chan struct{})
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := getClient(ts.URL).WithContext(ctx)
+	go func() {
+		<-received
+		cancel()
+	}()
+	err := c.SetMilestone("k8s", "kuber", 5, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the request to be canceled during the call, got: %v", err)
+	}
+}
+
 func TestCreateCommentCensored(t *testing.T) {
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -2009,7 +2141,7 @@ func TestListTeamMembers(t *testing.T) {
 	ts := simpleTestServer(t, "/teams/1/members", []TeamMember{{Login: "foo"}}, http.StatusOK)
 	defer ts.Close()
 	c := getClient(ts.URL)
-	teamMembers, err := c.ListTeamMembersBySlug("orgName", 1, RoleAll)
+	teamMembers, err := c.ListTeamMembers("orgName", 1, RoleAll)
 	if err != nil {
 		t.Errorf("Didn't expect error: %v", err)
 	} else if len(teamMembers) != 1 {
@@ -2640,6 +2772,60 @@ func TestListMilestones(t *testing.T) {
 	}
 }
 
+func TestCreateMilestone(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Bad method: %s", r.Method)
+		}
+		if r.URL.Path != "/repos/k8s/kuber/milestones" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("Could not read request body: %v", err)
+		}
+		var data struct {
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal(b, &data); err != nil {
+			t.Errorf("Could not unmarshal request: %v", err)
+		} else if data.Title != "v1.21" {
+			t.Errorf("Expected the title v1.21, got %q", data.Title)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Milestone{Title: "v1.21", Number: 7, State: MilestoneStateOpen})
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	number, err := c.CreateMilestone("k8s", "kuber", "v1.21")
+	if err != nil {
+		t.Fatalf("Didn't expect error: %v", err)
+	}
+	if number != 7 {
+		t.Errorf("Expected milestone number 7, got %d", number)
+	}
+}
+
+func TestListMilestonesByState(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("Bad method: %s", r.Method)
+		}
+		if r.URL.Path != "/repos/k8s/kuber/milestones" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		if state := r.URL.Query().Get("state"); state != MilestoneStateOpen {
+			t.Errorf("Expected open milestones to be requested, got state %q", state)
+		}
+		fmt.Fprint(w, "[]")
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	if _, err := c.ListMilestonesByState("k8s", "kuber", MilestoneStateOpen); err != nil {
+		t.Errorf("Didn't expect error: %v", err)
+	}
+}
+
 func TestListPRCommits(t *testing.T) {
 	ts := simpleTestServer(t, "/repos/theorg/therepo/pulls/3/commits", []RepositoryCommit{
 		{SHA: "sha"},
@@ -3555,8 +3741,9 @@ func TestThrottlerRespectsContexts(t *testing.T) {
 
 	clientMethods := getCallForAllClientMethodsThroughReflection(c,
 		// Skip all method whose first arg is not of type context.Context (self is the actual first arg)
+		// and WithContext, which doesn't make a request
 		func(m reflect.Method) bool {
-			return m.Func.Type().NumIn() < 2 || m.Func.Type().In(1).String() != "context.Context"
+			return m.Func.Type().NumIn() < 2 || m.Func.Type().In(1).String() != "context.Context" || m.Name == "WithContext"
 		},
 		// Insert our custom ctx for any arg of type context.Context
 		func(typeName string) interface{} {
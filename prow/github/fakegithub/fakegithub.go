@@ -382,6 +382,18 @@ func (f *FakeClient) GetPullRequest(owner, repo string, number int) (*github.Pul
 	return val, nil
 }
 
+// GetPullRequests returns the pull requests, ordered by number.
+func (f *FakeClient) GetPullRequests(org, repo string) ([]github.PullRequest, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	var prs []github.PullRequest
+	for _, pr := range f.PullRequests {
+		prs = append(prs, *pr)
+	}
+	sort.Slice(prs, func(i, j int) bool { return prs[i].Number < prs[j].Number })
+	return prs, nil
+}
+
 // EditPullRequest edits the pull request.
 func (f *FakeClient) EditPullRequest(org, repo string, number int, issue *github.PullRequest) (*github.PullRequest, error) {
 	f.lock.Lock()
@@ -710,8 +722,8 @@ func (f *FakeClient) ListTeams(org string) ([]github.Team, error) {
 	}, nil
 }
 
-// ListTeamMembersBySlug return a fake team with a single "sig-lead" GitHub teammember
-func (f *FakeClient) ListTeamMembersBySlug(org string, teamID int, role string) ([]github.TeamMember, error) {
+// ListTeamMembers return a fake team with a single "sig-lead" GitHub teammember
+func (f *FakeClient) ListTeamMembers(org string, teamID int, role string) ([]github.TeamMember, error) {
 	f.lock.RLock()
 	defer f.lock.RUnlock()
 	if role != github.RoleAll {
@@ -746,11 +758,23 @@ func (f *FakeClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github
 	return members, nil
 }
 
+// TeamBySlugHasMember checks if a user belongs to a team. If no teams are
+// configured for the org, the fake teams returned by ListTeamMembersBySlug are used.
 func (f *FakeClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
 	f.lock.RLock()
-	defer f.lock.RUnlock()
-	if f.Teams[org] != nil {
-		return f.Teams[org][teamSlug].Members.Has(memberLogin), nil
+	teams := f.Teams[org]
+	f.lock.RUnlock()
+	if teams != nil {
+		return teams[teamSlug].Members.Has(memberLogin), nil
+	}
+	members, err := f.ListTeamMembersBySlug(org, teamSlug, github.RoleAll)
+	if err != nil {
+		return false, err
+	}
+	for _, member := range members {
+		if github.NormLogin(member.Login) == github.NormLogin(memberLogin) {
+			return true, nil
+		}
 	}
 	return false, nil
 }
@@ -807,6 +831,36 @@ func (f *FakeClient) ListMilestones(org, repo string) ([]github.Milestone, error
 	return milestones, nil
 }
 
+// CreateMilestone adds a milestone to MilestoneMap, numbered after the
+// existing ones.
+func (f *FakeClient) CreateMilestone(org, repo, title string) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, exists := f.MilestoneMap[title]; exists {
+		return 0, fmt.Errorf("milestone %q already exists", title)
+	}
+	if f.MilestoneMap == nil {
+		f.MilestoneMap = map[string]int{}
+	}
+	number := 1
+	for _, n := range f.MilestoneMap {
+		if n >= number {
+			number = n + 1
+		}
+	}
+	f.MilestoneMap[title] = number
+	return number, nil
+}
+
+// ListMilestonesByState lists milestones. The milestones of the fake are
+// all open, so none are listed for the closed state.
+func (f *FakeClient) ListMilestonesByState(org, repo, state string) ([]github.Milestone, error) {
+	if state == github.MilestoneStateClosed {
+		return []github.Milestone{}, nil
+	}
+	return f.ListMilestones(org, repo)
+}
+
 // ListPRCommits lists commits for a given PR.
 func (f *FakeClient) ListPRCommits(org, repo string, prNumber int) ([]github.RepositoryCommit, error) {
 	f.lock.RLock()
@@ -1018,7 +1072,7 @@ func (f *FakeClient) MoveProjectCard(org string, projectCardID int, newColumnID
 
 // TeamHasMember checks if a user belongs to a team
 func (f *FakeClient) TeamHasMember(org string, teamID int, memberLogin string) (bool, error) {
-	teamMembers, _ := f.ListTeamMembersBySlug(org, teamID, github.RoleAll)
+	teamMembers, _ := f.ListTeamMembers(org, teamID, github.RoleAll)
 	for _, member := range teamMembers {
 		if member.Login == memberLogin {
 			return true, nil
@@ -1236,10 +1236,18 @@ type GenericCommentEvent struct {
 	GUID         string
 }
 
+const (
+	MilestoneStateOpen   = "open"
+	MilestoneStateClosed = "closed"
+	MilestoneStateAll    = "all"
+)
+
 // Milestone is a milestone defined on a github repository
 type Milestone struct {
-	Title  string `json:"title"`
-	Number int    `json:"number"`
+	Title  string     `json:"title"`
+	Number int        `json:"number"`
+	State  string     `json:"state"`
+	DueOn  *time.Time `json:"due_on,omitempty"`
 }
 
 // RepositoryCommit represents a commit in a repo.
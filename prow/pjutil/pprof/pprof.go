@@ -35,7 +35,13 @@ import (
 
 // Instrument implements the profiling options a user has asked for on the command line.
 func Instrument(opts flagutil.InstrumentationOptions) {
-	Serve(opts.PProfPort)
+	InstrumentWithHandlers(opts, nil)
+}
+
+// InstrumentWithHandlers is like Instrument, but also serves the given debug
+// handlers, keyed by pattern, next to the pprof endpoints.
+func InstrumentWithHandlers(opts flagutil.InstrumentationOptions, handlers map[string]http.Handler) {
+	ServeWithHandlers(opts.PProfPort, handlers)
 	if opts.ProfileMemory {
 		WriteMemoryProfiles(opts.MemoryProfileInterval)
 	}
@@ -46,7 +52,16 @@ func Instrument(opts flagutil.InstrumentationOptions) {
 // the simple case where the default mux is to be used, but with a custom mux to ensure we don't serve
 // this data from an exposed port.
 func Serve(port int) {
+	ServeWithHandlers(port, nil)
+}
+
+// ServeWithHandlers is like Serve, but also serves the given debug handlers,
+// keyed by pattern, which must not be exposed either.
+func ServeWithHandlers(port int, handlers map[string]http.Handler) {
 	pprofMux := http.NewServeMux()
+	for pattern, handler := range handlers {
+		pprofMux.Handle(pattern, handler)
+	}
 	pprofMux.HandleFunc("/debug/pprof/", pprof.Index)
 	pprofMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
 	pprofMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
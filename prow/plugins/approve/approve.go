@@ -33,6 +33,7 @@ import (
 	"k8s.io/test-infra/prow/pluginhelp"
 	"k8s.io/test-infra/prow/plugins"
 	"k8s.io/test-infra/prow/plugins/approve/approvers"
+	"k8s.io/test-infra/prow/plugins/milestone"
 	"k8s.io/test-infra/prow/repoowners"
 )
 
@@ -54,6 +55,8 @@ var (
 
 	// handleFunc is used to allow mocking out the behavior of 'handle' while testing.
 	handleFunc = handle
+	// applyDefaultMilestoneFunc is used to allow mocking out the milestone plugin while testing.
+	applyDefaultMilestoneFunc = milestone.ApplyDefaultMilestone
 )
 
 type githubClient interface {
@@ -148,14 +151,43 @@ func helpProvider(config *plugins.Configuration, enabledRepos []config.OrgRepo)
 }
 
 func handleGenericCommentEvent(pc plugins.Agent, ce github.GenericCommentEvent) error {
-	return handleGenericComment(
+	if err := handleGenericComment(
 		pc.Logger,
 		pc.GitHubClient,
 		pc.OwnersClient,
 		pc.Config.GitHubOptions,
 		pc.PluginConfig,
 		&ce,
-	)
+	); err != nil {
+		return err
+	}
+	return applyDefaultMilestone(pc, &ce)
+}
+
+// applyDefaultMilestone sets the current milestone of the repo on a PR
+// approved with /approve when the repo is configured with apply_on_approve.
+// The milestone plugin decides whether the approver may set it.
+func applyDefaultMilestone(pc plugins.Agent, ce *github.GenericCommentEvent) error {
+	if ce.Action != github.GenericCommentActionCreated || !ce.IsPR || ce.IssueState == "closed" {
+		return nil
+	}
+	org, repo := ce.Repo.Owner.Login, ce.Repo.Name
+	if !plugins.MilestoneFor(pc.PluginConfig.RepoMilestone, org, repo).AppliesOnApprove() || !isApproval(ce.Body) {
+		return nil
+	}
+	_, err := applyDefaultMilestoneFunc(pc, org, repo, ce.Number, ce.User.Login)
+	return err
+}
+
+// isApproval returns whether body contains an /approve command which doesn't
+// cancel the approval.
+func isApproval(body string) bool {
+	for _, match := range commandRegex.FindAllStringSubmatch(body, -1) {
+		if strings.ToUpper(match[1]) == approveCommand && strings.ToLower(strings.TrimSpace(match[2])) != cancelArgument {
+			return true
+		}
+	}
+	return false
 }
 
 func handleGenericComment(log *logrus.Entry, ghc githubClient, oc ownersClient, githubConfig config.GitHubOptions, config *plugins.Configuration, ce *github.GenericCommentEvent) error {
@@ -33,6 +33,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/yaml"
 
+	utilpointer "k8s.io/utils/pointer"
+
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/github/fakegithub"
@@ -40,6 +42,7 @@ import (
 	"k8s.io/test-infra/prow/pkg/layeredsets"
 	"k8s.io/test-infra/prow/plugins"
 	"k8s.io/test-infra/prow/plugins/approve/approvers"
+	"k8s.io/test-infra/prow/plugins/milestone"
 	"k8s.io/test-infra/prow/plugins/ownersconfig"
 	"k8s.io/test-infra/prow/repoowners"
 )
@@ -1593,6 +1596,79 @@ func stateToLower(s github.ReviewState) github.ReviewState {
 	return github.ReviewState(strings.ToLower(string(s)))
 }
 
+func TestApplyDefaultMilestone(t *testing.T) {
+	testcases := []struct {
+		name           string
+		body           string
+		issueState     string
+		applyOnApprove bool
+		expectApply    bool
+	}{
+		{
+			name:           "/approve applies the current milestone",
+			body:           "/approve",
+			applyOnApprove: true,
+			expectApply:    true,
+		},
+		{
+			name:           "/approve without apply_on_approve doesn't apply the milestone",
+			body:           "/approve",
+			applyOnApprove: false,
+		},
+		{
+			name:           "/approve cancel doesn't apply the milestone",
+			body:           "/approve cancel",
+			applyOnApprove: true,
+		},
+		{
+			name:           "/lgtm doesn't apply the milestone",
+			body:           "/lgtm",
+			applyOnApprove: true,
+		},
+		{
+			name:           "/approve on a closed PR doesn't apply the milestone",
+			body:           "/approve",
+			issueState:     "closed",
+			applyOnApprove: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var applied []string
+			applyDefaultMilestoneFunc = func(pc plugins.Agent, org, repo string, number int, login string) (bool, error) {
+				applied = append(applied, fmt.Sprintf("%s/%s#%d:%s", org, repo, number, login))
+				return true, nil
+			}
+			defer func() {
+				applyDefaultMilestoneFunc = milestone.ApplyDefaultMilestone
+			}()
+			pc := plugins.Agent{PluginConfig: &plugins.Configuration{RepoMilestone: map[string]plugins.Milestone{
+				"org/repo": {MaintainersTeam: "leads", CurrentMilestone: "v1.0", ApplyOnApprove: utilpointer.BoolPtr(tc.applyOnApprove)},
+			}}}
+			ce := &github.GenericCommentEvent{
+				Action:     github.GenericCommentActionCreated,
+				IsPR:       true,
+				IssueState: tc.issueState,
+				Body:       tc.body,
+				Number:     1,
+				Repo:       github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:       github.User{Login: "sig-lead"},
+			}
+			if err := applyDefaultMilestone(pc, ce); err != nil {
+				t.Fatalf("Unexpected error: %v.", err)
+			}
+			var expected []string
+			if tc.expectApply {
+				expected = []string{"org/repo#1:sig-lead"}
+			}
+			if diff := cmp.Diff(expected, applied); diff != "" {
+				t.Errorf("Unexpected applied milestones (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestHandleReview(t *testing.T) {
 	tests := []struct {
 		name                string
@@ -44,6 +44,7 @@ import (
 
 const (
 	defaultBlunderbussReviewerCount = 2
+	defaultMaintainersFriendlyName  = "org administrators"
 )
 
 // Configuration is the top-level serialization target for plugin Configuration.
@@ -79,7 +80,16 @@ type Configuration struct {
 	Lgtm                 []Lgtm                       `json:"lgtm,omitempty"`
 	Jira                 *Jira                        `json:"jira,omitempty"`
 	MilestoneApplier     map[string]BranchToMilestone `json:"milestone_applier,omitempty"`
-	RepoMilestone        map[string]Milestone         `json:"repo_milestone,omitempty"`
+	// RepoMilestone maps org/repo to the milestone config of the repo. The
+	// entry for org/* configures all the repos of the org, and the entry for
+	// "" is the default config. The config of each repo is merged field by
+	// field over the config of its org, itself merged over the default.
+	RepoMilestone map[string]Milestone `json:"repo_milestone,omitempty"`
+	// MilestoneTeamRefs maps orgs to symbolic names of teams to the teams
+	// they refer to, so that many repo_milestone entries can share one
+	// definition through maintainers_team_ref. Names defined for the "" org
+	// are available to all orgs.
+	MilestoneTeamRefs    map[string]map[string]string `json:"milestone_team_refs,omitempty"`
 	Project              ProjectConfig                `json:"project_config,omitempty"`
 	ProjectManager       ProjectManager               `json:"project_manager,omitempty"`
 	RequireMatchingLabel []RequireMatchingLabel       `json:"require_matching_label,omitempty"`
@@ -477,10 +487,587 @@ type Milestone struct {
 	// You can curl the following endpoint in order to determine the github ID of your team
 	// responsible for maintaining the milestones:
 	// curl -H "Authorization: token <token>" https://api.github.com/orgs/<org-name>/teams
-	// Deprecated: use MaintainersTeam instead
-	MaintainersID           int    `json:"maintainers_id,omitempty"`
-	MaintainersTeam         string `json:"maintainers_team,omitempty"`
+	// Deprecated: use MaintainersTeam instead. If both are set, the team with
+	// this ID is used when the MaintainersTeam isn't found, e.g. because the
+	// team was renamed.
+	MaintainersID int `json:"maintainers_id,omitempty"`
+	// MaintainersTeam is the slug of the github team for the milestone
+	// maintainers. A nested team may also be referred to by its path from its
+	// top-level ancestor, e.g. `parent-team/child-team`.
+	MaintainersTeam string `json:"maintainers_team,omitempty"`
+	// MaintainersTeamRef is the symbolic name of the team for the milestone
+	// maintainers, as defined in milestone_team_refs for the org of the repo.
+	// It is resolved into MaintainersTeam during config load.
+	MaintainersTeamRef string `json:"maintainers_team_ref,omitempty"`
+	// MaintainersTeams are the slugs of additional github teams whose members
+	// are milestone maintainers, alongside the members of MaintainersTeam.
+	MaintainersTeams []string `json:"maintainers_teams,omitempty"`
+	// MaintainersFriendlyName is how the milestone maintainers are referred
+	// to in the comments of the milestone plugins, e.g. `SIG Chairs/TLs`.
+	// Defaults to 'org administrators'.
 	MaintainersFriendlyName string `json:"maintainers_friendly_name,omitempty"`
+	// PathMaintainers authorize the members of other teams than the
+	// milestone maintainers teams to set the milestone of the PRs which
+	// change the files they own, e.g. in a monorepo. The milestone of a PR
+	// changing files matching none of them is set by the milestone
+	// maintainers teams.
+	PathMaintainers []PathMaintainers `json:"path_maintainers,omitempty"`
+	// MilestoneMaintainers authorize the members of other teams than the
+	// milestone maintainers teams to set the milestones they own, e.g. the
+	// backlog milestone, instead of the milestone maintainers teams. The
+	// milestones owned by none of them are set by the milestone maintainers
+	// teams, which also clear the milestone unless a ClearTeam is set.
+	MilestoneMaintainers []MilestoneMaintainers `json:"milestone_maintainers,omitempty"`
+	// RequireTeamMaintainerRole, if true, makes only the maintainers of the
+	// milestone maintainers teams milestone maintainers, and not their
+	// regular members.
+	RequireTeamMaintainerRole *bool `json:"require_team_maintainer_role,omitempty"`
+	// AllowOrgMembers, if true and no milestone maintainers team is configured,
+	// makes every member of the org a milestone maintainer.
+	AllowOrgMembers *bool `json:"allow_org_members,omitempty"`
+	// AllowedBots are the logins of the automation accounts, e.g. release
+	// bots, which are authorized like milestone maintainers without being
+	// members of the milestone maintainers teams.
+	AllowedBots []string `json:"allowed_bots,omitempty"`
+	// ClearTeam is the slug of the github team whose members may clear the
+	// milestone. If neither ClearTeam nor ClearTeamID is set, the milestone
+	// maintainers may clear the milestone.
+	ClearTeam string `json:"clear_team,omitempty"`
+	// ClearTeamID is the ID of the github team whose members may clear the
+	// milestone. It is only used if ClearTeam is unset.
+	ClearTeamID int `json:"clear_team_id,omitempty"`
+	// BulkTeam is the slug of the github team whose members may set a
+	// milestone on all the open PRs against a branch at once with
+	// `/milestone <version> --all-open-prs base:<branch>`. The command is
+	// disabled if unset.
+	BulkTeam string `json:"bulk_team,omitempty"`
+	// RequireClearConfirmation, if true, makes the milestone plugin only
+	// clear the milestone with `/milestone clear confirm`, so that it is not
+	// cleared by accident, and ask for the confirmation otherwise.
+	RequireClearConfirmation *bool `json:"require_clear_confirmation,omitempty"`
+	// WarnOnClosedIssue, if true, makes the milestone plugin warn when the
+	// milestone of a closed issue or PR is set, which is usually a mistake.
+	// The milestone is set regardless.
+	WarnOnClosedIssue *bool `json:"warn_on_closed_issue,omitempty"`
+	// SilentOnInvalid, if true, makes the milestone plugin only log the
+	// commands proposing a milestone which doesn't exist in the repo instead
+	// of commenting with the valid milestones.
+	SilentOnInvalid *bool `json:"silent_on_invalid,omitempty"`
+	// ConfirmMilestone, if true, makes the milestone plugin leave a comment
+	// confirming the milestone once it has been set. The comment names the
+	// user who changed the milestone and the previous milestone. Clearing the
+	// milestone is always confirmed.
+	ConfirmMilestone *bool `json:"confirm_milestone,omitempty"`
+	// AllowClosedMilestones, if true, allows closed milestones to be set in
+	// addition to open ones. Defaults to false.
+	AllowClosedMilestones *bool `json:"allow_closed_milestones,omitempty"`
+	// MatchVersionPrefix, if true, makes the milestone plugin match a
+	// milestone which no title matches to the milestone titled with or
+	// without a leading `v`, e.g. `1.20` to `v1.20` and `v1.20` to `1.20`.
+	MatchVersionPrefix *bool `json:"match_version_prefix,omitempty"`
+	// MilestonesCacheTTL is how long the list of milestones in a repo is
+	// cached for before it is fetched from GitHub again.
+	// Defaults to '60s'.
+	MilestonesCacheTTL         string        `json:"milestones_cache_ttl,omitempty"`
+	MilestonesCacheTTLDuration time.Duration `json:"-"`
+	// TeamMembershipCacheTTL is how long the members of the milestone
+	// maintainers teams are cached for before they are fetched from GitHub
+	// again. The cache is shared by the milestone and milestonestatus plugins.
+	// Defaults to '5m'.
+	TeamMembershipCacheTTL         string        `json:"team_membership_cache_ttl,omitempty"`
+	TeamMembershipCacheTTLDuration time.Duration `json:"-"`
+	// DuplicateCommandWindow is how long after a /milestone command an
+	// identical command on the same issue or PR, e.g. from a double-submitted
+	// comment, is ignored by the milestone plugin.
+	// Defaults to '10s'.
+	DuplicateCommandWindow         string        `json:"duplicate_command_window,omitempty"`
+	DuplicateCommandWindowDuration time.Duration `json:"-"`
+	// ChangeCooldown, if set, is how long after the milestone plugin changed
+	// the milestone of an issue or PR it refuses to change it again, e.g.
+	// '10m', so that maintainers disagreeing on the milestone don't flood
+	// the issue or PR with changes and notifications.
+	ChangeCooldown         string        `json:"change_cooldown,omitempty"`
+	ChangeCooldownDuration time.Duration `json:"-"`
+	// MaxAttempts is how many times the milestone plugin attempts to list
+	// the milestones of a repo or to set the milestone of an issue or PR while
+	// GitHub fails the request with a 5xx status code, on top of the retries
+	// of the GitHub client, backing off exponentially between the attempts
+	// for at most 30s. Defaults to 3.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// PropagateToLinkedIssues, if true, makes the milestone plugin also set
+	// the milestone on the issues a PR closes, e.g. with "Fixes #123", when
+	// the milestone is set on the PR.
+	PropagateToLinkedIssues *bool `json:"propagate_to_linked_issues,omitempty"`
+	// BranchMilestones maps the base branches of PRs to milestones, so that
+	// `/milestone` without an argument or `/milestone auto` sets the milestone
+	// of the first entry whose BranchRegexp matches the base branch of the PR.
+	BranchMilestones []BranchMilestone `json:"branch_milestones,omitempty"`
+	// LabelMilestones maps the labels of issues and PRs to milestones, so
+	// that `/milestone from-labels` sets the milestone of the entry whose
+	// LabelRegexp matches a label of the issue or PR. Labels mapping to
+	// different milestones are reported instead.
+	LabelMilestones []LabelMilestone `json:"label_milestones,omitempty"`
+	// MilestoneFreezes freeze milestones ahead of their release, so that
+	// they can no longer be set but by the members of a stricter team.
+	MilestoneFreezes []MilestoneFreeze `json:"milestone_freezes,omitempty"`
+	// MilestoneAliases maps aliases, e.g. `lts`, to the titles of the
+	// milestones which `/milestone <alias>` sets. Aliases are matched
+	// regardless of case, and keywords such as `next` take precedence.
+	MilestoneAliases map[string]string `json:"milestone_aliases,omitempty"`
+	// CurrentMilestone is the title of the milestone which `/milestone`
+	// without an argument sets, e.g. the milestone of the release being
+	// worked on. It takes precedence over BranchMilestones for `/milestone`
+	// without an argument, which then still apply to `/milestone auto`.
+	CurrentMilestone string `json:"current_milestone,omitempty"`
+	// ApplyOnApprove, if true, makes the approve plugin set the
+	// CurrentMilestone on the PRs without a milestone which a milestone
+	// maintainer approves with `/approve`.
+	ApplyOnApprove *bool `json:"apply_on_approve,omitempty"`
+	// StatusLabels maps the keywords accepted by the /status command of the
+	// milestonestatus plugin to the labels they apply, e.g.
+	// `blocked: status/blocked`. Defaults to the approved-for-milestone,
+	// in-progress and in-review statuses.
+	StatusLabels map[string]string `json:"status_labels,omitempty"`
+	// StatusLabelPrefix is the prefix of the labels of the default statuses
+	// of the /status command, e.g. `sig/status-`. It doesn't apply to the
+	// labels configured in StatusLabels. Defaults to `status/`.
+	StatusLabelPrefix string `json:"status_label_prefix,omitempty"`
+	// ValidateStatusLabels, if true, makes the milestonestatus plugin check
+	// once an hour per repo that the labels of the statuses exist in the repo,
+	// and log a warning about the missing ones, which adding would create.
+	ValidateStatusLabels *bool `json:"validate_status_labels,omitempty"`
+	// StatusSynonyms maps alternate keywords of the /status command to the
+	// keywords of the statuses they apply, e.g. `wip: in-progress`.
+	StatusSynonyms map[string]string `json:"status_synonyms,omitempty"`
+	// RequireMilestoneForStatus, if true, makes the milestonestatus plugin
+	// refuse to apply a status label to an issue or PR without a milestone.
+	RequireMilestoneForStatus *bool `json:"require_milestone_for_status,omitempty"`
+	// StatusMinimumPRAge, if set, makes the milestonestatus plugin refuse to
+	// apply a status label to a PR opened less than this long ago, e.g.
+	// '72h', for teams which only track the status of PRs once they have been
+	// open a while. It doesn't apply to issues.
+	StatusMinimumPRAge         string        `json:"status_minimum_pr_age,omitempty"`
+	StatusMinimumPRAgeDuration time.Duration `json:"-"`
+	// StatusAllowOnIssues, if false, makes the milestonestatus plugin refuse
+	// the /status command on issues, for workflows in which only PRs have a
+	// status. Defaults to true.
+	StatusAllowOnIssues *bool `json:"status_allow_on_issues,omitempty"`
+	// StatusTrackingIssue is the number of an issue of the repo aggregating
+	// the statuses of its issues and PRs. If set, the milestonestatus plugin
+	// comments on it each time a status is applied.
+	StatusTrackingIssue int `json:"status_tracking_issue,omitempty"`
+	// StatusCommandsPerComment is how many of the /status commands of a
+	// comment the milestonestatus plugin handles, the others are ignored.
+	// A comment giving conflicting statuses is rejected even if the limit
+	// would ignore some of them. Defaults to 1.
+	StatusCommandsPerComment int `json:"status_commands_per_comment,omitempty"`
+	// AllowCreate, if true, allows the milestone maintainers to create a
+	// missing milestone and set it with `/milestone <title> --create`.
+	AllowCreate *bool `json:"allow_create,omitempty"`
+	// TitlePattern is a regular expression which the titles of the milestones
+	// set by the milestone plugin must match, e.g. `^v\d+\.\d+$`. Milestones
+	// whose titles don't match are rejected even if they exist.
+	// Compiles into TitleRe during config load.
+	TitlePattern string         `json:"title_pattern,omitempty"`
+	TitleRe      *regexp.Regexp `json:"-"`
+	// NotifyURL, if set, is the URL to which the milestone plugin POSTs a JSON
+	// event with the org, repo, number, old_milestone, new_milestone and actor
+	// whenever a /milestone command sets or clears the milestone of the issue
+	// or PR it was issued on. Failures to notify are only logged.
+	NotifyURL string `json:"notify_url,omitempty"`
+	// MaintainersListLimit is how many milestone maintainers `/milestone who`
+	// lists at most, the others are only counted. Defaults to 20.
+	MaintainersListLimit int `json:"maintainers_list_limit,omitempty"`
+	// NotifyRateLimit is the maximum number of notifications per second
+	// posted to NotifyURL, e.g. when many milestones are set at once.
+	// Notifications exceeding it are dropped with a warning. Defaults to 10.
+	NotifyRateLimit int `json:"notify_rate_limit,omitempty"`
+	// TrackViaLabel, if true, makes the milestone plugin label the issue or
+	// PR with `milestone-set/<title>` when a /milestone command sets its
+	// milestone, replacing any previous such label, so that label-based
+	// tooling can follow the plugin's actions. Clearing the milestone
+	// removes the label.
+	TrackViaLabel *bool `json:"track_via_label,omitempty"`
+	// HandleEditedComments, if true, makes the milestone plugins also act on
+	// the commands in edited comments, so that correcting a mistyped command
+	// takes effect. A command in an edited comment is ignored if the milestone
+	// or status it sets or clears is already in place, so an edit does not
+	// apply the command of the original comment a second time.
+	HandleEditedComments *bool `json:"handle_edited_comments,omitempty"`
+	// UnauthorizedMessage, if set, is the text/template of the message with
+	// which the milestone plugins reject a user who isn't a milestone
+	// maintainer, e.g. to link to the docs of the org on how to become one.
+	// It is rendered with the fields of UnauthorizedInfo: {{.Org}}, {{.Teams}},
+	// {{.TeamLinks}}, {{.FriendlyName}} and {{.Login}}.
+	UnauthorizedMessage string `json:"unauthorized_message,omitempty"`
+	// UseReactions, if true, makes the milestone plugin react to the comment
+	// with the command with a thumbs up on success and a thumbs down on
+	// failure, instead of commenting.
+	UseReactions *bool `json:"use_reactions,omitempty"`
+	// DryRun, if true, makes the milestone plugin comment with the action it
+	// would have taken instead of setting or clearing the milestone.
+	DryRun *bool `json:"dry_run,omitempty"`
+	// AuditOnly, if true, makes the milestone plugins record the actions
+	// they would take, e.g. setting the milestone, adding a label or
+	// commenting, as JSON lines on stdout instead of taking them, so that
+	// they can be evaluated without acting on any issue or PR.
+	AuditOnly *bool `json:"audit_only,omitempty"`
+}
+
+// AllowsStatusOnIssues returns whether the /status command may be used on
+// issues, and not only on PRs.
+func (m Milestone) AllowsStatusOnIssues() bool {
+	if m.StatusAllowOnIssues != nil {
+		return *m.StatusAllowOnIssues
+	}
+	return true
+}
+
+// isTrue returns whether b is set to true.
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// RequiresTeamMaintainerRole returns whether RequireTeamMaintainerRole is set.
+func (m Milestone) RequiresTeamMaintainerRole() bool {
+	return isTrue(m.RequireTeamMaintainerRole)
+}
+
+// AllowsOrgMembers returns whether AllowOrgMembers is set.
+func (m Milestone) AllowsOrgMembers() bool {
+	return isTrue(m.AllowOrgMembers)
+}
+
+// RequiresClearConfirmation returns whether RequireClearConfirmation is set.
+func (m Milestone) RequiresClearConfirmation() bool {
+	return isTrue(m.RequireClearConfirmation)
+}
+
+// WarnsOnClosedIssue returns whether WarnOnClosedIssue is set.
+func (m Milestone) WarnsOnClosedIssue() bool {
+	return isTrue(m.WarnOnClosedIssue)
+}
+
+// IsSilentOnInvalid returns whether SilentOnInvalid is set.
+func (m Milestone) IsSilentOnInvalid() bool {
+	return isTrue(m.SilentOnInvalid)
+}
+
+// ConfirmsMilestone returns whether ConfirmMilestone is set.
+func (m Milestone) ConfirmsMilestone() bool {
+	return isTrue(m.ConfirmMilestone)
+}
+
+// AllowsClosedMilestones returns whether AllowClosedMilestones is set.
+func (m Milestone) AllowsClosedMilestones() bool {
+	return isTrue(m.AllowClosedMilestones)
+}
+
+// MatchesVersionPrefix returns whether MatchVersionPrefix is set.
+func (m Milestone) MatchesVersionPrefix() bool {
+	return isTrue(m.MatchVersionPrefix)
+}
+
+// PropagatesToLinkedIssues returns whether PropagateToLinkedIssues is set.
+func (m Milestone) PropagatesToLinkedIssues() bool {
+	return isTrue(m.PropagateToLinkedIssues)
+}
+
+// AppliesOnApprove returns whether ApplyOnApprove is set.
+func (m Milestone) AppliesOnApprove() bool {
+	return isTrue(m.ApplyOnApprove)
+}
+
+// ValidatesStatusLabels returns whether ValidateStatusLabels is set.
+func (m Milestone) ValidatesStatusLabels() bool {
+	return isTrue(m.ValidateStatusLabels)
+}
+
+// RequiresMilestoneForStatus returns whether RequireMilestoneForStatus is set.
+func (m Milestone) RequiresMilestoneForStatus() bool {
+	return isTrue(m.RequireMilestoneForStatus)
+}
+
+// AllowsCreate returns whether AllowCreate is set.
+func (m Milestone) AllowsCreate() bool {
+	return isTrue(m.AllowCreate)
+}
+
+// TracksViaLabel returns whether TrackViaLabel is set.
+func (m Milestone) TracksViaLabel() bool {
+	return isTrue(m.TrackViaLabel)
+}
+
+// HandlesEditedComments returns whether HandleEditedComments is set.
+func (m Milestone) HandlesEditedComments() bool {
+	return isTrue(m.HandleEditedComments)
+}
+
+// UsesReactions returns whether UseReactions is set.
+func (m Milestone) UsesReactions() bool {
+	return isTrue(m.UseReactions)
+}
+
+// IsDryRun returns whether DryRun is set.
+func (m Milestone) IsDryRun() bool {
+	return isTrue(m.DryRun)
+}
+
+// IsAuditOnly returns whether AuditOnly is set.
+func (m Milestone) IsAuditOnly() bool {
+	return isTrue(m.AuditOnly)
+}
+
+// FriendlyName returns the MaintainersFriendlyName without surrounding
+// whitespace, or the default if it is empty.
+func (m Milestone) FriendlyName() string {
+	if name := strings.TrimSpace(m.MaintainersFriendlyName); name != "" {
+		return name
+	}
+	return defaultMaintainersFriendlyName
+}
+
+// AuthorizesOrgMembers returns whether every org member is a milestone
+// maintainer, which is the case if AllowOrgMembers is set and no team is.
+func (m Milestone) AuthorizesOrgMembers() bool {
+	return m.AllowsOrgMembers() && len(m.MaintainersTeamSlugs()) == 0 && m.MaintainersID == 0
+}
+
+// UnauthorizedInfo is the data with which the UnauthorizedMessage template of
+// a Milestone is rendered.
+type UnauthorizedInfo struct {
+	// Org is the org of the repo in which the command was issued.
+	Org string
+	// Teams are the slugs of the milestone maintainers teams.
+	Teams []string
+	// TeamLinks are the markdown links to the milestone maintainers teams,
+	// or to the org if all its members are milestone maintainers.
+	TeamLinks string
+	// FriendlyName is the MaintainersFriendlyName of the milestone maintainers.
+	FriendlyName string
+	// Login is the login of the user who issued the command.
+	Login string
+}
+
+// MergeOver returns m with its unset fields taken from defaults. The
+// maintainers teams and the clear team of m each replace those of defaults as
+// a whole if any of their fields are set. The boolean fields are pointers so
+// that m can turn off those turned on by defaults.
+func (m Milestone) MergeOver(defaults Milestone) Milestone {
+	if m.MaintainersID != 0 || m.MaintainersTeam != "" || len(m.MaintainersTeams) > 0 {
+		defaults.MaintainersID, defaults.MaintainersTeam, defaults.MaintainersTeamRef, defaults.MaintainersTeams = 0, "", "", nil
+	}
+	if m.ClearTeam != "" || m.ClearTeamID != 0 {
+		defaults.ClearTeam, defaults.ClearTeamID = "", 0
+	}
+	merged := reflect.ValueOf(&defaults).Elem()
+	overrides := reflect.ValueOf(m)
+	for i := 0; i < overrides.NumField(); i++ {
+		if field := overrides.Field(i); !field.IsZero() {
+			merged.Field(i).Set(field)
+		}
+	}
+	return defaults
+}
+
+// MilestoneFor returns the repo_milestone config of the org/repo repo merged
+// over the config of all the repos of the org, the entry for org/*, merged
+// over the default config, the entry for "".
+func MilestoneFor(repoMilestone map[string]Milestone, org, repo string) Milestone {
+	milestone := repoMilestone[""]
+	if orgMilestone, exists := repoMilestone[fmt.Sprintf("%s/*", org)]; exists {
+		milestone = orgMilestone.MergeOver(milestone)
+	}
+	if repoMilestone, exists := repoMilestone[fmt.Sprintf("%s/%s", org, repo)]; exists {
+		milestone = repoMilestone.MergeOver(milestone)
+	}
+	return milestone
+}
+
+// HasMilestoneFor returns whether repo_milestone configures the org/repo repo,
+// either by itself or with all the repos of the org, rather than only by
+// default.
+func HasMilestoneFor(repoMilestone map[string]Milestone, org, repo string) bool {
+	_, orgExists := repoMilestone[fmt.Sprintf("%s/*", org)]
+	_, repoExists := repoMilestone[fmt.Sprintf("%s/%s", org, repo)]
+	return orgExists || repoExists
+}
+
+// BranchMilestone maps PRs against the branches matching BranchRegexp to Milestone.
+type BranchMilestone struct {
+	// BranchRegexp is the regular expression for the base branches of PRs,
+	// e.g. `^release-1\.20$`.
+	// Compiles into BranchRe during config load.
+	BranchRegexp string         `json:"branchregexp"`
+	BranchRe     *regexp.Regexp `json:"-"`
+	// Milestone is the title of the milestone to set, e.g. `v1.20`.
+	Milestone string `json:"milestone"`
+}
+
+// MaintainersTeamSlugs returns the slugs of all configured milestone maintainers teams.
+func (m Milestone) MaintainersTeamSlugs() []string {
+	var teams []string
+	if m.MaintainersTeam != "" {
+		teams = append(teams, m.MaintainersTeam)
+	}
+	return append(teams, m.MaintainersTeams...)
+}
+
+// ForChangedFiles returns the config authorizing the teams of the
+// PathMaintainers owning any of the files changed by a PR instead of the
+// milestone maintainers teams, or the config itself if none owns any of them.
+func (m Milestone) ForChangedFiles(files []string) Milestone {
+	var teams []string
+	seen := sets.NewString()
+	for _, pm := range m.PathMaintainers {
+		if !pm.owns(files) {
+			continue
+		}
+		for _, team := range pm.MaintainersTeams {
+			if !seen.Has(team) {
+				seen.Insert(team)
+				teams = append(teams, team)
+			}
+		}
+	}
+	if len(teams) == 0 {
+		return m
+	}
+	m.MaintainersID, m.MaintainersTeam, m.MaintainersTeamRef, m.MaintainersTeams = 0, "", "", teams
+	m.AllowOrgMembers = nil
+	return m
+}
+
+// WithMilestoneMaintainers returns m with the teams owning specific milestones
+// added to its maintainers teams, so that their members are authorized to use
+// the milestone command before it is known which milestone they set.
+func (m Milestone) WithMilestoneMaintainers() Milestone {
+	if len(m.MilestoneMaintainers) == 0 || m.AuthorizesOrgMembers() {
+		return m
+	}
+	teams := m.MaintainersTeamSlugs()
+	seen := sets.NewString(teams...)
+	for _, mm := range m.MilestoneMaintainers {
+		for _, team := range mm.MaintainersTeams {
+			if !seen.Has(team) {
+				seen.Insert(team)
+				teams = append(teams, team)
+			}
+		}
+	}
+	m.MaintainersTeam, m.MaintainersTeamRef, m.MaintainersTeams = "", "", teams
+	return m
+}
+
+// ForMilestone returns m with the teams owning the milestone titled title, if
+// any, as its maintainers teams. Titles are compared regardless of case.
+func (m Milestone) ForMilestone(title string) Milestone {
+	for _, mm := range m.MilestoneMaintainers {
+		if strings.EqualFold(strings.TrimSpace(mm.Milestone), strings.TrimSpace(title)) {
+			m.MaintainersID, m.MaintainersTeam, m.MaintainersTeamRef, m.MaintainersTeams = 0, "", "", mm.MaintainersTeams
+			m.AllowOrgMembers = nil
+			return m
+		}
+	}
+	return m
+}
+
+// owns returns whether any of the files matches the Paths.
+func (pm PathMaintainers) owns(files []string) bool {
+	for _, glob := range pm.Paths {
+		for _, file := range files {
+			// the globs are validated when the config is loaded
+			if match, _ := matchPath(glob, file); match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPath returns whether the slash-separated path matches the glob, whose
+// `**` elements match any number of path elements and whose other elements
+// are matched by path.Match.
+func matchPath(glob, name string) (bool, error) {
+	patterns := strings.Split(glob, "/")
+	var match func(patterns, elems []string) (bool, error)
+	match = func(patterns, elems []string) (bool, error) {
+		if len(patterns) == 0 {
+			return len(elems) == 0, nil
+		}
+		if patterns[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matched, err := match(patterns[1:], elems[i:]); matched || err != nil {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+		if len(elems) == 0 {
+			// validate the remaining patterns
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return false, err
+				}
+			}
+			return false, nil
+		}
+		matched, err := path.Match(patterns[0], elems[0])
+		if !matched || err != nil {
+			return false, err
+		}
+		return match(patterns[1:], elems[1:])
+	}
+	var elems []string
+	if name != "" {
+		elems = strings.Split(name, "/")
+	}
+	return match(patterns, elems)
+}
+
+// PathMaintainers are the teams whose members may set the milestone of the PRs
+// changing files matching Paths, instead of the milestone maintainers teams.
+type PathMaintainers struct {
+	// Paths are the globs of the files owned by MaintainersTeams, e.g.
+	// `staging/src/**/*`, where `**` matches any number of directories.
+	Paths []string `json:"paths"`
+	// MaintainersTeams are the slugs of the teams owning the milestone of
+	// the PRs changing files matching Paths.
+	MaintainersTeams []string `json:"maintainers_teams"`
+}
+
+// MilestoneMaintainers are the teams whose members may set the milestone titled
+// Milestone, instead of the milestone maintainers teams.
+type MilestoneMaintainers struct {
+	// Milestone is the title of the milestone, e.g. `backlog`.
+	Milestone string `json:"milestone"`
+	// MaintainersTeams are the slugs of the teams owning the milestone.
+	MaintainersTeams []string `json:"maintainers_teams"`
+}
+
+// LabelMilestone maps the issues and PRs with a label matching LabelRegexp to Milestone.
+type LabelMilestone struct {
+	// LabelRegexp is the regular expression for the labels of issues and
+	// PRs, e.g. `^area/release-1\.20$`.
+	// Compiles into LabelRe during config load.
+	LabelRegexp string         `json:"labelregexp"`
+	LabelRe     *regexp.Regexp `json:"-"`
+	// Milestone is the title of the milestone to set, e.g. `v1.20`.
+	Milestone string `json:"milestone"`
+}
+
+// MilestoneFreeze freezes the milestone titled Milestone from FreezeAt.
+type MilestoneFreeze struct {
+	// Milestone is the title of the frozen milestone, e.g. `v1.20`.
+	Milestone string `json:"milestone"`
+	// FreezeAt is the RFC 3339 time from which the milestone is frozen,
+	// e.g. `2022-08-02T00:00:00Z`.
+	// Compiles into FreezeTime during config load.
+	FreezeAt   string    `json:"freeze_at"`
+	FreezeTime time.Time `json:"-"`
+	// FreezeTeam is the slug of the github team whose members may still set
+	// the milestone once it is frozen. If unset, nobody may.
+	FreezeTeam string `json:"freeze_team,omitempty"`
 }
 
 // BranchToMilestone is a map of the branch name to the configured milestone for that branch.
@@ -1028,11 +1615,6 @@ func (c *Configuration) setDefaults() {
 	if c.Owners.LabelsDenyList == nil {
 		c.Owners.LabelsDenyList = []string{labels.Approved, labels.LGTM}
 	}
-	for _, milestone := range c.RepoMilestone {
-		if milestone.MaintainersFriendlyName == "" {
-			milestone.MaintainersFriendlyName = "SIG Chairs/TLs"
-		}
-	}
 	if c.CherryPickUnapproved.BranchRegexp == "" {
 		c.CherryPickUnapproved.BranchRegexp = `^release-.*$`
 	}
@@ -1252,12 +1834,117 @@ func validateTrigger(triggers []Trigger) error {
 
 var warnRepoMilestone time.Time
 
-func validateRepoMilestone(milestones map[string]Milestone) {
-	for _, milestone := range milestones {
+func validateRepoMilestone(milestones map[string]Milestone) error {
+	repos := make([]string, 0, len(milestones))
+	for repo := range milestones {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		// the config of a repo is only used merged over the config of its
+		// org and the default config
+		milestone := milestones[repo]
+		if org, name, ok := strings.Cut(repo, "/"); ok {
+			milestone = MilestoneFor(milestones, org, name)
+		} else if repo != "" {
+			milestone = milestone.MergeOver(milestones[""])
+		}
 		if milestone.MaintainersID != 0 {
 			logrusutil.ThrottledWarnf(&warnRepoMilestone, time.Hour, "deprecated field: maintainers_id is configured for repo_milestone, maintainers_team should be used instead")
 		}
+		teams := milestone.MaintainersTeamSlugs()
+		if len(teams) == 0 && milestone.MaintainersID == 0 && !milestone.AllowsOrgMembers() {
+			return fmt.Errorf("repo_milestone of %q configures no milestone maintainers: one of maintainers_team, maintainers_teams or maintainers_id must be set", repo)
+		}
+		for _, pm := range milestone.PathMaintainers {
+			if len(pm.Paths) == 0 || len(pm.MaintainersTeams) == 0 {
+				return fmt.Errorf("path_maintainers of repo_milestone of %q must configure both paths and maintainers_teams", repo)
+			}
+			for _, glob := range pm.Paths {
+				if _, err := matchPath(glob, ""); err != nil {
+					return fmt.Errorf("invalid path %q configured in path_maintainers of repo_milestone of %q: %w", glob, repo, err)
+				}
+			}
+		}
+		for _, mm := range milestone.MilestoneMaintainers {
+			if mm.Milestone == "" || len(mm.MaintainersTeams) == 0 {
+				return fmt.Errorf("milestone_maintainers of repo_milestone of %q must configure both milestone and maintainers_teams", repo)
+			}
+			teams = append(teams, mm.MaintainersTeams...)
+		}
+		if len(milestone.MilestoneMaintainers) > 0 && milestone.MaintainersID != 0 {
+			return fmt.Errorf("repo_milestone of %q configures both maintainers_id and milestone_maintainers: maintainers_team should be used instead of maintainers_id", repo)
+		}
+		if milestone.MaxAttempts < 0 {
+			return fmt.Errorf("repo_milestone of %q configures a negative max_attempts: %d", repo, milestone.MaxAttempts)
+		}
+		for alias, title := range milestone.MilestoneAliases {
+			if strings.TrimSpace(alias) == "" || strings.TrimSpace(title) == "" {
+				return fmt.Errorf("invalid milestone_aliases entry %q: %q configured for repo_milestone of %q: neither the alias nor the milestone may be empty", alias, title, repo)
+			}
+		}
+		for synonym, keyword := range milestone.StatusSynonyms {
+			if strings.TrimSpace(synonym) == "" || strings.TrimSpace(keyword) == "" {
+				return fmt.Errorf("invalid status_synonyms entry %q: %q configured for repo_milestone of %q: neither the synonym nor the status may be empty", synonym, keyword, repo)
+			}
+		}
+		if milestone.NotifyRateLimit < 0 {
+			return fmt.Errorf("repo_milestone of %q configures a negative notify_rate_limit: %d", repo, milestone.NotifyRateLimit)
+		}
+		if milestone.MaintainersListLimit < 0 {
+			return fmt.Errorf("repo_milestone of %q configures a negative maintainers_list_limit: %d", repo, milestone.MaintainersListLimit)
+		}
+		if milestone.StatusCommandsPerComment < 0 {
+			return fmt.Errorf("repo_milestone of %q configures a negative status_commands_per_comment: %d", repo, milestone.StatusCommandsPerComment)
+		}
+		if len(milestone.MaintainersTeams) > 0 && milestone.MaintainersID != 0 {
+			return fmt.Errorf("repo_milestone of %q configures both maintainers_id and maintainers_teams: maintainers_id may only be set alone or along with maintainers_team", repo)
+		}
+		if milestone.UnauthorizedMessage != "" {
+			if _, err := UnauthorizedMessage(milestone, UnauthorizedInfo{}, ""); err != nil {
+				return fmt.Errorf("invalid unauthorized_message configured for repo_milestone of %q: %w", repo, err)
+			}
+		}
+		if milestone.ClearTeam != "" {
+			teams = append(teams, milestone.ClearTeam)
+		}
+		if milestone.BulkTeam != "" {
+			teams = append(teams, milestone.BulkTeam)
+		}
+		for _, team := range teams {
+			for _, slug := range strings.Split(team, "/") {
+				if slug == "" {
+					return fmt.Errorf("invalid team %q configured for repo_milestone of %q: team paths must consist of non-empty team slugs separated by '/'", team, repo)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveMilestoneTeamRefs sets the MaintainersTeam of each repo_milestone
+// entry with a MaintainersTeamRef to the team the reference is defined as for
+// the org of the repo, or for all orgs.
+func resolveMilestoneTeamRefs(pc *Configuration) error {
+	for name, milestone := range pc.RepoMilestone {
+		if milestone.MaintainersTeamRef == "" {
+			continue
+		}
+		if milestone.MaintainersTeam != "" {
+			return fmt.Errorf("repo_milestone of %q configures both maintainers_team and maintainers_team_ref: only one of them may be set", name)
+		}
+		org := strings.SplitN(name, "/", 2)[0]
+		team, ok := pc.MilestoneTeamRefs[org][milestone.MaintainersTeamRef]
+		if !ok {
+			team, ok = pc.MilestoneTeamRefs[""][milestone.MaintainersTeamRef]
+		}
+		if !ok {
+			return fmt.Errorf("repo_milestone of %q refers to the team %q, which is not defined in milestone_team_refs", name, milestone.MaintainersTeamRef)
+		}
+		milestone.MaintainersTeam = team
+		pc.RepoMilestone[name] = milestone
 	}
+	return nil
 }
 
 func compileRegexpsAndDurations(pc *Configuration) error {
@@ -1305,6 +1992,73 @@ func compileRegexpsAndDurations(pc *Configuration) error {
 		}
 		rs[i].GracePeriodDuration = dur
 	}
+
+	for name, milestone := range pc.RepoMilestone {
+		for i, bm := range milestone.BranchMilestones {
+			branchRe, err := regexp.Compile(bm.BranchRegexp)
+			if err != nil {
+				return fmt.Errorf("failed to compile branch milestone branchregexp for %q: %q, error: %w", name, bm.BranchRegexp, err)
+			}
+			milestone.BranchMilestones[i].BranchRe = branchRe
+		}
+		for i, lm := range milestone.LabelMilestones {
+			labelRe, err := regexp.Compile(lm.LabelRegexp)
+			if err != nil {
+				return fmt.Errorf("failed to compile label milestone labelregexp for %q: %q, error: %w", name, lm.LabelRegexp, err)
+			}
+			milestone.LabelMilestones[i].LabelRe = labelRe
+		}
+		for i, freeze := range milestone.MilestoneFreezes {
+			freezeTime, err := time.Parse(time.RFC3339, freeze.FreezeAt)
+			if err != nil {
+				return fmt.Errorf("failed to compile milestone freeze time for %q: %q, error: %w", name, freeze.FreezeAt, err)
+			}
+			milestone.MilestoneFreezes[i].FreezeTime = freezeTime
+		}
+		if milestone.TitlePattern != "" {
+			titleRe, err := regexp.Compile(milestone.TitlePattern)
+			if err != nil {
+				return fmt.Errorf("failed to compile milestone title pattern for %q: %q, error: %w", name, milestone.TitlePattern, err)
+			}
+			milestone.TitleRe = titleRe
+		}
+		if milestone.MilestonesCacheTTL != "" {
+			dur, err := time.ParseDuration(milestone.MilestonesCacheTTL)
+			if err != nil {
+				return fmt.Errorf("failed to compile milestones cache ttl for %q: %q, error: %w", name, milestone.MilestonesCacheTTL, err)
+			}
+			milestone.MilestonesCacheTTLDuration = dur
+		}
+		if milestone.TeamMembershipCacheTTL != "" {
+			dur, err := time.ParseDuration(milestone.TeamMembershipCacheTTL)
+			if err != nil {
+				return fmt.Errorf("failed to compile team membership cache ttl for %q: %q, error: %w", name, milestone.TeamMembershipCacheTTL, err)
+			}
+			milestone.TeamMembershipCacheTTLDuration = dur
+		}
+		if milestone.DuplicateCommandWindow != "" {
+			dur, err := time.ParseDuration(milestone.DuplicateCommandWindow)
+			if err != nil {
+				return fmt.Errorf("failed to compile duplicate command window for %q: %q, error: %w", name, milestone.DuplicateCommandWindow, err)
+			}
+			milestone.DuplicateCommandWindowDuration = dur
+		}
+		if milestone.ChangeCooldown != "" {
+			dur, err := time.ParseDuration(milestone.ChangeCooldown)
+			if err != nil {
+				return fmt.Errorf("failed to compile change cooldown for %q: %q, error: %w", name, milestone.ChangeCooldown, err)
+			}
+			milestone.ChangeCooldownDuration = dur
+		}
+		if milestone.StatusMinimumPRAge != "" {
+			dur, err := time.ParseDuration(milestone.StatusMinimumPRAge)
+			if err != nil {
+				return fmt.Errorf("failed to compile status minimum PR age for %q: %q, error: %w", name, milestone.StatusMinimumPRAge, err)
+			}
+			milestone.StatusMinimumPRAgeDuration = dur
+		}
+		pc.RepoMilestone[name] = milestone
+	}
 	return nil
 }
 
@@ -1319,6 +2073,10 @@ func (c *Configuration) Validate() error {
 	if err := compileRegexpsAndDurations(c); err != nil {
 		return err
 	}
+	// Team references must be resolved before the milestone maintainers are validated.
+	if err := resolveMilestoneTeamRefs(c); err != nil {
+		return err
+	}
 
 	if err := validatePluginsDupes(c.Plugins); err != nil {
 		return err
@@ -1344,7 +2102,9 @@ func (c *Configuration) Validate() error {
 	if err := validateTrigger(c.Triggers); err != nil {
 		return err
 	}
-	validateRepoMilestone(c.RepoMilestone)
+	if err := validateRepoMilestone(c.RepoMilestone); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -1378,6 +1378,475 @@ func TestValidateConfigUpdater(t *testing.T) {
 	}
 }
 
+func TestValidateRepoMilestone(t *testing.T) {
+	testCases := []struct {
+		name        string
+		milestones  map[string]Milestone
+		expectedErr string
+	}{
+		{
+			name:       "team slug",
+			milestones: map[string]Milestone{"org/repo": {MaintainersTeam: "leads"}},
+		},
+		{
+			name:       "nested team path",
+			milestones: map[string]Milestone{"org/repo": {MaintainersTeam: "parent-team/child-team", ClearTeam: "admins/leads"}},
+		},
+		{
+			name:       "team ID",
+			milestones: map[string]Milestone{"org/repo": {MaintainersID: 42}},
+		},
+		{
+			name:       "org members",
+			milestones: map[string]Milestone{"org/repo": {AllowOrgMembers: utilpointer.BoolPtr(true)}},
+		},
+		{
+			name:       "milestone maintainers inherited from the default",
+			milestones: map[string]Milestone{"": {MaintainersTeam: "leads"}, "org/repo": {MaintainersFriendlyName: "Leads"}},
+		},
+		{
+			name: "milestone maintainers inherited from the org",
+			milestones: map[string]Milestone{
+				"org/*":    {MaintainersTeam: "leads"},
+				"org/repo": {MaintainersFriendlyName: "Release Team"},
+			},
+		},
+		{
+			name:        "no milestone maintainers",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersFriendlyName: "Leads"}},
+			expectedErr: `repo_milestone of "org/repo" configures no milestone maintainers: one of maintainers_team, maintainers_teams or maintainers_id must be set`,
+		},
+		{
+			name:       "team ID as the fallback of the team slug",
+			milestones: map[string]Milestone{"org/repo": {MaintainersID: 42, MaintainersTeam: "leads"}},
+		},
+		{
+			name:        "both team ID and additional team slugs",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersID: 42, MaintainersTeams: []string{"leads"}}},
+			expectedErr: `repo_milestone of "org/repo" configures both maintainers_id and maintainers_teams: maintainers_id may only be set alone or along with maintainers_team`,
+		},
+		{
+			name:        "nested team path with an empty slug",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "parent-team//child-team"}},
+			expectedErr: `invalid team "parent-team//child-team" configured for repo_milestone of "org/repo": team paths must consist of non-empty team slugs separated by '/'`,
+		},
+		{
+			name:        "additional team path with a trailing slash",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeams: []string{"parent-team/"}}},
+			expectedErr: `invalid team "parent-team/" configured for repo_milestone of "org/repo": team paths must consist of non-empty team slugs separated by '/'`,
+		},
+		{
+			name:        "negative max attempts",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", MaxAttempts: -1}},
+			expectedErr: `repo_milestone of "org/repo" configures a negative max_attempts: -1`,
+		},
+		{
+			name:        "negative status commands per comment",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", StatusCommandsPerComment: -1}},
+			expectedErr: `repo_milestone of "org/repo" configures a negative status_commands_per_comment: -1`,
+		},
+		{
+			name:        "status synonym without a status",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", StatusSynonyms: map[string]string{"wip": ""}}},
+			expectedErr: `invalid status_synonyms entry "wip": "" configured for repo_milestone of "org/repo": neither the synonym nor the status may be empty`,
+		},
+		{
+			name:        "negative maintainers list limit",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", MaintainersListLimit: -1}},
+			expectedErr: `repo_milestone of "org/repo" configures a negative maintainers_list_limit: -1`,
+		},
+		{
+			name:        "negative notify rate limit",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", NotifyRateLimit: -1}},
+			expectedErr: `repo_milestone of "org/repo" configures a negative notify_rate_limit: -1`,
+		},
+		{
+			name:       "milestone aliases",
+			milestones: map[string]Milestone{"org/repo": {MaintainersTeam: "leads", MilestoneAliases: map[string]string{"lts": "v1.18"}}},
+		},
+		{
+			name:        "milestone alias without a milestone",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", MilestoneAliases: map[string]string{"lts": " "}}},
+			expectedErr: `invalid milestone_aliases entry "lts": " " configured for repo_milestone of "org/repo": neither the alias nor the milestone may be empty`,
+		},
+		{
+			name:       "milestone maintainers",
+			milestones: map[string]Milestone{"org/repo": {MaintainersTeam: "leads", MilestoneMaintainers: []MilestoneMaintainers{{Milestone: "backlog", MaintainersTeams: []string{"triage"}}}}},
+		},
+		{
+			name:        "milestone maintainers without teams",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", MilestoneMaintainers: []MilestoneMaintainers{{Milestone: "backlog"}}}},
+			expectedErr: `milestone_maintainers of repo_milestone of "org/repo" must configure both milestone and maintainers_teams`,
+		},
+		{
+			name:        "milestone maintainers with a team ID",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersID: 42, MilestoneMaintainers: []MilestoneMaintainers{{Milestone: "backlog", MaintainersTeams: []string{"triage"}}}}},
+			expectedErr: `repo_milestone of "org/repo" configures both maintainers_id and milestone_maintainers: maintainers_team should be used instead of maintainers_id`,
+		},
+		{
+			name:       "path maintainers",
+			milestones: map[string]Milestone{"org/repo": {MaintainersTeam: "leads", PathMaintainers: []PathMaintainers{{Paths: []string{"staging/**/*"}, MaintainersTeams: []string{"staging"}}}}},
+		},
+		{
+			name:        "path maintainers without teams",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", PathMaintainers: []PathMaintainers{{Paths: []string{"staging/**/*"}}}}},
+			expectedErr: `path_maintainers of repo_milestone of "org/repo" must configure both paths and maintainers_teams`,
+		},
+		{
+			name:        "path maintainers with invalid glob",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", PathMaintainers: []PathMaintainers{{Paths: []string{"staging/["}, MaintainersTeams: []string{"staging"}}}}},
+			expectedErr: `invalid path "staging/[" configured in path_maintainers of repo_milestone of "org/repo": syntax error in pattern`,
+		},
+		{
+			name:       "unauthorized message",
+			milestones: map[string]Milestone{"org/repo": {MaintainersTeam: "leads", UnauthorizedMessage: "Ask {{.FriendlyName}} to add you to {{.TeamLinks}}."}},
+		},
+		{
+			name:        "unauthorized message with invalid syntax",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", UnauthorizedMessage: "Ask {{.FriendlyName"}},
+			expectedErr: `invalid unauthorized_message configured for repo_milestone of "org/repo": failed to parse the unauthorized message: template: unauthorized_message:1: unclosed action`,
+		},
+		{
+			name:        "unauthorized message with an unknown field",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", UnauthorizedMessage: "Ask {{.Team}}"}},
+			expectedErr: `invalid unauthorized_message configured for repo_milestone of "org/repo": failed to render the unauthorized message: template: unauthorized_message:1:6: executing "unauthorized_message" at <.Team>: can't evaluate field Team in type plugins.UnauthorizedInfo`,
+		},
+		{
+			name:        "clear team path with a leading slash",
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", ClearTeam: "/admins"}},
+			expectedErr: `invalid team "/admins" configured for repo_milestone of "org/repo": team paths must consist of non-empty team slugs separated by '/'`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var actualErr string
+			if err := validateRepoMilestone(tc.milestones); err != nil {
+				actualErr = err.Error()
+			}
+			if actualErr != tc.expectedErr {
+				t.Errorf("expected error %q, got %q", tc.expectedErr, actualErr)
+			}
+		})
+	}
+}
+
+func TestForChangedFiles(t *testing.T) {
+	milestone := Milestone{
+		MaintainersTeam:         "leads",
+		AllowOrgMembers:         utilpointer.BoolPtr(true),
+		MaintainersFriendlyName: "SIG Leads",
+		PathMaintainers: []PathMaintainers{
+			{Paths: []string{"staging/**/*"}, MaintainersTeams: []string{"staging"}},
+			{Paths: []string{"docs/*.md", "*.md"}, MaintainersTeams: []string{"docs", "staging"}},
+		},
+	}
+	testcases := []struct {
+		name          string
+		files         []string
+		expectedTeams []string
+	}{
+		{
+			name:          "no path owned by a team falls back to the milestone maintainers",
+			files:         []string{"cmd/main.go"},
+			expectedTeams: []string{"leads"},
+		},
+		{
+			name:          "path owned by a team",
+			files:         []string{"cmd/main.go", "staging/src/pkg/main.go"},
+			expectedTeams: []string{"staging"},
+		},
+		{
+			name:          "paths owned by several teams",
+			files:         []string{"staging/src/main.go", "README.md"},
+			expectedTeams: []string{"staging", "docs"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := milestone.ForChangedFiles(tc.files)
+			if diff := cmp.Diff(tc.expectedTeams, actual.MaintainersTeamSlugs()); diff != "" {
+				t.Errorf("unexpected maintainers teams (-expected +got):\n%s", diff)
+			}
+			if actual.MaintainersFriendlyName != milestone.MaintainersFriendlyName {
+				t.Errorf("expected the friendly name %q, got %q", milestone.MaintainersFriendlyName, actual.MaintainersFriendlyName)
+			}
+		})
+	}
+}
+
+func TestForMilestone(t *testing.T) {
+	milestone := Milestone{
+		MaintainersTeam: "leads",
+		MilestoneMaintainers: []MilestoneMaintainers{
+			{Milestone: "Backlog", MaintainersTeams: []string{"triage"}},
+			{Milestone: "v1.20", MaintainersTeams: []string{"release", "leads"}},
+		},
+	}
+	testcases := []struct {
+		name          string
+		title         string
+		expectedTeams []string
+	}{
+		{
+			name:          "milestone owned by no team falls back to the milestone maintainers",
+			title:         "v1.21",
+			expectedTeams: []string{"leads"},
+		},
+		{
+			name:          "milestone owned by a team",
+			title:         "v1.20",
+			expectedTeams: []string{"release", "leads"},
+		},
+		{
+			name:          "titles are compared regardless of case",
+			title:         "backlog",
+			expectedTeams: []string{"triage"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := milestone.ForMilestone(tc.title)
+			if diff := cmp.Diff(tc.expectedTeams, actual.MaintainersTeamSlugs()); diff != "" {
+				t.Errorf("unexpected maintainers teams (-expected +got):\n%s", diff)
+			}
+		})
+	}
+
+	expected := []string{"leads", "triage", "release"}
+	if diff := cmp.Diff(expected, milestone.WithMilestoneMaintainers().MaintainersTeamSlugs()); diff != "" {
+		t.Errorf("unexpected maintainers teams with the milestone maintainers (-expected +got):\n%s", diff)
+	}
+	orgMembers := Milestone{AllowOrgMembers: utilpointer.BoolPtr(true), MilestoneMaintainers: milestone.MilestoneMaintainers}
+	if !orgMembers.WithMilestoneMaintainers().AuthorizesOrgMembers() {
+		t.Error("expected the org members to stay authorized with the milestone maintainers")
+	}
+}
+
+func TestMilestoneFriendlyName(t *testing.T) {
+	testcases := []struct {
+		name         string
+		friendlyName string
+		expected     string
+	}{
+		{
+			name:         "configured friendly name",
+			friendlyName: "SIG Chairs/TLs",
+			expected:     "SIG Chairs/TLs",
+		},
+		{
+			name:         "surrounding whitespace is trimmed",
+			friendlyName: "  Release Team \n",
+			expected:     "Release Team",
+		},
+		{
+			name:     "empty friendly name defaults",
+			expected: "org administrators",
+		},
+		{
+			name:         "blank friendly name defaults",
+			friendlyName: "   ",
+			expected:     "org administrators",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := (Milestone{MaintainersFriendlyName: tc.friendlyName}).FriendlyName(); actual != tc.expected {
+				t.Errorf("expected the friendly name %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMilestoneFor(t *testing.T) {
+	defaults := Milestone{MaintainersTeam: "leads", MaintainersFriendlyName: "SIG Leads", ClearTeam: "admins", ConfirmMilestone: utilpointer.BoolPtr(true)}
+	testcases := []struct {
+		name          string
+		repoMilestone map[string]Milestone
+		expected      Milestone
+	}{
+		{
+			name:          "repo without config uses the default",
+			repoMilestone: map[string]Milestone{"": defaults},
+			expected:      defaults,
+		},
+		{
+			name: "repo overriding the friendly name inherits the default team",
+			repoMilestone: map[string]Milestone{
+				"":         defaults,
+				"org/repo": {MaintainersFriendlyName: "Release Team"},
+			},
+			expected: Milestone{MaintainersTeam: "leads", MaintainersFriendlyName: "Release Team", ClearTeam: "admins", ConfirmMilestone: utilpointer.BoolPtr(true)},
+		},
+		{
+			name: "repo teams replace all the default teams",
+			repoMilestone: map[string]Milestone{
+				"":         {MaintainersTeam: "leads", MaintainersTeams: []string{"admins"}, ClearTeam: "admins"},
+				"org/repo": {MaintainersTeams: []string{"release"}, ClearTeamID: 7},
+			},
+			expected: Milestone{MaintainersTeams: []string{"release"}, ClearTeamID: 7},
+		},
+		{
+			name: "repo without a default uses its own config",
+			repoMilestone: map[string]Milestone{
+				"org/repo": {MaintainersTeam: "release", AllowCreate: utilpointer.BoolPtr(true)},
+			},
+			expected: Milestone{MaintainersTeam: "release", AllowCreate: utilpointer.BoolPtr(true)},
+		},
+		{
+			name: "repo without config uses the config of its org",
+			repoMilestone: map[string]Milestone{
+				"":      defaults,
+				"org/*": {MaintainersFriendlyName: "Org Leads"},
+			},
+			expected: Milestone{MaintainersTeam: "leads", MaintainersFriendlyName: "Org Leads", ClearTeam: "admins", ConfirmMilestone: utilpointer.BoolPtr(true)},
+		},
+		{
+			name: "repo config takes precedence over the config of its org",
+			repoMilestone: map[string]Milestone{
+				"":         defaults,
+				"org/*":    {MaintainersTeam: "org-leads", MaintainersFriendlyName: "Org Leads", AllowCreate: utilpointer.BoolPtr(true)},
+				"org/repo": {MaintainersFriendlyName: "Release Team"},
+			},
+			expected: Milestone{MaintainersTeam: "org-leads", MaintainersFriendlyName: "Release Team", ClearTeam: "admins", ConfirmMilestone: utilpointer.BoolPtr(true), AllowCreate: utilpointer.BoolPtr(true)},
+		},
+		{
+			name: "repo turns off a boolean turned on by its org",
+			repoMilestone: map[string]Milestone{
+				"":         defaults,
+				"org/*":    {DryRun: utilpointer.BoolPtr(true), AllowCreate: utilpointer.BoolPtr(true)},
+				"org/repo": {DryRun: utilpointer.BoolPtr(false)},
+			},
+			expected: Milestone{MaintainersTeam: "leads", MaintainersFriendlyName: "SIG Leads", ClearTeam: "admins", ConfirmMilestone: utilpointer.BoolPtr(true), AllowCreate: utilpointer.BoolPtr(true), DryRun: utilpointer.BoolPtr(false)},
+		},
+		{
+			name: "config of another org is ignored",
+			repoMilestone: map[string]Milestone{
+				"":            defaults,
+				"other-org/*": {MaintainersFriendlyName: "Other Leads"},
+			},
+			expected: defaults,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := MilestoneFor(tc.repoMilestone, "org", "repo")
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("unexpected milestone config (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnauthorizedMessage(t *testing.T) {
+	info := UnauthorizedInfo{Org: "org", Teams: []string{"leads"}, TeamLinks: "the leads team", Login: "user"}
+	testCases := []struct {
+		name        string
+		message     string
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name:     "default message",
+			expected: "default",
+		},
+		{
+			name:     "custom message",
+			message:  "@{{.Login}}, see https://example.com/{{.Org}}/milestones to join {{.TeamLinks}}.",
+			expected: "@user, see https://example.com/org/milestones to join the leads team.",
+		},
+		{
+			name:        "default message when the custom one is invalid",
+			message:     "Ask {{.FriendlyName",
+			expected:    "default",
+			expectedErr: true,
+		},
+		{
+			name:        "default message when the custom one fails to render",
+			message:     "Ask {{.Team}}",
+			expected:    "default",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := UnauthorizedMessage(Milestone{UnauthorizedMessage: tc.message}, info, "default")
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected an error: %t, got %v", tc.expectedErr, err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResolveMilestoneTeamRefs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		teamRefs      map[string]map[string]string
+		milestones    map[string]Milestone
+		expectedTeams map[string]string
+		expectedErr   string
+	}{
+		{
+			name:          "reference defined for the org",
+			teamRefs:      map[string]map[string]string{"org": {"release-team": "release-managers"}},
+			milestones:    map[string]Milestone{"org/repo": {MaintainersTeamRef: "release-team"}, "org/other": {MaintainersTeamRef: "release-team"}},
+			expectedTeams: map[string]string{"org/repo": "release-managers", "org/other": "release-managers"},
+		},
+		{
+			name:          "reference defined for all orgs",
+			teamRefs:      map[string]map[string]string{"": {"release-team": "release-managers"}, "org": {"release-team": "org-release-managers"}},
+			milestones:    map[string]Milestone{"": {MaintainersTeamRef: "release-team"}, "org/repo": {MaintainersTeamRef: "release-team"}, "other/repo": {MaintainersTeamRef: "release-team"}},
+			expectedTeams: map[string]string{"": "release-managers", "org/repo": "org-release-managers", "other/repo": "release-managers"},
+		},
+		{
+			name:          "team without a reference",
+			milestones:    map[string]Milestone{"org/repo": {MaintainersTeam: "leads"}},
+			expectedTeams: map[string]string{"org/repo": "leads"},
+		},
+		{
+			name:        "undefined reference",
+			teamRefs:    map[string]map[string]string{"other": {"release-team": "release-managers"}},
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeamRef: "release-team"}},
+			expectedErr: `repo_milestone of "org/repo" refers to the team "release-team", which is not defined in milestone_team_refs`,
+		},
+		{
+			name:        "both team and reference",
+			teamRefs:    map[string]map[string]string{"org": {"release-team": "release-managers"}},
+			milestones:  map[string]Milestone{"org/repo": {MaintainersTeam: "leads", MaintainersTeamRef: "release-team"}},
+			expectedErr: `repo_milestone of "org/repo" configures both maintainers_team and maintainers_team_ref: only one of them may be set`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pc := &Configuration{MilestoneTeamRefs: tc.teamRefs, RepoMilestone: tc.milestones}
+			var actualErr string
+			if err := resolveMilestoneTeamRefs(pc); err != nil {
+				actualErr = err.Error()
+			}
+			if actualErr != tc.expectedErr {
+				t.Fatalf("expected error %q, got %q", tc.expectedErr, actualErr)
+			}
+			if tc.expectedErr != "" {
+				return
+			}
+			for name, expected := range tc.expectedTeams {
+				if actual := pc.RepoMilestone[name].MaintainersTeam; actual != expected {
+					t.Errorf("expected the maintainers team of %q to be %q, got %q", name, expected, actual)
+				}
+			}
+		})
+	}
+}
+
 func TestConfigUpdaterResolve(t *testing.T) {
 	testCases := []struct {
 		name           string
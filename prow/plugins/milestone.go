@@ -0,0 +1,354 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+var codeFenceRegex = regexp.MustCompile("^[ \t]*(?:```|~~~)")
+
+// StripQuotedText returns the body of a comment without its blockquotes and
+// the lines of its fenced code blocks, so that the commands quoted from other
+// comments or shown as examples aren't acted upon by the milestone plugins.
+// An unterminated code block extends to the end of the comment.
+func StripQuotedText(body string) string {
+	var lines []string
+	inBlock := false
+	for _, line := range strings.Split(body, "\n") {
+		if codeFenceRegex.MatchString(line) {
+			inBlock = !inBlock
+			continue
+		}
+		if !inBlock && !strings.HasPrefix(strings.TrimSpace(line), ">") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MilestoneMaintainersClient is the subset of the GitHub client needed to
+// determine the milestone maintainers.
+type MilestoneMaintainersClient interface {
+	ListTeams(org string) ([]github.Team, error)
+	ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error)
+	IsMember(org, user string) (bool, error)
+}
+
+// UnauthorizedMessage returns the message rejecting a user who isn't a
+// milestone maintainer, rendered from the UnauthorizedMessage template of
+// milestone, or defaultMessage if there is no template or it fails to render.
+func UnauthorizedMessage(milestone Milestone, info UnauthorizedInfo, defaultMessage string) (string, error) {
+	if milestone.UnauthorizedMessage == "" {
+		return defaultMessage, nil
+	}
+	unauthorizedTemplate, err := template.New("unauthorized_message").Parse(milestone.UnauthorizedMessage)
+	if err != nil {
+		return defaultMessage, fmt.Errorf("failed to parse the unauthorized message: %w", err)
+	}
+	var msg strings.Builder
+	if err := unauthorizedTemplate.Execute(&msg, info); err != nil {
+		return defaultMessage, fmt.Errorf("failed to render the unauthorized message: %w", err)
+	}
+	return msg.String(), nil
+}
+
+// TeamNotFoundError is returned when a configured milestone maintainers team
+// does not exist in the org, which usually means the config is wrong.
+type TeamNotFoundError struct {
+	Org  string
+	Team string
+}
+
+func (e TeamNotFoundError) Error() string {
+	return fmt.Sprintf("configured milestone maintainers team `%s` does not exist in org `%s`", e.Team, e.Org)
+}
+
+// MissingPermissionError is returned when GitHub forbids looking up the
+// members of the org or of its teams, which usually means that the GitHub App
+// of Prow lacks the members:read permission.
+type MissingPermissionError struct {
+	Org string
+	Err error
+}
+
+func (e MissingPermissionError) Error() string {
+	return fmt.Sprintf("the members of org `%s` and of its teams can't be read, please make sure that the GitHub App is granted the members:read permission: %v", e.Org, e.Err)
+}
+
+func (e MissingPermissionError) Unwrap() error {
+	return e.Err
+}
+
+// TeamSlug returns the slug of the team referred to by team, which is either a
+// team slug or the path of a nested team from its top-level ancestor, e.g.
+// `parent-team/child-team`. Team slugs are unique within an org, so a nested
+// team is identified by the last slug of its path.
+func TeamSlug(team string) string {
+	return team[strings.LastIndex(team, "/")+1:]
+}
+
+// TeamMembershipClient is implemented by GitHub clients which can check whether
+// a user is a member of a team without listing all the members of the team.
+type TeamMembershipClient interface {
+	TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error)
+}
+
+// TeamRole returns the role which the members of the milestone maintainers
+// teams must have to be milestone maintainers.
+func (m Milestone) TeamRole() string {
+	if m.RequiresTeamMaintainerRole() {
+		return github.RoleMaintainer
+	}
+	return github.RoleAll
+}
+
+// Authorizer decides whether a user may use the commands of the milestone
+// plugins which require the milestone configured for the repo to authorize
+// them, e.g. to set the milestone or to apply a status. It allows custom
+// authorization, e.g. with LDAP, in place of the default TeamAuthorizer.
+type Authorizer interface {
+	IsAuthorized(org, user string, milestone Milestone) (bool, error)
+}
+
+// TeamAuthorizer authorizes the milestone maintainers, as determined by
+// IsMilestoneMaintainer.
+type TeamAuthorizer struct {
+	Client MilestoneMaintainersClient
+}
+
+// IsAuthorized returns whether user is a milestone maintainer of milestone.
+func (a *TeamAuthorizer) IsAuthorized(org, user string, milestone Milestone) (bool, error) {
+	return IsMilestoneMaintainer(a.Client, milestone, org, user)
+}
+
+// IsMilestoneMaintainer returns whether login is a member of any of the
+// milestone maintainers teams configured in milestone, or of org if the
+// configuration authorizes all org members. The allowed bots are milestone
+// maintainers regardless of their memberships.
+func IsMilestoneMaintainer(gc MilestoneMaintainersClient, milestone Milestone, org, login string) (bool, error) {
+	login = github.NormLogin(login)
+	for _, bot := range milestone.AllowedBots {
+		if github.NormLogin(bot) == login {
+			return true, nil
+		}
+	}
+	found, err := isMilestoneMaintainer(gc, milestone, org, login)
+	if github.IsForbidden(err) {
+		return false, MissingPermissionError{Org: org, Err: err}
+	}
+	return found, err
+}
+
+func isMilestoneMaintainer(gc MilestoneMaintainersClient, milestone Milestone, org, login string) (bool, error) {
+	if milestone.AuthorizesOrgMembers() {
+		return gc.IsMember(org, login)
+	}
+	teams, err := maintainersTeams(gc, milestone, org)
+	if err != nil {
+		return false, err
+	}
+	found, err := isTeamMember(gc, org, teams, login, milestone.TeamRole())
+	if renamed, ok := renamedTeamFallback(gc, milestone, org, teams, err); ok {
+		return isTeamMember(gc, org, renamed, login, milestone.TeamRole())
+	}
+	return found, err
+}
+
+// HasNoMaintainers returns whether none of the milestone maintainers teams
+// configured in milestone has any members, in which case nobody is authorized
+// to use the milestone commands.
+func HasNoMaintainers(gc MilestoneMaintainersClient, milestone Milestone, org string) (bool, error) {
+	if milestone.AuthorizesOrgMembers() {
+		return false, nil
+	}
+	teams, err := maintainersTeams(gc, milestone, org)
+	if err != nil {
+		return false, err
+	}
+	logins, err := teamMemberLogins(gc, org, teams, milestone.TeamRole())
+	if renamed, ok := renamedTeamFallback(gc, milestone, org, teams, err); ok {
+		logins, err = teamMemberLogins(gc, org, renamed, milestone.TeamRole())
+	}
+	return len(logins) == 0, err
+}
+
+// MilestoneMaintainerLogins returns the sorted logins of the members of the
+// milestone maintainers teams configured in milestone.
+func MilestoneMaintainerLogins(gc MilestoneMaintainersClient, milestone Milestone, org string) ([]string, error) {
+	teams, err := maintainersTeams(gc, milestone, org)
+	if err != nil {
+		return nil, err
+	}
+	logins, err := teamMemberLogins(gc, org, teams, milestone.TeamRole())
+	if renamed, ok := renamedTeamFallback(gc, milestone, org, teams, err); ok {
+		return teamMemberLogins(gc, org, renamed, milestone.TeamRole())
+	}
+	return logins, err
+}
+
+// teamMemberLogins returns the sorted logins of the members of teams with the role.
+func teamMemberLogins(gc MilestoneMaintainersClient, org string, teams []string, role string) ([]string, error) {
+	seen := map[string]bool{}
+	var logins []string
+	for _, team := range teams {
+		members, err := gc.ListTeamMembersBySlug(org, TeamSlug(team), role)
+		if github.IsNotFound(err) {
+			return nil, TeamNotFoundError{Org: org, Team: team}
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if login := github.NormLogin(member.Login); !seen[login] {
+				seen[login] = true
+				logins = append(logins, member.Login)
+			}
+		}
+	}
+	sort.Strings(logins)
+	return logins, nil
+}
+
+// renamedTeamFallback returns teams with the MaintainersTeam replaced by the
+// team with the MaintainersID, if err is that the MaintainersTeam wasn't found
+// and both are configured, e.g. because the team was renamed since.
+func renamedTeamFallback(gc MilestoneMaintainersClient, milestone Milestone, org string, teams []string, err error) ([]string, bool) {
+	var notFound TeamNotFoundError
+	if milestone.MaintainersID == 0 || milestone.MaintainersTeam == "" || !errors.As(err, &notFound) || notFound.Team != milestone.MaintainersTeam {
+		return nil, false
+	}
+	slug, idErr := teamIDSlugs.resolve(gc, org, milestone.MaintainersID)
+	if idErr != nil {
+		logrus.WithError(idErr).WithField("org", org).Warnf("The milestone maintainers team %q wasn't found, nor the team with ID %d.", milestone.MaintainersTeam, milestone.MaintainersID)
+		return nil, false
+	}
+	logrus.WithField("org", org).Warnf("The milestone maintainers team %q wasn't found, using the team %q with ID %d instead. The team may have been renamed, so maintainers_team may need to be updated.", milestone.MaintainersTeam, slug, milestone.MaintainersID)
+	renamed := make([]string, 0, len(teams))
+	for _, team := range teams {
+		if team == milestone.MaintainersTeam {
+			team = slug
+		}
+		renamed = append(renamed, team)
+	}
+	return renamed, true
+}
+
+// maintainersTeams returns the milestone maintainers teams configured in
+// milestone, resolving the deprecated team ID if no team is configured by slug.
+func maintainersTeams(gc MilestoneMaintainersClient, milestone Milestone, org string) ([]string, error) {
+	teams := milestone.MaintainersTeamSlugs()
+	if len(teams) == 0 && milestone.MaintainersID != 0 {
+		slug, err := teamIDSlugs.resolve(gc, org, milestone.MaintainersID)
+		if err != nil {
+			return nil, err
+		}
+		teams = []string{slug}
+	}
+	return teams, nil
+}
+
+// IsParentTeamMember returns whether login is a member of the parent team of
+// any of the milestone maintainers teams configured in milestone. The parent
+// of a team configured by its path is taken from the path, and otherwise
+// looked up in the teams of org.
+func IsParentTeamMember(gc MilestoneMaintainersClient, milestone Milestone, org, login string) (bool, error) {
+	var parents []string
+	var orgTeams []github.Team
+	for _, team := range milestone.MaintainersTeamSlugs() {
+		if i := strings.LastIndex(team, "/"); i >= 0 {
+			parents = append(parents, team[:i])
+			continue
+		}
+		if orgTeams == nil {
+			var err error
+			if orgTeams, err = gc.ListTeams(org); err != nil {
+				return false, err
+			}
+		}
+		for _, orgTeam := range orgTeams {
+			if orgTeam.Slug == team && orgTeam.Parent != nil {
+				parents = append(parents, orgTeam.Parent.Slug)
+			}
+		}
+	}
+	return isTeamMember(gc, org, parents, github.NormLogin(login), github.RoleAll)
+}
+
+// checkTeamsExist returns a TeamNotFoundError for the first of teams which
+// does not exist in org.
+func checkTeamsExist(gc MilestoneMaintainersClient, org string, teams []string) error {
+	if len(teams) == 0 {
+		return nil
+	}
+	orgTeams, err := gc.ListTeams(org)
+	if err != nil {
+		return err
+	}
+	slugs := map[string]bool{}
+	for _, orgTeam := range orgTeams {
+		slugs[orgTeam.Slug] = true
+	}
+	for _, team := range teams {
+		if !slugs[TeamSlug(team)] {
+			return TeamNotFoundError{Org: org, Team: team}
+		}
+	}
+	return nil
+}
+
+// isTeamMember returns whether login has the role in any of teams.
+func isTeamMember(gc MilestoneMaintainersClient, org string, teams []string, login, role string) (bool, error) {
+	// the membership check can't tell the role of the member
+	if checker, ok := gc.(TeamMembershipClient); ok && role == github.RoleAll {
+		for _, team := range teams {
+			member, err := checker.TeamBySlugHasMember(org, TeamSlug(team), login)
+			if err != nil {
+				return false, err
+			}
+			if member {
+				return true, nil
+			}
+		}
+		// the membership check can't tell a missing team from a non-member
+		return false, checkTeamsExist(gc, org, teams)
+	}
+	for _, team := range teams {
+		members, err := gc.ListTeamMembersBySlug(org, TeamSlug(team), role)
+		if github.IsNotFound(err) {
+			return false, TeamNotFoundError{Org: org, Team: team}
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, person := range members {
+			if github.NormLogin(person.Login) == login {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// ApplyDefaultMilestone sets the current_milestone configured for org/repo on
+// the issue or PR number on behalf of login, so that other plugins, e.g. the
+// approve plugin, can set the milestone without a /milestone command. As with
+// the command, login must be a milestone maintainer, or an owner of the files
+// changed by the PR, the title_pattern, the milestone freezes and the change
+// cooldown apply, and the change can be undone with `/milestone undo`. It
+// returns whether the milestone was set, which it isn't if login isn't
+// authorized, no current_milestone is configured, the issue or PR already has
+// a milestone, or the repo is dry-run or audit-only.
+func ApplyDefaultMilestone(pc plugins.Agent, org, repo string, number int, login string) (bool, error) {
+	milestone := plugins.MilestoneFor(pc.PluginConfig.RepoMilestone, org, repo)
+	if milestone.CurrentMilestone == "" {
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), handleTimeout)
+	defer cancel()
+	return applyDefaultMilestone(ctx, pluginClient(ctx, pc, milestone), pc.Authorizer, pc.Logger, pc.PluginConfig.RepoMilestone, org, repo, number, login)
+}
+
+// applyDefaultMilestone applies the default milestone, authorizing login with
+// authorizer, or with a plugins.TeamAuthorizer if it is nil.
+func applyDefaultMilestone(ctx context.Context, gc githubClient, authorizer plugins.Authorizer, log *logrus.Entry, repoMilestone map[string]plugins.Milestone, org, repo string, number int, login string) (bool, error) {
+	milestone := plugins.MilestoneFor(repoMilestone, org, repo)
+	if milestone.CurrentMilestone == "" {
+		return false, nil
+	}
+	issue, err := gc.GetIssue(ctx, org, repo, number)
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s/%s#%d: %w", org, repo, number, err)
+	}
+	// the change is made as if login had commented `/milestone` on the issue or PR
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Repo:   github.Repo{Owner: github.User{Login: org}, Name: repo},
+		Number: number,
+		IsPR:   issue.IsPullRequest(),
+		User:   github.User{Login: login},
+	}
+	c := newCommand(ctx, gc, authorizer, log, e, milestone)
+	if err := c.resolveMaintainers(); err != nil {
+		return false, fmt.Errorf("failed to list the files changed by %s/%s#%d: %w", org, repo, number, err)
+	}
+	found, err := c.authorizer.IsAuthorized(org, login, c.milestone)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		countCommand(org, repo, outcomeUnauthorized)
+		log.Debugf("Not setting the default milestone on behalf of %s, who isn't a milestone maintainer.", login)
+		return false, nil
+	}
+	previous := issue.Milestone.Title
+	if previous != "" {
+		// an explicitly set milestone takes precedence over the default
+		return false, nil
+	}
+	milestones, err := c.gc.ListMilestonesByState(ctx, org, repo, github.MilestoneStateOpen)
+	if err != nil {
+		return false, fmt.Errorf("failed to list the milestones in the %s/%s repo: %w", org, repo, err)
+	}
+	milestoneNumber, ok := ResolveMilestone(milestones, milestone.CurrentMilestone)
+	if !ok {
+		return false, fmt.Errorf("the current milestone %q is not an open milestone of the %s/%s repo", milestone.CurrentMilestone, org, repo)
+	}
+	title := milestoneTitle(milestones, milestoneNumber)
+	log = log.WithFields(logrus.Fields{"milestone": title, "milestoneNumber": milestoneNumber})
+	if outcome, msg, err := c.rejection(title); err != nil {
+		return false, err
+	} else if msg != "" {
+		countCommand(org, repo, outcome)
+		log.WithField("reason", msg).Infof("Not setting the default milestone on behalf of %s.", login)
+		return false, nil
+	}
+	if milestone.IsDryRun() {
+		log.Infof("Not setting the default milestone on behalf of %s, as the repo is dry-run.", login)
+		return false, nil
+	}
+	if remaining := changeCooldowns.remaining(issueTarget(org, repo, number)); remaining > 0 {
+		log.Infof("Not setting the default milestone on behalf of %s, as the milestone can't be changed for another %s.", login, formatCooldown(remaining))
+		return false, nil
+	}
+	if err := c.applyMilestone(log, milestoneNumber, title, previous, true); err != nil {
+		return false, fmt.Errorf("failed to set the milestone of %s/%s#%d: %w", org, repo, number, err)
+	}
+	// the change is only audited in the audit-only repos
+	return !milestone.IsAuditOnly(), nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	utilpointer "k8s.io/utils/pointer"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestApplyDefaultMilestone(t *testing.T) {
+	testcases := []struct {
+		name              string
+		login             string
+		currentMilestone  string
+		previousMilestone github.Milestone
+		auditOnly         bool
+		dryRun            bool
+		titlePattern      string
+		changedFiles      []string
+		expectedApplied   bool
+		expectedMilestone int
+		expectedAudit     bool
+		expectErr         bool
+	}{
+		{
+			name:              "Set the default milestone on behalf of a maintainer",
+			login:             "sig-lead",
+			currentMilestone:  "v1.0",
+			expectedApplied:   true,
+			expectedMilestone: 1,
+		},
+		{
+			name:             "Don't set the default milestone on behalf of a non-maintainer",
+			login:            "sig-follow",
+			currentMilestone: "v1.0",
+		},
+		{
+			name:  "Don't set a milestone without a default milestone",
+			login: "sig-lead",
+		},
+		{
+			name:              "Don't replace the milestone of the issue",
+			login:             "sig-lead",
+			currentMilestone:  "v1.0",
+			previousMilestone: github.Milestone{Title: "v2.0", Number: 2},
+			expectedMilestone: 2,
+		},
+		{
+			name:             "Only audit the default milestone in an audit-only repo",
+			login:            "sig-lead",
+			currentMilestone: "v1.0",
+			auditOnly:        true,
+			expectedAudit:    true,
+		},
+		{
+			name:             "Don't set the default milestone in a dry-run repo",
+			login:            "sig-lead",
+			currentMilestone: "v1.0",
+			dryRun:           true,
+		},
+		{
+			name:             "Don't set a default milestone not matching the title pattern",
+			login:            "sig-lead",
+			currentMilestone: "v1.0",
+			titlePattern:     `^release-`,
+		},
+		{
+			name:              "Set the default milestone on behalf of an owner of the changed files",
+			login:             "default-sig-lead",
+			currentMilestone:  "v1.0",
+			changedFiles:      []string{"staging/src/file.go"},
+			expectedApplied:   true,
+			expectedMilestone: 1,
+		},
+		{
+			name:             "Don't set the default milestone on behalf of a maintainer not owning the changed files",
+			login:            "sig-lead",
+			currentMilestone: "v1.0",
+			changedFiles:     []string{"staging/src/file.go"},
+		},
+		{
+			name:             "Fail when the default milestone doesn't exist",
+			login:            "sig-lead",
+			currentMilestone: "v3.0",
+			expectErr:        true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sink bytes.Buffer
+			defer plugins.SetAuditSink(plugins.SetAuditSink(&sink))
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1, "v2.0": 2}
+			fakeClient.Issues[1] = &github.Issue{Number: 1, Milestone: tc.previousMilestone}
+			fakeClient.Milestone = tc.previousMilestone.Number
+			milestone := plugins.Milestone{MaintainersTeam: "leads", CurrentMilestone: tc.currentMilestone, AuditOnly: utilpointer.BoolPtr(tc.auditOnly), DryRun: utilpointer.BoolPtr(tc.dryRun)}
+			if tc.changedFiles != nil {
+				fakeClient.Issues[1].PullRequest = &struct{}{}
+				for _, file := range tc.changedFiles {
+					fakeClient.PullRequestChanges[1] = append(fakeClient.PullRequestChanges[1], github.PullRequestChange{Filename: file})
+				}
+				milestone.PathMaintainers = []plugins.PathMaintainers{{Paths: []string{"staging/**/*"}, MaintainersTeams: []string{"admins"}}}
+			}
+			if tc.titlePattern != "" {
+				milestone.TitlePattern, milestone.TitleRe = tc.titlePattern, regexp.MustCompile(tc.titlePattern)
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": milestone}
+
+			applied, err := applyDefaultMilestone(context.Background(), &contextClient{client: fakeClient}, nil, logrus.WithField("plugin", pluginName), repoMilestone, "org", "repo", 1, tc.login)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expected an error: %t, got %v.", tc.expectErr, err)
+			}
+			if applied != tc.expectedApplied {
+				t.Errorf("Expected the milestone to be applied: %t, got %t.", tc.expectedApplied, applied)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if audited := strings.Contains(sink.String(), plugins.AuditSetMilestone); audited != tc.expectedAudit {
+				t.Errorf("Expected the milestone to be audited: %t, got %t.", tc.expectedAudit, audited)
+			}
+		})
+	}
+}
+
+func TestApplyDefaultMilestoneUndo(t *testing.T) {
+	defer func(previous *undoStore) { milestoneUndos = previous }(milestoneUndos)
+	milestoneUndos = newUndoStore(time.Now)
+	defer func(previous *cooldownTracker) { changeCooldowns = previous }(changeCooldowns)
+	changeCooldowns = newCooldownTracker(time.Now)
+	fakeClient := &milestoneIssueClient{FakeClient: fakegithub.NewFakeClient()}
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", CurrentMilestone: "v1.0", ChangeCooldownDuration: time.Hour}}
+
+	if _, err := applyDefaultMilestone(context.Background(), &contextClient{client: fakeClient}, nil, logrus.WithField("plugin", pluginName), repoMilestone, "org", "repo", 1, "sig-lead"); err != nil {
+		t.Fatalf("Unexpected error: %v.", err)
+	}
+	if previous, ok := milestoneUndos.get(issueTarget("org", "repo", 1)); !ok || previous != "" {
+		t.Errorf("Expected setting the default milestone to be undoable, got %q, %t.", previous, ok)
+	}
+	if remaining := changeCooldowns.remaining(issueTarget("org", "repo", 1)); remaining == 0 {
+		t.Error("Expected setting the default milestone to start the cooldown.")
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// auditingClient records the mutating calls of the plugin in the audit sink
+// instead of making them, for the repos configured to be audit-only. The calls
+// reading from GitHub are made as usual.
+type auditingClient struct {
+	githubClient
+	actor string
+}
+
+func (c *auditingClient) audit(action, target, value string) {
+	plugins.Audit(plugins.AuditRecord{Plugin: pluginName, Actor: c.actor, Action: action, Target: target, Value: value})
+}
+
+func issueTarget(org, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", org, repo, number)
+}
+
+func (c *auditingClient) CreateComment(ctx context.Context, owner, repo string, number int, comment string) error {
+	c.audit(plugins.AuditComment, issueTarget(owner, repo, number), comment)
+	return nil
+}
+
+func (c *auditingClient) CreateCommentReaction(ctx context.Context, org, repo string, id int, reaction string) error {
+	c.audit(plugins.AuditReaction, fmt.Sprintf("%s/%s comment %d", org, repo, id), reaction)
+	return nil
+}
+
+func (c *auditingClient) ClearMilestone(ctx context.Context, org, repo string, num int) error {
+	c.audit(plugins.AuditClearMilestone, issueTarget(org, repo, num), "")
+	return nil
+}
+
+func (c *auditingClient) SetMilestone(ctx context.Context, org, repo string, issueNum, milestoneNum int) error {
+	c.audit(plugins.AuditSetMilestone, issueTarget(org, repo, issueNum), strconv.Itoa(milestoneNum))
+	return nil
+}
+
+// CreateMilestone returns 0 as the number of the milestone it didn't create.
+func (c *auditingClient) CreateMilestone(ctx context.Context, org, repo, title string) (int, error) {
+	c.audit(plugins.AuditCreateMilestone, fmt.Sprintf("%s/%s", org, repo), title)
+	return 0, nil
+}
+
+func (c *auditingClient) AddLabel(ctx context.Context, org, repo string, number int, label string) error {
+	c.audit(plugins.AuditAddLabel, issueTarget(org, repo, number), label)
+	return nil
+}
+
+func (c *auditingClient) RemoveLabel(ctx context.Context, org, repo string, number int, label string) error {
+	c.audit(plugins.AuditRemoveLabel, issueTarget(org, repo, number), label)
+	return nil
+}
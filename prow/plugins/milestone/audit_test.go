@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	utilpointer "k8s.io/utils/pointer"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestAuditOnly(t *testing.T) {
+	testcases := []struct {
+		name            string
+		body            string
+		current         int
+		expectedActions []string
+	}{
+		{
+			name:            "Record the milestone which would have been set",
+			body:            "/milestone v1.0",
+			expectedActions: []string{plugins.AuditSetMilestone, plugins.AuditComment},
+		},
+		{
+			name:            "Record the milestone which would have been cleared",
+			body:            "/milestone clear",
+			current:         1,
+			expectedActions: []string{plugins.AuditClearMilestone, plugins.AuditComment},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sink bytes.Buffer
+			defer plugins.SetAuditSink(plugins.SetAuditSink(&sink))
+
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Milestone = tc.current
+			fakeClient.Issues = map[int]*github.Issue{1: {Number: 1}}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", AuditOnly: utilpointer.BoolPtr(true), ConfirmMilestone: utilpointer.BoolPtr(true)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.current {
+				t.Errorf("Expected the milestone to stay %d, but it is %d.", tc.current, fakeClient.Milestone)
+			}
+			if len(fakeClient.IssueComments[1]) != 0 {
+				t.Errorf("Expected no comments, but got %v.", fakeClient.IssueComments[1])
+			}
+
+			var actions []string
+			decoder := json.NewDecoder(&sink)
+			for decoder.More() {
+				var record plugins.AuditRecord
+				if err := decoder.Decode(&record); err != nil {
+					t.Fatalf("Error decoding the audit record: %v.", err)
+				}
+				if record.Actor != "sig-lead" {
+					t.Errorf("Expected the actor of %s to be sig-lead, but got %q.", record.Action, record.Actor)
+				}
+				if record.Target != "org/repo#1" {
+					t.Errorf("Expected the target of %s to be org/repo#1, but got %q.", record.Action, record.Target)
+				}
+				actions = append(actions, record.Action)
+			}
+			if !reflect.DeepEqual(tc.expectedActions, actions) {
+				t.Errorf("Expected the audited actions %v, but got %v.", tc.expectedActions, actions)
+			}
+		})
+	}
+}
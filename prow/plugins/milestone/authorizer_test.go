@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// usersAuthorizer authorizes a fixed set of users, as e.g. an LDAP group would,
+// and records the milestones it authorizes users for.
+type usersAuthorizer struct {
+	users      map[string]bool
+	milestones []plugins.Milestone
+}
+
+func (a *usersAuthorizer) IsAuthorized(org, user string, milestone plugins.Milestone) (bool, error) {
+	a.milestones = append(a.milestones, milestone)
+	return a.users[user], nil
+}
+
+func TestCustomAuthorizer(t *testing.T) {
+	testcases := []struct {
+		name              string
+		commenter         string
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Set the milestone for a user the authorizer allows",
+			commenter:         "ldap-user",
+			expectedMilestone: 1,
+		},
+		{
+			name:            "Refuse a milestone maintainer the authorizer denies",
+			commenter:       "sig-lead",
+			expectedComment: fmt.Sprintf(mustBeAuthorized, teamLinks("org", []string{"leads"}), plugins.Milestone{MaintainersTeam: "leads"}.FriendlyName()),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+			authorizer := &usersAuthorizer{users: map[string]bool{"ldap-user": true}}
+
+			if err := handleWithAuthorizer(context.Background(), &contextClient{client: fakeClient}, authorizer, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if len(authorizer.milestones) != 1 || authorizer.milestones[0].MaintainersTeam != "leads" {
+				t.Errorf("Expected the user to be authorized once for the leads team, got %+v.", authorizer.milestones)
+			}
+			var comments []string
+			for _, c := range fakeClient.IssueComments[1] {
+				comments = append(comments, c.Body)
+			}
+			if tc.expectedComment == "" {
+				if len(comments) != 0 {
+					t.Errorf("Expected no comments, got %q.", comments)
+				}
+				return
+			}
+			if len(comments) != 1 || !strings.Contains(comments[0], tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %q.", tc.expectedComment, comments)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// defaultMilestonesCacheTTL is used when no milestones_cache_ttl is configured.
+const defaultMilestonesCacheTTL = 60 * time.Second
+
+var milestonesCache = newMilestoneCache(time.Now)
+
+// milestoneCache holds the milestones of each repo, keyed by org/repo and state.
+type milestoneCache struct {
+	lock    sync.Mutex
+	now     func() time.Time
+	entries map[string]milestoneCacheEntry
+}
+
+type milestoneCacheEntry struct {
+	milestones []github.Milestone
+	expiry     time.Time
+}
+
+func newMilestoneCache(now func() time.Time) *milestoneCache {
+	return &milestoneCache{now: now, entries: map[string]milestoneCacheEntry{}}
+}
+
+func (c *milestoneCache) get(org, repo, state string) ([]github.Milestone, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[fmt.Sprintf("%s/%s/%s", org, repo, state)]
+	if !ok || !c.now().Before(entry.expiry) {
+		return nil, false
+	}
+	return entry.milestones, true
+}
+
+// invalidate drops the milestones of a repo of all states.
+func (c *milestoneCache) invalidate(org, repo string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, state := range []string{github.MilestoneStateOpen, github.MilestoneStateClosed, github.MilestoneStateAll} {
+		delete(c.entries, fmt.Sprintf("%s/%s/%s", org, repo, state))
+	}
+}
+
+func (c *milestoneCache) set(org, repo, state string, milestones []github.Milestone, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[fmt.Sprintf("%s/%s/%s", org, repo, state)] = milestoneCacheEntry{milestones: milestones, expiry: c.now().Add(ttl)}
+}
+
+// milestonesCachingClient serves ListMilestonesByState from the cache while the
+// cached milestones of a repo are younger than the ttl.
+type milestonesCachingClient struct {
+	plainGitHubClient
+	cache *milestoneCache
+	ttl   time.Duration
+}
+
+func (c *milestonesCachingClient) ListMilestonesByState(org, repo, state string) ([]github.Milestone, error) {
+	if milestones, ok := c.cache.get(org, repo, state); ok {
+		return milestones, nil
+	}
+	milestones, err := c.plainGitHubClient.ListMilestonesByState(org, repo, state)
+	if err != nil {
+		return nil, err
+	}
+	ttl := c.ttl
+	if ttl == 0 {
+		ttl = defaultMilestonesCacheTTL
+	}
+	c.cache.set(org, repo, state, milestones, ttl)
+	return milestones, nil
+}
+
+// CreateMilestone invalidates the cached milestones of the repo, so that the
+// new milestone is listed right away.
+func (c *milestonesCachingClient) CreateMilestone(org, repo, title string) (int, error) {
+	number, err := c.plainGitHubClient.CreateMilestone(org, repo, title)
+	if err != nil {
+		return 0, err
+	}
+	c.cache.invalidate(org, repo)
+	return number, nil
+}
+
+// teamsCachingClient serves the team membership lookups from the cache of the
+// TeamMembershipResolver shared by the milestone plugins.
+type teamsCachingClient struct {
+	plainGitHubClient
+	teams *plugins.TeamMembershipCachingClient
+}
+
+func (c *teamsCachingClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	return c.teams.ListTeamMembersBySlug(org, teamSlug, role)
+}
+
+func (c *teamsCachingClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	return c.teams.TeamBySlugHasMember(org, teamSlug, memberLogin)
+}
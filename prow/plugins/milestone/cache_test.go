@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+type countingClient struct {
+	*fakegithub.FakeClient
+	lock  sync.Mutex
+	calls int
+}
+
+func (c *countingClient) ListMilestonesByState(org, repo, state string) ([]github.Milestone, error) {
+	c.lock.Lock()
+	c.calls++
+	c.lock.Unlock()
+	return c.FakeClient.ListMilestonesByState(org, repo, state)
+}
+
+func TestMilestonesCachingClient(t *testing.T) {
+	now := time.Now()
+	cache := newMilestoneCache(func() time.Time { return now })
+	fakeClient := &countingClient{FakeClient: fakegithub.NewFakeClient()}
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	gc := &milestonesCachingClient{plainGitHubClient: fakeClient, cache: cache, ttl: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		milestones, err := gc.ListMilestonesByState("org", "repo", github.MilestoneStateOpen)
+		if err != nil {
+			t.Fatalf("Unexpected error listing milestones: %v", err)
+		}
+		if len(milestones) != 1 {
+			t.Errorf("Expected 1 milestone, got %d", len(milestones))
+		}
+	}
+	if fakeClient.calls != 1 {
+		t.Errorf("Expected a second call within the ttl to be served from the cache, but the client was called %d times", fakeClient.calls)
+	}
+
+	if _, err := gc.ListMilestonesByState("org", "other-repo", github.MilestoneStateOpen); err != nil {
+		t.Fatalf("Unexpected error listing milestones: %v", err)
+	}
+	if fakeClient.calls != 2 {
+		t.Errorf("Expected milestones to be cached per repo, but the client was called %d times", fakeClient.calls)
+	}
+
+	if _, err := gc.ListMilestonesByState("org", "repo", github.MilestoneStateAll); err != nil {
+		t.Fatalf("Unexpected error listing milestones: %v", err)
+	}
+	if fakeClient.calls != 3 {
+		t.Errorf("Expected milestones to be cached per state, but the client was called %d times", fakeClient.calls)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := gc.ListMilestonesByState("org", "repo", github.MilestoneStateOpen); err != nil {
+		t.Fatalf("Unexpected error listing milestones: %v", err)
+	}
+	if fakeClient.calls != 4 {
+		t.Errorf("Expected a call after the ttl to hit the client, but the client was called %d times", fakeClient.calls)
+	}
+}
+
+func TestMilestonesCachingClientCreateMilestone(t *testing.T) {
+	cache := newMilestoneCache(time.Now)
+	fakeClient := &countingClient{FakeClient: fakegithub.NewFakeClient()}
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	gc := &milestonesCachingClient{plainGitHubClient: fakeClient, cache: cache, ttl: time.Minute}
+
+	if _, err := gc.ListMilestonesByState("org", "repo", github.MilestoneStateOpen); err != nil {
+		t.Fatalf("Unexpected error listing milestones: %v", err)
+	}
+	if _, err := gc.CreateMilestone("org", "repo", "v1.1"); err != nil {
+		t.Fatalf("Unexpected error creating a milestone: %v", err)
+	}
+	milestones, err := gc.ListMilestonesByState("org", "repo", github.MilestoneStateOpen)
+	if err != nil {
+		t.Fatalf("Unexpected error listing milestones: %v", err)
+	}
+	if len(milestones) != 2 {
+		t.Errorf("Expected the created milestone to be listed, got %v", milestones)
+	}
+	if fakeClient.calls != 2 {
+		t.Errorf("Expected creating a milestone to invalidate the cache, but the client was called %d times", fakeClient.calls)
+	}
+}
+
+func TestMilestonesCachingClientConcurrency(t *testing.T) {
+	cache := newMilestoneCache(time.Now)
+	fakeClient := &countingClient{FakeClient: fakegithub.NewFakeClient()}
+	gc := &milestonesCachingClient{plainGitHubClient: fakeClient, cache: cache}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := gc.ListMilestonesByState("org", "repo", github.MilestoneStateOpen); err != nil {
+				t.Errorf("Unexpected error listing milestones: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// membershipCountingClient counts the calls checking the membership of a team.
+type membershipCountingClient struct {
+	*fakegithub.FakeClient
+	calls int
+}
+
+func (c *membershipCountingClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	c.calls++
+	return c.FakeClient.TeamBySlugHasMember(org, teamSlug, memberLogin)
+}
+
+func TestSharedTeamMembershipResolver(t *testing.T) {
+	resolver := plugins.NewTeamMembershipResolver()
+	// the milestonestatus plugin checks the membership with its own client first
+	status := &membershipCountingClient{FakeClient: fakegithub.NewFakeClient()}
+	if _, err := plugins.IsMilestoneMaintainer(resolver.Client(status, 0), plugins.Milestone{MaintainersTeam: "leads"}, "org", "sig-lead"); err != nil {
+		t.Fatalf("Unexpected error checking the membership: %v.", err)
+	}
+
+	fakeClient := &membershipCountingClient{FakeClient: fakegithub.NewFakeClient()}
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	gc := &teamsCachingClient{plainGitHubClient: fakeClient, teams: resolver.Client(fakeClient, 0)}
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/milestone v1.0",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+	if err := handle(context.Background(), &contextClient{client: gc}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		t.Fatalf("Unexpected error from handle: %v.", err)
+	}
+	if fakeClient.Milestone != 1 {
+		t.Errorf("Expected milestone 1 to be set, got %d.", fakeClient.Milestone)
+	}
+	if status.calls != 1 || fakeClient.calls != 0 {
+		t.Errorf("Expected the membership to be checked once and then served from the shared cache, got %d and %d calls.", status.calls, fakeClient.calls)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+// contextClient adapts a GitHub client whose methods don't take a context to
+// githubClient. Calls made once the context is done fail with the error of the
+// context instead of reaching GitHub.
+type contextClient struct {
+	client plainGitHubClient
+}
+
+func (c *contextClient) CreateComment(ctx context.Context, owner, repo string, number int, comment string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.client.CreateComment(owner, repo, number, comment)
+}
+
+func (c *contextClient) CreateCommentReaction(ctx context.Context, org, repo string, id int, reaction string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.client.CreateCommentReaction(org, repo, id, reaction)
+}
+
+func (c *contextClient) ClearMilestone(ctx context.Context, org, repo string, num int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.client.ClearMilestone(org, repo, num)
+}
+
+func (c *contextClient) SetMilestone(ctx context.Context, org, repo string, issueNum, milestoneNum int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.client.SetMilestone(org, repo, issueNum, milestoneNum)
+}
+
+func (c *contextClient) ListTeams(ctx context.Context, org string) ([]github.Team, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.client.ListTeams(org)
+}
+
+func (c *contextClient) ListTeamMembersBySlug(ctx context.Context, org, teamSlug, role string) ([]github.TeamMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.client.ListTeamMembersBySlug(org, teamSlug, role)
+}
+
+func (c *contextClient) IsMember(ctx context.Context, org, user string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return c.client.IsMember(org, user)
+}
+
+func (c *contextClient) TeamBySlugHasMember(ctx context.Context, org string, teamSlug string, memberLogin string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return c.client.TeamBySlugHasMember(org, teamSlug, memberLogin)
+}
+
+func (c *contextClient) ListMilestonesByState(ctx context.Context, org, repo, state string) ([]github.Milestone, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.client.ListMilestonesByState(org, repo, state)
+}
+
+func (c *contextClient) CreateMilestone(ctx context.Context, org, repo, title string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.client.CreateMilestone(org, repo, title)
+}
+
+func (c *contextClient) GetIssue(ctx context.Context, org, repo string, number int) (*github.Issue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.client.GetIssue(org, repo, number)
+}
+
+func (c *contextClient) GetPullRequest(ctx context.Context, org, repo string, number int) (*github.PullRequest, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.client.GetPullRequest(org, repo, number)
+}
+
+// maintainersClient binds a githubClient to a context for looking up the
+// milestone maintainers with plugins.IsMilestoneMaintainer.
+type maintainersClient struct {
+	ctx context.Context
+	gc  githubClient
+}
+
+func (c *maintainersClient) ListTeams(org string) ([]github.Team, error) {
+	return c.gc.ListTeams(c.ctx, org)
+}
+
+func (c *maintainersClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	return c.gc.ListTeamMembersBySlug(c.ctx, org, teamSlug, role)
+}
+
+func (c *maintainersClient) IsMember(org, user string) (bool, error) {
+	return c.gc.IsMember(c.ctx, org, user)
+}
+
+func (c *maintainersClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	return c.gc.TeamBySlugHasMember(c.ctx, org, teamSlug, memberLogin)
+}
+
+func (c *contextClient) GetPullRequestChanges(ctx context.Context, org, repo string, number int) ([]github.PullRequestChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.client.GetPullRequestChanges(org, repo, number)
+}
+
+func (c *contextClient) GetIssueLabels(ctx context.Context, org, repo string, number int) ([]github.Label, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.client.GetIssueLabels(org, repo, number)
+}
+
+func (c *contextClient) AddLabel(ctx context.Context, org, repo string, number int, label string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.client.AddLabel(org, repo, number, label)
+}
+
+func (c *contextClient) RemoveLabel(ctx context.Context, org, repo string, number int, label string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.client.RemoveLabel(org, repo, number, label)
+}
+
+func (c *contextClient) GetPullRequests(ctx context.Context, org, repo string) ([]github.PullRequest, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.client.GetPullRequests(org, repo)
+}
+
+func (c *contextClient) FindIssuesWithOrg(ctx context.Context, org, query, sort string, asc bool) ([]github.Issue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.client.FindIssuesWithOrg(org, query, sort, asc)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"sync"
+	"time"
+)
+
+var changeCooldowns = newCooldownTracker(time.Now)
+
+// cooldownTracker remembers until when the milestone of each issue or PR
+// can't be changed again, keyed by org/repo#number, so that the milestone
+// can't be changed back and forth in quick succession.
+type cooldownTracker struct {
+	lock  sync.Mutex
+	now   func() time.Time
+	until map[string]time.Time
+}
+
+func newCooldownTracker(now func() time.Time) *cooldownTracker {
+	return &cooldownTracker{now: now, until: map[string]time.Time{}}
+}
+
+// remaining returns how long the milestone of the issue or PR can't be
+// changed for, which is 0 if it can be changed now.
+func (c *cooldownTracker) remaining(key string) time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	for k, until := range c.until {
+		// forget the cooldowns which are over
+		if !now.Before(until) {
+			delete(c.until, k)
+		}
+	}
+	if until, ok := c.until[key]; ok {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+// record records that the milestone of the issue or PR was changed now, so
+// that it can't be changed again for the cooldown.
+func (c *cooldownTracker) record(key string, cooldown time.Duration) {
+	if cooldown <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.until[key] = c.now().Add(cooldown)
+}
+
+// formatCooldown formats the remaining cooldown rounded up to the second.
+func formatCooldown(remaining time.Duration) string {
+	return (remaining + time.Second - 1).Truncate(time.Second).String()
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestChangeCooldown(t *testing.T) {
+	testcases := []struct {
+		name              string
+		cooldown          time.Duration
+		elapsed           time.Duration
+		secondBody        string
+		secondNumber      int
+		expectedCalls     int
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "A change within the cooldown is refused",
+			cooldown:          10 * time.Minute,
+			elapsed:           time.Minute,
+			secondBody:        "/milestone v2.0",
+			secondNumber:      1,
+			expectedCalls:     1,
+			expectedMilestone: 1,
+			expectedComment:   fmt.Sprintf(milestoneInCooldown, "9m0s"),
+		},
+		{
+			name:              "Clearing the milestone within the cooldown is refused",
+			cooldown:          10 * time.Minute,
+			elapsed:           90 * time.Second,
+			secondBody:        "/milestone clear",
+			secondNumber:      1,
+			expectedCalls:     1,
+			expectedMilestone: 1,
+			expectedComment:   fmt.Sprintf(milestoneInCooldown, "8m30s"),
+		},
+		{
+			name:              "A change after the cooldown is made",
+			cooldown:          10 * time.Minute,
+			elapsed:           10 * time.Minute,
+			secondBody:        "/milestone v2.0",
+			secondNumber:      1,
+			expectedCalls:     2,
+			expectedMilestone: 2,
+		},
+		{
+			name:              "A change of another issue within the cooldown is made",
+			cooldown:          10 * time.Minute,
+			elapsed:           time.Minute,
+			secondBody:        "/milestone v2.0",
+			secondNumber:      2,
+			expectedCalls:     2,
+			expectedMilestone: 2,
+		},
+		{
+			name:              "Changes are made in quick succession without a cooldown",
+			elapsed:           time.Second,
+			secondBody:        "/milestone v2.0",
+			secondNumber:      1,
+			expectedCalls:     2,
+			expectedMilestone: 2,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			defer func(previous *cooldownTracker) { changeCooldowns = previous }(changeCooldowns)
+			changeCooldowns = newCooldownTracker(func() time.Time { return now })
+			fakeClient := &settingClient{FakeClient: fakegithub.NewFakeClient()}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1, "v2.0": 2}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads", ChangeCooldownDuration: tc.cooldown}}
+			events := []*github.GenericCommentEvent{
+				{Body: "/milestone v1.0", Number: 1},
+				{Body: tc.secondBody, Number: tc.secondNumber},
+			}
+			for _, e := range events {
+				e.Action = github.GenericCommentActionCreated
+				e.Repo = github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}
+				e.User = github.User{Login: "sig-lead"}
+				if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+					t.Fatalf("Unexpected error from handle: %v.", err)
+				}
+				now = now.Add(tc.elapsed)
+			}
+			if fakeClient.calls != tc.expectedCalls {
+				t.Errorf("Expected SetMilestone to be called %d times, got %d.", tc.expectedCalls, fakeClient.calls)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			comments := fakeClient.IssueComments[tc.secondNumber]
+			if tc.expectedComment == "" {
+				if len(comments) != 0 {
+					t.Errorf("Expected no comments, got %v.", comments)
+				}
+				return
+			}
+			if len(comments) != 1 || !strings.Contains(comments[0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, comments)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// defaultDuplicateCommandWindow is used when no duplicate_command_window is configured.
+const defaultDuplicateCommandWindow = 10 * time.Second
+
+var recentCommands = newCommandDeduplicator(time.Now)
+
+// commandDeduplicator remembers when each command was last handled, keyed by
+// org/repo#number and command, so that identical commands issued in quick
+// succession are only handled once.
+type commandDeduplicator struct {
+	lock sync.Mutex
+	now  func() time.Time
+	seen map[string]time.Time
+}
+
+func newCommandDeduplicator(now func() time.Time) *commandDeduplicator {
+	return &commandDeduplicator{now: now, seen: map[string]time.Time{}}
+}
+
+// reserve records the command as being handled now and returns true, unless
+// the same command was handled or is being handled within the window. Checking
+// and recording the command under one lock makes sure that only one of the
+// identical commands delivered concurrently is handled.
+func (d *commandDeduplicator) reserve(key string, window time.Duration) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := d.now()
+	for k, handled := range d.seen {
+		// forget the commands which can no longer be duplicated
+		if now.Sub(handled) >= window {
+			delete(d.seen, k)
+		}
+	}
+	if _, ok := d.seen[key]; ok {
+		return false
+	}
+	d.seen[key] = now
+	return true
+}
+
+// release forgets the command, so that it can be handled again.
+func (d *commandDeduplicator) release(key string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.seen, key)
+}
+
+// handleDeduplicated handles the event unless it repeats a /milestone command
+// handled within the duplicate command window. A command is reserved before it
+// is handled and released if handling it fails, so that it can be retried, and
+// the queries, which change nothing, are never deduplicated.
+func handleDeduplicated(ctx context.Context, gc githubClient, authorizer plugins.Authorizer, log *logrus.Entry, e *github.GenericCommentEvent, repoMilestone map[string]plugins.Milestone, recent *commandDeduplicator) error {
+	body := plugins.StripQuotedText(e.Body)
+	if e.Action != github.GenericCommentActionCreated || !milestoneRegex.MatchString(body) || isQuery(body) {
+		return handleWithAuthorizer(ctx, gc, authorizer, log, e, repoMilestone)
+	}
+	window := plugins.MilestoneFor(repoMilestone, e.Repo.Owner.Login, e.Repo.Name).DuplicateCommandWindowDuration
+	if window == 0 {
+		window = defaultDuplicateCommandWindow
+	}
+	key := fmt.Sprintf("%s/%s#%d:%s", e.Repo.Owner.Login, e.Repo.Name, e.Number, strings.TrimSpace(e.Body))
+	if !recent.reserve(key, window) {
+		log.WithField("window", window).Info("Ignoring a /milestone command identical to one handled recently.")
+		return nil
+	}
+	if err := handleWithAuthorizer(ctx, gc, authorizer, log, e, repoMilestone); err != nil {
+		recent.release(key)
+		return err
+	}
+	return nil
+}
+
+// isQuery returns whether the body holds one of the /milestone commands which
+// only report information.
+func isQuery(body string) bool {
+	return queryRegex.MatchString(body) || listRegex.MatchString(body) || whoRegex.MatchString(body)
+}
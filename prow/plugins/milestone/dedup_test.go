@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestDuplicateCommands(t *testing.T) {
+	testcases := []struct {
+		name          string
+		elapsed       time.Duration
+		secondBody    string
+		secondNumber  int
+		expectedCalls int
+	}{
+		{
+			name:          "identical command within the window is ignored",
+			elapsed:       time.Second,
+			secondBody:    "/milestone v1.0",
+			secondNumber:  1,
+			expectedCalls: 1,
+		},
+		{
+			name:          "identical command after the window is handled",
+			elapsed:       time.Minute,
+			secondBody:    "/milestone v1.0",
+			secondNumber:  1,
+			expectedCalls: 2,
+		},
+		{
+			name:          "different command within the window is handled",
+			elapsed:       time.Second,
+			secondBody:    "/milestone v2.0",
+			secondNumber:  1,
+			expectedCalls: 2,
+		},
+		{
+			name:          "identical command on another issue within the window is handled",
+			elapsed:       time.Second,
+			secondBody:    "/milestone v1.0",
+			secondNumber:  2,
+			expectedCalls: 2,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			recent := newCommandDeduplicator(func() time.Time { return now })
+			fakeClient := &settingClient{FakeClient: fakegithub.NewFakeClient()}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1, "v2.0": 2}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+			events := []*github.GenericCommentEvent{
+				{Body: "/milestone v1.0", Number: 1},
+				{Body: tc.secondBody, Number: tc.secondNumber},
+			}
+			for _, e := range events {
+				e.Action = github.GenericCommentActionCreated
+				e.Repo = github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}
+				e.User = github.User{Login: "sig-lead"}
+				if err := handleDeduplicated(context.Background(), &contextClient{client: fakeClient}, nil, logrus.WithField("plugin", pluginName), e, repoMilestone, recent); err != nil {
+					t.Fatalf("Unexpected error from handleDeduplicated: %v.", err)
+				}
+				now = now.Add(tc.elapsed)
+			}
+			if fakeClient.calls != tc.expectedCalls {
+				t.Errorf("Expected SetMilestone to be called %d times, got %d.", tc.expectedCalls, fakeClient.calls)
+			}
+		})
+	}
+}
+
+func TestDuplicateCommandsAfterFailure(t *testing.T) {
+	recent := newCommandDeduplicator(time.Now)
+	fakeClient := &settingClient{FakeClient: fakegithub.NewFakeClient(), failures: 1}
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/milestone v1.0",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	if err := handleDeduplicated(context.Background(), &contextClient{client: fakeClient}, nil, logrus.WithField("plugin", pluginName), e, repoMilestone, recent); err == nil {
+		t.Fatal("Expected the first command to fail.")
+	}
+	if err := handleDeduplicated(context.Background(), &contextClient{client: fakeClient}, nil, logrus.WithField("plugin", pluginName), e, repoMilestone, recent); err != nil {
+		t.Fatalf("Unexpected error from handleDeduplicated: %v.", err)
+	}
+	if fakeClient.calls != 2 {
+		t.Errorf("Expected the command to be retried after failing, got %d calls setting the milestone.", fakeClient.calls)
+	}
+	if fakeClient.Milestone != 1 {
+		t.Errorf("Expected milestone 1 to be set, got %d.", fakeClient.Milestone)
+	}
+}
+
+func TestConcurrentDuplicateCommands(t *testing.T) {
+	recent := newCommandDeduplicator(time.Now)
+	fakeClient := &settingClient{FakeClient: fakegithub.NewFakeClient(), setting: make(chan struct{}, 2), release: make(chan struct{})}
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+	handle := func(errs chan<- error) {
+		e := &github.GenericCommentEvent{
+			Action: github.GenericCommentActionCreated,
+			Body:   "/milestone v1.0",
+			Number: 1,
+			Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			User:   github.User{Login: "sig-lead"},
+		}
+		errs <- handleDeduplicated(context.Background(), &contextClient{client: fakeClient}, nil, logrus.WithField("plugin", pluginName), e, repoMilestone, recent)
+	}
+
+	first, second := make(chan error, 1), make(chan error, 1)
+	go handle(first)
+	select {
+	case <-fakeClient.setting:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The first command didn't set the milestone.")
+	}
+	// the second delivery arrives while the first one is still being handled
+	go handle(second)
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Errorf("Unexpected error from the second handleDeduplicated: %v.", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Error("The second command was handled concurrently with the first one.")
+	}
+	close(fakeClient.release)
+	if err := <-first; err != nil {
+		t.Errorf("Unexpected error from the first handleDeduplicated: %v.", err)
+	}
+	if fakeClient.calls != 1 {
+		t.Errorf("Expected SetMilestone to be called once, got %d calls.", fakeClient.calls)
+	}
+}
+
+func TestDuplicateQueries(t *testing.T) {
+	for _, body := range []string{"/milestone?", "/milestone status", "/milestone list", "/milestone who"} {
+		t.Run(body, func(t *testing.T) {
+			recent := newCommandDeduplicator(time.Now)
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Issues = map[int]*github.Issue{1: {Number: 1}}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+			for i := 0; i < 2; i++ {
+				e := &github.GenericCommentEvent{
+					Action: github.GenericCommentActionCreated,
+					Body:   body,
+					Number: 1,
+					Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+					User:   github.User{Login: "someone"},
+				}
+				if err := handleDeduplicated(context.Background(), &contextClient{client: fakeClient}, nil, logrus.WithField("plugin", pluginName), e, repoMilestone, recent); err != nil {
+					t.Fatalf("Unexpected error from handleDeduplicated: %v.", err)
+				}
+			}
+			if len(fakeClient.IssueComments[1]) != 2 {
+				t.Errorf("Expected both queries to be answered, got %d comments.", len(fakeClient.IssueComments[1]))
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"k8s.io/test-infra/prow/github"
+)
+
+// cachedMilestones returns the milestones of the repo which the commands of
+// the plugin fetched recently, listing the open ones if they were fetched. Help
+// providers aren't given a GitHub client, so the help lists the milestones
+// from the cache of the plugin instead of calling GitHub.
+func cachedMilestones(org, repo string) ([]github.Milestone, bool) {
+	for _, state := range []string{github.MilestoneStateOpen, github.MilestoneStateAll} {
+		if milestones, ok := milestonesCache.get(org, repo, state); ok {
+			return milestones, true
+		}
+	}
+	return nil, false
+}
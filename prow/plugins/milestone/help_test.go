@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	prowconfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestHelpOpenMilestones(t *testing.T) {
+	milestones := []github.Milestone{
+		{Title: "v1.1", Number: 2, State: github.MilestoneStateOpen},
+		{Title: "v1.0", Number: 1, State: github.MilestoneStateOpen},
+		{Title: "v0.9", Number: 3, State: github.MilestoneStateClosed},
+	}
+
+	testcases := []struct {
+		name        string
+		cached      map[string][]github.Milestone
+		cachedState string
+		expected    map[string]string
+		notExpected []string
+	}{
+		{
+			name:   "open milestones are listed for every enabled repo",
+			cached: map[string][]github.Milestone{"repo": milestones[:2], "other": milestones[:2]},
+			expected: map[string]string{
+				"org/repo":  "Open milestones in this repository: [`v1.0`, `v1.1`].",
+				"org/other": "Open milestones in this repository: [`v1.0`, `v1.1`].",
+			},
+		},
+		{
+			name:        "closed milestones listed along the open ones are left out",
+			cached:      map[string][]github.Milestone{"repo": milestones},
+			cachedState: github.MilestoneStateAll,
+			expected: map[string]string{
+				"org/repo": "Open milestones in this repository: [`v1.0`, `v1.1`].",
+			},
+		},
+		{
+			name:   "repo without open milestones says so",
+			cached: map[string][]github.Milestone{"repo": nil, "other": nil},
+			expected: map[string]string{
+				"org/repo":  noOpenMilestones,
+				"org/other": noOpenMilestones,
+			},
+		},
+		{
+			name:        "repo without cached milestones falls back to the static help",
+			expected:    map[string]string{"org/repo": `"leads"`},
+			notExpected: []string{"org/other"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			oldCache := milestonesCache
+			defer func() { milestonesCache = oldCache }()
+			milestonesCache = newMilestoneCache(time.Now)
+			for repo, milestones := range tc.cached {
+				state := github.MilestoneStateOpen
+				if tc.cachedState != "" {
+					state = tc.cachedState
+				}
+				milestonesCache.set("org", repo, state, milestones, time.Minute)
+			}
+
+			config := &plugins.Configuration{
+				RepoMilestone: map[string]plugins.Milestone{
+					"org/repo": {MaintainersTeam: "leads"},
+				},
+			}
+			help, err := helpProvider(config, []prowconfig.OrgRepo{{Org: "org", Repo: "repo"}, {Org: "org", Repo: "other"}})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for repo, expected := range tc.expected {
+				if !strings.Contains(help.Config[repo], expected) {
+					t.Errorf("Expected the help of %s to contain %q, got %q", repo, expected, help.Config[repo])
+				}
+			}
+			for _, repo := range tc.notExpected {
+				if msg, ok := help.Config[repo]; ok {
+					t.Errorf("Expected no help for %s, got %q", repo, msg)
+				}
+			}
+		})
+	}
+}
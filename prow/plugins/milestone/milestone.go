@@ -19,12 +19,19 @@ limitations under the License.
 package milestone
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	prowconfig "k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
@@ -32,32 +39,216 @@ import (
 	"k8s.io/test-infra/prow/plugins"
 )
 
-const pluginName = "milestone"
+const (
+	pluginName = "milestone"
+	// defaultMaintainersListLimit is used when no maintainers_list_limit is configured.
+	defaultMaintainersListLimit = 20
+	// trackingLabelPrefix prefixes the title of the milestone in the label
+	// recording that it was set by the plugin, e.g. `milestone-set/v1.20`.
+	trackingLabelPrefix = "milestone-set/"
+)
 
 var (
-	milestoneRegex   = regexp.MustCompile(`(?m)^/milestone\s+(.+?)\s*$`)
-	mustBeAuthorized = "You must be a member of the [%s/%s](https://github.com/orgs/%s/teams/%s/members) GitHub team to set the milestone. If you believe you should be able to issue the /milestone command, please contact your %s and have them propose you as an additional delegate for this responsibility."
-	invalidMilestone = "The provided milestone is not valid for this repository. Milestones in this repository: [%s]\n\nUse `/milestone %s` to clear the milestone."
-	milestoneTeamMsg = "The milestone maintainers team is the GitHub team %q with ID: %d."
-	clearKeyword     = "clear"
+	milestoneRegex          = regexp.MustCompile(`(?m)^/milestone(?:[ \t]+([^\r\n]+?))?[ \t\r]*$`)
+	queryRegex              = regexp.MustCompile(`(?m)^/milestone(?:\?|[ \t]+status)[ \t\r]*$`)
+	listRegex               = regexp.MustCompile(`(?m)^/milestone[ \t]+list[ \t\r]*$`)
+	whoRegex                = regexp.MustCompile(`(?m)^/milestone[ \t]+who[ \t\r]*$`)
+	numberRegex             = regexp.MustCompile(`^(?:#|number:)(\d+)$`)
+	milestoneURLRegex       = regexp.MustCompile(`^https?://[^/\s]+/([^/\s]+)/([^/\s]+)/milestone/(\d+)/?(?:[?#]\S*)?$`)
+	issueRefsRegex          = regexp.MustCompile(`^(.+?)((?:\s+#\d+)+)$`)
+	closingRegex            = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+#(\d+)\b`)
+	mustBeAuthorized        = "You must be a member of %s to set the milestone. If you believe you should be able to issue the /milestone command, please contact your %s and have them propose you as an additional delegate for this responsibility."
+	parentTeamMember        = "\n\nYou are a member of the parent team; ask an org admin to add you to the child team."
+	noMaintainers           = "The milestone maintainers team has no members, so nobody can use the /milestone command. Please report this to the maintainers of the Prow configuration."
+	missingPermission       = "Prow can't look up the members of the `%s` org, so the /milestone command can't be authorized. Please report this to the maintainers of the Prow configuration, its GitHub App may lack the members:read permission."
+	teamNotFound            = "The %v, so the /milestone command can't be authorized. Please report this to the maintainers of the Prow configuration."
+	invalidMilestone        = "The provided milestone is not valid for this repository. Milestones in this repository: [%s]\n\nUse %s to clear the milestone."
+	ambiguousMilestone      = "The milestone `%s` is ambiguous, as it matches the milestones %s. Please use the exact title or the number of the milestone, e.g. `/milestone #42`."
+	closedIssueWarning      = "The milestone `%s` was set, but this issue or pull request is closed. If that was a mistake, please use `/milestone clear` to clear it."
+	milestoneInCooldown     = "The milestone of this issue or PR was changed recently. It can be changed again in %s."
+	milestoneFrozen         = "The milestone `%s` is frozen since %s and can no longer be set."
+	frozenExceptFor         = " Only the members of %s may still set it."
+	confirmClear            = "Are you sure you want to clear the milestone? If so, please use `/milestone %s %s`."
+	clearConditionFailed    = "The milestone was not cleared, as it changed in the meantime: it is %s, not `%s`."
+	didYouMean              = "Did you mean %s?\n\n"
+	closedMilestone         = "The milestone `%s` is closed and can no longer be set. Open milestones in this repository: [%s]\n\nUse %s to clear the milestone."
+	createNotAllowed        = "Creating milestones with `--create` is not enabled for this repository."
+	wouldCreateMilestone    = "Would create and set milestone %s. The milestone plugin is running in dry-run mode."
+	titleNotAllowed         = "The milestone `%s` can't be set by the milestone plugin, as its title does not match the pattern `%s`."
+	milestoneSet            = "Set milestone to %s."
+	milestoneAlreadySet     = "The milestone is already set to `%s`."
+	milestoneRestored       = "Restored the milestone %s."
+	nothingToUndo           = "There is no change of the milestone of this issue or PR to undo."
+	undoMilestoneGone       = "The milestone `%s` no longer exists, so the change can't be undone."
+	wouldUndoMilestone      = "Would restore the milestone %s. The milestone plugin is running in dry-run mode."
+	milestoneCleared        = "Cleared the milestone."
+	milestoneChangedBy      = "\n\nThe milestone was changed by @%s: %s → %s."
+	noMilestone             = "none"
+	wouldSetMilestone       = "Would set milestone to %s. The milestone plugin is running in dry-run mode."
+	milestoneSetOnIssues    = "Set milestone to %s on the referenced issues:\n%s"
+	milestoneSetOnPRs       = "Set milestone to %s on the open pull requests against the `%s` branch:\n%s"
+	noOpenPRs               = "There are no open pull requests against the `%s` branch."
+	bulkNotAllowed          = "Setting the milestone of many issues or pull requests at once is not enabled for this repository."
+	mustBeAuthorizedForBulk = "You must be a member of %s to set the milestone of many issues or pull requests at once."
+	milestonesMoved         = "Moved %d issues and pull requests from milestone `%s` to milestone `%s`."
+	milestonesNotMoved      = "\n\nMoving %d of them failed: %s"
+	nothingToMove           = "There are no open issues or pull requests in milestone `%s`."
+	wouldMoveMilestones     = "Would move %d issues and pull requests from milestone `%s` to milestone `%s`. The milestone plugin is running in dry-run mode."
+	unknownMoveMilestone    = "The milestone `%s` doesn't exist in this repository. Milestones in this repository: [%s]"
+	issueMilestoneSet       = "- #%d: set"
+	issueNotFound           = "- #%d: not set, the issue does not exist in this repository"
+	issueMilestoneFailed    = "- #%d: not set, setting the milestone failed"
+	wouldSetMilestoneOn     = "Would set milestone to %s on %s. The milestone plugin is running in dry-run mode."
+	wouldClearMilestone     = "Would clear the milestone. The milestone plugin is running in dry-run mode."
+	mustBeMilestoneOwner    = "You must be a member of %s to set the milestone to `%s`. If you believe you should be able to set this milestone, please contact your %s."
+	mustBeAuthorizedToClear = "You must be a member of %s to clear the milestone. If you believe you should be able to clear the milestone, please contact your %s."
+	teamLink                = "the [%s/%s](https://github.com/orgs/%s/teams/%s/members) GitHub team"
+	orgLink                 = "the [%s](https://github.com/orgs/%s/people) GitHub org"
+	milestoneTeamsMsg       = "The milestone maintainers teams are the GitHub teams %s."
+	milestoneTeamMsg        = "The milestone maintainers team is the GitHub team %q with ID: %d."
+	currentMilestoneMsg     = " `/milestone` without an argument sets the current milestone %q."
+	foreignMilestoneURL     = "The milestone URL refers to the `%s/%s` repository. Please use a milestone of this repository instead."
+	aliasNotFound           = "The alias `%s` refers to the milestone `%s`, which doesn't exist in this repository. Please ask the maintainers of the milestone plugin configuration to fix the alias."
+	noBranchMilestone       = "No milestone is configured for PRs against the `%s` branch."
+	noLabelMilestone        = "None of the labels of this issue or PR maps to a milestone."
+	conflictingLabels       = "The labels of this issue or PR map to different milestones: %s. Please set the milestone explicitly."
+	autoOnlyOnPRs           = "The milestone can only be determined automatically for pull requests."
+	noNextMilestone         = "There is no open milestone with a due date in the future."
+	milestoneIs             = "The milestone of this issue or PR is `%s`."
+	milestoneDueOn          = " It is due on %s."
+	noMilestoneIsSet        = "This issue or PR has no milestone."
+	maintainersAre          = "The milestone maintainers are %s."
+	moreMaintainers         = "%s and %d more, see %s"
+	orgMembersMaintainers   = "All the members of %s are milestone maintainers."
+	openMilestones          = "Open milestones in this repository: [%s]"
+	noOpenMilestones        = "There are no open milestones in this repository."
+	rateLimited             = "GitHub is rate limiting the requests of the milestone plugin at the moment. Please try again shortly."
+	clearKeyword            = "clear"
+	clearConditionPrefix    = "if:"
+	clearKeywords           = []string{clearKeyword, "none", "-"}
+	autoKeyword             = "auto"
+	createFlag              = "--create"
+	allOpenPRsFlag          = "--all-open-prs"
+	moveRegex               = regexp.MustCompile(`(?i)^move\s+from:("[^"]+"|\S+)\s+to:("[^"]+"|\S+)$`)
+	basePrefix              = "base:"
+	nextKeyword             = "next"
+	undoKeyword             = "undo"
+	fromLabelsKeyword       = "from-labels"
+	confirmKeyword          = "confirm"
+)
+
+// ErrRateLimited is returned when a command could not be handled because
+// GitHub is rate limiting the requests of the plugin, so that callers can
+// tell it apart from other failures and back off instead of retrying.
+var ErrRateLimited = errors.New("rate limited by GitHub")
+
+// Outcomes of a /milestone command, used as the outcome label of milestoneCommands
+// and in milestoneStats.
+const (
+	outcomeSet          = "set"
+	outcomeCleared      = "cleared"
+	outcomeUnauthorized = "unauthorized"
+	outcomeInvalid      = "invalid"
+	outcomeRateLimited  = "rate_limited"
+	outcomeQueried      = "queried"
+	outcomeUnchanged    = "unchanged"
 )
 
+var milestoneCommands = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "milestone_commands_total",
+	Help: "Number of /milestone commands handled by the milestone plugin, by outcome.",
+}, []string{"org", "repo", "outcome"})
+
+// milestoneStats counts the /milestone commands by repo and outcome, and is
+// served by the debug handler of the plugin.
+var milestoneStats = plugins.NewCommandStats()
+
+// countCommand counts a /milestone command with the given outcome in org/repo.
+func countCommand(org, repo, outcome string) {
+	milestoneCommands.WithLabelValues(org, repo, outcome).Inc()
+	milestoneStats.Inc(org, repo, outcome)
+}
+
+// Reactions to the comment with the command used instead of comments when the
+// milestone is configured to use reactions.
+const (
+	successReaction = "+1"
+	failureReaction = "-1"
+)
+
+// maxSuggestionDistance is the largest edit distance between a proposed milestone
+// and an existing milestone title for which the latter is suggested.
+const maxSuggestionDistance = 2
+
+// handleTimeout bounds the time spent handling a single /milestone command.
+const handleTimeout = 5 * time.Minute
+
 type githubClient interface {
+	CreateComment(ctx context.Context, owner, repo string, number int, comment string) error
+	CreateCommentReaction(ctx context.Context, org, repo string, id int, reaction string) error
+	ClearMilestone(ctx context.Context, org, repo string, num int) error
+	SetMilestone(ctx context.Context, org, repo string, issueNum, milestoneNum int) error
+	ListTeams(ctx context.Context, org string) ([]github.Team, error)
+	ListTeamMembersBySlug(ctx context.Context, org, teamSlug, role string) ([]github.TeamMember, error)
+	IsMember(ctx context.Context, org, user string) (bool, error)
+	TeamBySlugHasMember(ctx context.Context, org string, teamSlug string, memberLogin string) (bool, error)
+	ListMilestonesByState(ctx context.Context, org, repo, state string) ([]github.Milestone, error)
+	CreateMilestone(ctx context.Context, org, repo, title string) (int, error)
+	GetIssue(ctx context.Context, org, repo string, number int) (*github.Issue, error)
+	GetPullRequest(ctx context.Context, org, repo string, number int) (*github.PullRequest, error)
+	GetPullRequestChanges(ctx context.Context, org, repo string, number int) ([]github.PullRequestChange, error)
+	GetPullRequests(ctx context.Context, org, repo string) ([]github.PullRequest, error)
+	FindIssuesWithOrg(ctx context.Context, org, query, sort string, asc bool) ([]github.Issue, error)
+	GetIssueLabels(ctx context.Context, org, repo string, number int) ([]github.Label, error)
+	AddLabel(ctx context.Context, org, repo string, number int, label string) error
+	RemoveLabel(ctx context.Context, org, repo string, number int, label string) error
+}
+
+// plainGitHubClient is the GitHub client of the plugin, whose methods don't
+// take a context. It is adapted to githubClient by contextClient.
+type plainGitHubClient interface {
 	CreateComment(owner, repo string, number int, comment string) error
+	CreateCommentReaction(org, repo string, id int, reaction string) error
 	ClearMilestone(org, repo string, num int) error
 	SetMilestone(org, repo string, issueNum, milestoneNum int) error
-	ListTeamMembersBySlug(org string, id int, role string) ([]github.TeamMember, error)
+	ListTeams(org string) ([]github.Team, error)
 	ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error)
-	ListMilestones(org, repo string) ([]github.Milestone, error)
+	IsMember(org, user string) (bool, error)
+	TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error)
+	ListMilestonesByState(org, repo, state string) ([]github.Milestone, error)
+	CreateMilestone(org, repo, title string) (int, error)
+	GetIssue(org, repo string, number int) (*github.Issue, error)
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+	GetPullRequests(org, repo string) ([]github.PullRequest, error)
+	FindIssuesWithOrg(org, query, sort string, asc bool) ([]github.Issue, error)
+	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
+	AddLabel(org, repo string, number int, label string) error
+	RemoveLabel(org, repo string, number int, label string) error
 }
 
 func init() {
 	plugins.RegisterGenericCommentHandler(pluginName, handleGenericComment, helpProvider)
+	prometheus.MustRegister(milestoneCommands)
+	plugins.RegisterDebugHandler(pluginName, milestoneStats)
 }
 
 func helpProvider(config *plugins.Configuration, enabledRepos []prowconfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
 	msgForTeam := func(team plugins.Milestone) string {
-		return fmt.Sprintf(milestoneTeamMsg, team.MaintainersTeam, team.MaintainersID)
+		var msg string
+		teams := team.MaintainersTeamSlugs()
+		if len(teams) > 1 {
+			msg = fmt.Sprintf(milestoneTeamsMsg, strings.Join(teams, ", "))
+		} else {
+			if len(teams) == 1 {
+				team.MaintainersTeam = teams[0]
+			}
+			msg = fmt.Sprintf(milestoneTeamMsg, team.MaintainersTeam, team.MaintainersID)
+		}
+		if team.CurrentMilestone != "" {
+			msg += fmt.Sprintf(currentMilestoneMsg, team.CurrentMilestone)
+		}
+		return msg
 	}
 
 	pluginHelp := &pluginhelp.PluginHelp{
@@ -65,9 +256,16 @@ func helpProvider(config *plugins.Configuration, enabledRepos []prowconfig.OrgRe
 		Config: func(repos []prowconfig.OrgRepo) map[string]string {
 			configMap := make(map[string]string)
 			for _, repo := range repos {
-				team, exists := config.RepoMilestone[repo.String()]
-				if exists {
-					configMap[repo.String()] = msgForTeam(team)
+				milestone := plugins.MilestoneFor(config.RepoMilestone, repo.Org, repo.Repo)
+				// the open milestones are only listed once a command fetched them
+				if milestones, ok := cachedMilestones(repo.Org, repo.Repo); ok {
+					msg := noOpenMilestones
+					if open := OpenMilestones(milestones); len(open) > 0 {
+						msg = fmt.Sprintf(openMilestones, FormatMilestoneList(open)) + "."
+					}
+					configMap[repo.String()] = msgForTeam(milestone) + " " + msg
+				} else if plugins.HasMilestoneFor(config.RepoMilestone, repo.Org, repo.Repo) {
+					configMap[repo.String()] = msgForTeam(milestone)
 				}
 			}
 			configMap[""] = msgForTeam(config.RepoMilestone[""])
@@ -75,100 +273,1347 @@ func helpProvider(config *plugins.Configuration, enabledRepos []prowconfig.OrgRe
 		}(enabledRepos),
 	}
 	pluginHelp.AddCommand(pluginhelp.Command{
-		Usage:       "/milestone <version> or /milestone clear",
+		Usage:       "/milestone [<version>] [#<issue>...] [--create], /milestone <version> --all-open-prs base:<branch>, /milestone move from:<version> to:<version>, /milestone \"<title>\", /milestone #<number>, /milestone <milestone URL>, /milestone auto, /milestone next, /milestone from-labels, /milestone undo or /milestone (clear|none|-) [confirm] [if:<version>]",
 		Description: "Updates the milestone for an issue or PR",
 		Featured:    false,
 		WhoCanUse:   "Members of the milestone maintainers GitHub team can use the '/milestone' command.",
-		Examples:    []string{"/milestone v1.10", "/milestone v1.9", "/milestone clear"},
+		Examples:    []string{"/milestone v1.10", "/milestone v1.9", "/milestone v1.10 #101 #102", "/milestone \"Q3 Planning\"", "/milestone #42", "/milestone number:42", "/milestone https://github.com/org/repo/milestone/42", "/milestone auto", "/milestone next", "/milestone from-labels", "/milestone undo", "/milestone v1.21 --create", "/milestone v1.20 --all-open-prs base:release-1.20", "/milestone move from:v1.20 to:v1.21", "/milestone clear", "/milestone none", "/milestone clear confirm", "/milestone clear if:v1.10"},
+	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/milestone?, /milestone status, /milestone list or /milestone who",
+		Description: "Reports the milestone of an issue or PR and its due date, lists the open milestones of the repository, or lists the milestone maintainers",
+		Featured:    false,
+		WhoCanUse:   "Anyone can use the '/milestone?', '/milestone list' and '/milestone who' commands.",
+		Examples:    []string{"/milestone?", "/milestone status", "/milestone list", "/milestone who"},
 	})
 	return pluginHelp, nil
 }
 
 func handleGenericComment(pc plugins.Agent, e github.GenericCommentEvent) error {
-	return handle(pc.GitHubClient, pc.Logger, &e, pc.PluginConfig.RepoMilestone)
+	milestone := plugins.MilestoneFor(pc.PluginConfig.RepoMilestone, e.Repo.Owner.Login, e.Repo.Name)
+	ctx, cancel := context.WithTimeout(context.Background(), handleTimeout)
+	defer cancel()
+	return handleDeduplicated(ctx, pluginClient(ctx, pc, milestone), pc.Authorizer, pc.Logger, &e, pc.PluginConfig.RepoMilestone, recentCommands)
+}
+
+// pluginClient returns the client of pc with which the milestone plugin makes
+// its requests, serving the members of the teams from the cache of the
+// TeamMembershipResolver of pc and the milestones from milestonesCache.
+func pluginClient(ctx context.Context, pc plugins.Agent, milestone plugins.Milestone) githubClient {
+	// the requests are made with ctx, so that they are aborted once it's done
+	ghc := pc.GitHubClient.WithContext(ctx)
+	teams := &teamsCachingClient{
+		plainGitHubClient: ghc,
+		teams:             pc.TeamMembershipResolver.Client(ghc, milestone.TeamMembershipCacheTTLDuration),
+	}
+	gc := &milestonesCachingClient{plainGitHubClient: teams, cache: milestonesCache, ttl: milestone.MilestonesCacheTTLDuration}
+	return &contextClient{client: gc}
+}
+
+// NormalizeTitle returns the form of a milestone title used for matching, so
+// that titles differing only in case or whitespace are equivalent.
+func NormalizeTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
 }
 
+// BuildMilestoneMap maps the normalized title of each milestone to its number.
+// Titles which collide under normalization, e.g. `v1.0` and `V1.0`, are left
+// out, as their normalized title doesn't identify one milestone.
 func BuildMilestoneMap(milestones []github.Milestone) map[string]int {
 	m := make(map[string]int)
+	colliding := sets.NewString()
 	for _, ms := range milestones {
-		m[ms.Title] = ms.Number
+		title := NormalizeTitle(ms.Title)
+		if _, ok := m[title]; ok {
+			colliding.Insert(title)
+		}
+		m[title] = ms.Number
+	}
+	for _, title := range colliding.List() {
+		delete(m, title)
 	}
 	return m
 }
-func handle(gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent, repoMilestone map[string]plugins.Milestone) error {
-	if e.Action != github.GenericCommentActionCreated {
+
+// collidingMilestones returns the milestones which title matches under
+// normalization if there are several of them and none has exactly that
+// title, and nil otherwise.
+func collidingMilestones(milestones []github.Milestone, title string) []github.Milestone {
+	var colliding []github.Milestone
+	for _, ms := range milestones {
+		if ms.Title == title {
+			return nil
+		}
+		if NormalizeTitle(ms.Title) == NormalizeTitle(title) {
+			colliding = append(colliding, ms)
+		}
+	}
+	if len(colliding) < 2 {
+		return nil
+	}
+	return colliding
+}
+
+func handle(ctx context.Context, gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent, repoMilestone map[string]plugins.Milestone) error {
+	return handleWithAuthorizer(ctx, gc, nil, log, e, repoMilestone)
+}
+
+// command is a /milestone command being handled. It holds what the preamble of
+// handleWithAuthorizer sets up for the handlers of the subcommands.
+type command struct {
+	ctx context.Context
+	// gc is wrapped to retry the server errors, and to audit the changes
+	// instead of making them in the audit-only repos
+	gc         githubClient
+	authorizer plugins.Authorizer
+	// teamAuthorizer is whether the default authorizer is used, as the hints
+	// about the maintainers teams only apply to it
+	teamAuthorizer bool
+	log            *logrus.Entry
+	e              *github.GenericCommentEvent
+	org, repo      string
+	edited         bool
+	// milestone is the configuration of the repo, which includes the owners
+	// of specific milestones once the user is authorized
+	milestone plugins.Milestone
+	// maintainers is the configuration of the repo without the owners of
+	// specific milestones, who may only set the milestones they own
+	maintainers plugins.Milestone
+}
+
+func newCommand(ctx context.Context, gc githubClient, authorizer plugins.Authorizer, log *logrus.Entry, e *github.GenericCommentEvent, milestone plugins.Milestone) *command {
+	maxAttempts := milestone.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	gc = &retryingClient{githubClient: gc, log: log, maxAttempts: maxAttempts}
+	if milestone.IsAuditOnly() {
+		gc = &auditingClient{githubClient: gc, actor: e.User.Login}
+	}
+	teamAuthorizer := authorizer == nil
+	if teamAuthorizer {
+		authorizer = &plugins.TeamAuthorizer{Client: &maintainersClient{ctx: ctx, gc: gc}}
+	}
+	return &command{
+		ctx:            ctx,
+		gc:             gc,
+		authorizer:     authorizer,
+		teamAuthorizer: teamAuthorizer,
+		log:            log,
+		e:              e,
+		org:            e.Repo.Owner.Login,
+		repo:           e.Repo.Name,
+		edited:         e.Action == github.GenericCommentActionEdited,
+		milestone:      milestone,
+		maintainers:    milestone,
+	}
+}
+
+func (c *command) respond(msg string, success bool) error {
+	return respond(c.ctx, c.gc, c.e, c.milestone, msg, success)
+}
+
+// reportDryRun comments about the change which the command would have made
+// if the repo wasn't dry-run.
+func (c *command) reportDryRun(msg string) error {
+	return c.gc.CreateComment(c.ctx, c.org, c.repo, c.e.Number, plugins.FormatResponseRaw(c.e.Body, c.e.HTMLURL, c.e.User.Login, msg))
+}
+
+// confirm returns whether successes are reported, which they are if
+// confirmations are configured or with reactions.
+func (c *command) confirm() bool {
+	return c.milestone.ConfirmsMilestone() || (c.milestone.UsesReactions() && c.e.CommentID != nil)
+}
+
+// handleWithAuthorizer handles the event, authorizing the commands with
+// authorizer, or with a plugins.TeamAuthorizer if it is nil.
+func handleWithAuthorizer(ctx context.Context, gc githubClient, authorizer plugins.Authorizer, log *logrus.Entry, e *github.GenericCommentEvent, repoMilestone map[string]plugins.Milestone) error {
+	edited := e.Action == github.GenericCommentActionEdited
+	if e.Action != github.GenericCommentActionCreated && !edited {
+		return nil
+	}
+
+	body := plugins.StripQuotedText(e.Body)
+	query := queryRegex.MatchString(body)
+	list := listRegex.MatchString(body)
+	who := whoRegex.MatchString(body)
+	milestoneMatch := milestoneRegex.FindStringSubmatch(body)
+	if !query && !list && !who && len(milestoneMatch) != 2 {
+		return nil
+	}
+
+	milestone := plugins.MilestoneFor(repoMilestone, e.Repo.Owner.Login, e.Repo.Name)
+	if edited && !milestone.HandlesEditedComments() {
+		return nil
+	}
+	c := newCommand(ctx, gc, authorizer, log, e, milestone)
+	switch {
+	case query:
+		// anyone may ask for the milestone, so this precedes the authorization
+		return reportMilestone(ctx, c.gc, log, e)
+	case list:
+		return listOpenMilestones(ctx, c.gc, log, e)
+	case who:
+		return listMaintainers(ctx, c.gc, log, e, milestone)
+	}
+	if milestoneMatch[1] == "" && milestone.CurrentMilestone == "" && len(milestone.BranchMilestones) == 0 {
+		// without a current milestone or branch milestones, `/milestone` needs an argument
+		return nil
+	}
+	if authorized, err := c.authorize(); !authorized {
+		return err
+	}
+
+	// closed milestones are only listed if they can be set
+	state := github.MilestoneStateOpen
+	if c.milestone.AllowsClosedMilestones() {
+		state = github.MilestoneStateAll
+	}
+	milestones, err := c.gc.ListMilestonesByState(ctx, c.org, c.repo, state)
+	if err != nil {
+		log.WithError(err).Errorf("Error listing the milestones in the %s/%s repo", c.org, c.repo)
+		return handleRateLimit(ctx, c.gc, e, c.milestone, err)
+	}
+	c.log = log.WithField("milestoneCount", len(milestones))
+	// a quoted milestone is always a title, even if it is one of the keywords
+	if move := moveRegex.FindStringSubmatch(milestoneMatch[1]); move != nil {
+		from, _ := unquote(move[1])
+		to, _ := unquote(move[2])
+		return moveMilestone(ctx, c.gc, c.authorizer, c.log, e, c.milestone, milestones, from, to)
+	}
+	argument, bulkBase := splitAllOpenPRs(milestoneMatch[1])
+	argument, create := splitCreateFlag(argument)
+	argument, issues := splitIssueRefs(argument)
+	proposedMilestone, quoted := unquote(argument)
+	if quoted {
+		return c.setMilestone(milestones, proposedMilestone, true, create, issues, bulkBase)
+	}
+	proposedMilestone, expectedMilestone := splitClearCondition(proposedMilestone)
+	proposedMilestone, clearConfirmed := splitClearConfirmation(proposedMilestone)
+	proposedMilestone, handled, err := c.resolveKeyword(milestones, proposedMilestone)
+	if handled {
+		return err
+	}
+	if isClearKeyword(proposedMilestone) {
+		return c.clearMilestone(proposedMilestone, clearConfirmed, expectedMilestone)
+	}
+	return c.setMilestone(milestones, proposedMilestone, false, create, issues, bulkBase)
+}
+
+// resolveMaintainers narrows the milestone maintainers of a PR to the owners
+// of the files it changes, if any, and adds the owners of specific milestones,
+// who are only allowed to set them once the milestone is known.
+func (c *command) resolveMaintainers() error {
+	if c.e.IsPR && len(c.milestone.PathMaintainers) > 0 {
+		changes, err := c.gc.GetPullRequestChanges(c.ctx, c.org, c.repo, c.e.Number)
+		if err != nil {
+			return err
+		}
+		var files []string
+		for _, change := range changes {
+			files = append(files, change.Filename)
+		}
+		// the owners of the changed files set the milestone instead of the milestone maintainers
+		c.milestone = c.milestone.ForChangedFiles(files)
+	}
+	c.maintainers = c.milestone
+	c.milestone = c.milestone.WithMilestoneMaintainers()
+	return nil
+}
+
+// authorize checks that the user may change the milestone, and tells them
+// how to get authorized otherwise. It returns whether the user is authorized,
+// along with the error of handling the command if they aren't.
+func (c *command) authorize() (bool, error) {
+	if err := c.resolveMaintainers(); err != nil {
+		return false, handleRateLimit(c.ctx, c.gc, c.e, c.milestone, err)
+	}
+	milestone := c.milestone
+	found, err := c.authorizer.IsAuthorized(c.org, c.e.User.Login, milestone)
+	if err != nil {
+		return false, handleAuthorizationError(c.ctx, c.gc, c.log, c.e, milestone, err)
+	}
+	if found {
+		return true, nil
+	}
+	// not in the milestone maintainers team
+	countCommand(c.org, c.repo, outcomeUnauthorized)
+	if c.teamAuthorizer {
+		if empty, err := plugins.HasNoMaintainers(&maintainersClient{ctx: c.ctx, gc: c.gc}, milestone, c.org); err != nil {
+			c.log.WithError(err).Warn("Error checking whether the milestone maintainers team has members.")
+		} else if empty {
+			c.log.Warn("The milestone maintainers team has no members, so every /milestone command is rejected.")
+			return false, c.gc.CreateComment(c.ctx, c.org, c.repo, c.e.Number, plugins.FormatResponseRaw(c.e.Body, c.e.HTMLURL, c.e.User.Login, noMaintainers))
+		}
+	}
+	links := teamLinks(c.org, milestone.MaintainersTeamSlugs())
+	if milestone.AuthorizesOrgMembers() {
+		links = fmt.Sprintf(orgLink, c.org, c.org)
+	}
+	info := plugins.UnauthorizedInfo{Org: c.org, Teams: milestone.MaintainersTeamSlugs(), TeamLinks: links, FriendlyName: milestone.FriendlyName(), Login: c.e.User.Login}
+	msg, err := plugins.UnauthorizedMessage(milestone, info, fmt.Sprintf(mustBeAuthorized, links, milestone.FriendlyName()))
+	if err != nil {
+		c.log.WithError(err).Warn("Using the default message to reject the user.")
+	}
+	if c.teamAuthorizer && !milestone.AuthorizesOrgMembers() {
+		parentMember, err := plugins.IsParentTeamMember(&maintainersClient{ctx: c.ctx, gc: c.gc}, milestone, c.org, c.e.User.Login)
+		if err != nil {
+			// the hint is optional, the user is told how to get authorized regardless
+			c.log.WithError(err).Warnf("Error checking whether %s is a member of the parent of the milestone maintainers team.", c.e.User.Login)
+		} else if parentMember {
+			msg += parentTeamMember
+		}
+	}
+	return false, c.respond(msg, false)
+}
+
+// resolveKeyword resolves the keywords standing for a milestone, e.g. `auto`,
+// to its title or number, and handles `/milestone undo`. It returns whether
+// the command was handled already, e.g. as the keyword couldn't be resolved,
+// along with the error of handling it.
+func (c *command) resolveKeyword(milestones []github.Milestone, proposedMilestone string) (string, bool, error) {
+	org, repo := c.org, c.repo
+	// special case, `/milestone` without an argument sets the current milestone
+	if proposedMilestone == "" && c.milestone.CurrentMilestone != "" {
+		proposedMilestone = c.milestone.CurrentMilestone
+	}
+
+	// special case, determine the milestone from the base branch of the PR
+	if proposedMilestone == "" || NormalizeTitle(proposedMilestone) == autoKeyword {
+		if !c.e.IsPR {
+			return "", true, c.respond(autoOnlyOnPRs, false)
+		}
+		pr, err := c.gc.GetPullRequest(c.ctx, org, repo, c.e.Number)
+		if err != nil {
+			c.log.WithError(err).Errorf("Error getting the pull request %s/%s#%d.", org, repo, c.e.Number)
+			return "", true, err
+		}
+		branchMilestone, ok := milestoneForBranch(c.milestone.BranchMilestones, pr.Base.Ref)
+		if !ok {
+			countCommand(org, repo, outcomeInvalid)
+			return "", true, c.respond(fmt.Sprintf(noBranchMilestone, pr.Base.Ref), false)
+		}
+		proposedMilestone = branchMilestone
+	}
+
+	// special case, determine the milestone from the labels of the issue or PR
+	if NormalizeTitle(proposedMilestone) == fromLabelsKeyword {
+		labels, err := c.gc.GetIssueLabels(c.ctx, org, repo, c.e.Number)
+		if err != nil {
+			c.log.WithError(err).Errorf("Error getting the labels of %s/%s#%d.", org, repo, c.e.Number)
+			return "", true, err
+		}
+		labelMilestones := milestonesForLabels(c.milestone.LabelMilestones, labels)
+		switch len(labelMilestones) {
+		case 0:
+			countCommand(org, repo, outcomeInvalid)
+			return "", true, c.respond(noLabelMilestone, false)
+		case 1:
+			for title := range labelMilestones {
+				proposedMilestone = title
+			}
+		default:
+			countCommand(org, repo, outcomeInvalid)
+			matches := make([]string, 0, len(labelMilestones))
+			for title, label := range labelMilestones {
+				matches = append(matches, fmt.Sprintf("`%s` → `%s`", label, title))
+			}
+			sort.Strings(matches)
+			return "", true, c.respond(fmt.Sprintf(conflictingLabels, strings.Join(matches, ", ")), false)
+		}
+	}
+
+	// special case, restore the milestone which the last change replaced
+	if NormalizeTitle(proposedMilestone) == undoKeyword {
+		return "", true, undoMilestone(c.ctx, c.gc, c.log, c.e, c.milestone)
+	}
+
+	// special case, set the open milestone which is due next
+	if NormalizeTitle(proposedMilestone) == nextKeyword {
+		next, ok := nextMilestone(milestones)
+		if !ok {
+			countCommand(org, repo, outcomeInvalid)
+			return "", true, c.respond(noNextMilestone, false)
+		}
+		proposedMilestone = fmt.Sprintf("#%d", next.Number)
+	}
+
+	// special case, a milestone URL refers to the milestone by its number
+	if match := milestoneURLRegex.FindStringSubmatch(proposedMilestone); match != nil {
+		if !strings.EqualFold(match[1], org) || !strings.EqualFold(match[2], repo) {
+			countCommand(org, repo, outcomeInvalid)
+			return "", true, c.respond(fmt.Sprintf(foreignMilestoneURL, match[1], match[2]), false)
+		}
+		proposedMilestone = "#" + match[3]
+	}
+	return proposedMilestone, false, nil
+}
+
+// clearMilestone handles `/milestone clear`, clearing the milestone if it is
+// expectedMilestone, unless that is empty.
+func (c *command) clearMilestone(keyword string, confirmed bool, expectedMilestone string) error {
+	org, repo, milestone := c.org, c.repo, c.milestone
+	if milestone.ClearTeam == "" && milestone.ClearTeamID == 0 && len(milestone.MilestoneMaintainers) > 0 {
+		// the owners of specific milestones may not clear the milestone
+		canClear, err := c.authorizer.IsAuthorized(org, c.e.User.Login, c.maintainers)
+		if err != nil {
+			return handleRateLimit(c.ctx, c.gc, c.e, milestone, err)
+		}
+		if !canClear {
+			countCommand(org, repo, outcomeUnauthorized)
+			return c.respond(fmt.Sprintf(mustBeAuthorizedToClear, teamLinks(org, c.maintainers.MaintainersTeamSlugs()), milestone.FriendlyName()), false)
+		}
+	}
+	if milestone.ClearTeam != "" || milestone.ClearTeamID != 0 {
+		clearTeam := plugins.Milestone{MaintainersTeam: milestone.ClearTeam, MaintainersID: milestone.ClearTeamID}
+		canClear, err := c.authorizer.IsAuthorized(org, c.e.User.Login, clearTeam)
+		if err != nil {
+			return handleAuthorizationError(c.ctx, c.gc, c.log, c.e, milestone, err)
+		}
+		if !canClear {
+			countCommand(org, repo, outcomeUnauthorized)
+			links := "the GitHub team allowed to clear the milestone"
+			if milestone.ClearTeam != "" {
+				links = teamLinks(org, []string{milestone.ClearTeam})
+			}
+			return c.respond(fmt.Sprintf(mustBeAuthorizedToClear, links, milestone.FriendlyName()), false)
+		}
+	}
+	if milestone.RequiresClearConfirmation() && !confirmed {
+		return c.respond(fmt.Sprintf(confirmClear, NormalizeTitle(keyword), confirmKeyword), false)
+	}
+	if milestone.IsDryRun() {
+		return c.reportDryRun(wouldClearMilestone)
+	}
+	// the previous milestone is also recorded to undo the change, which is
+	// only offered if it can be determined
+	previous, err := currentMilestone(c.ctx, c.gc, org, repo, c.e.Number)
+	if err != nil && (milestone.NotifyURL != "" || c.edited || expectedMilestone != "") {
+		c.log.WithError(err).Errorf("Error getting the milestone of %s/%s#%d.", org, repo, c.e.Number)
+		return err
+	}
+	undoable := err == nil
+	if expectedMilestone != "" && NormalizeTitle(previous) != NormalizeTitle(expectedMilestone) {
+		countCommand(org, repo, outcomeInvalid)
+		current := noMilestone
+		if previous != "" {
+			current = fmt.Sprintf("`%s`", previous)
+		}
+		return c.respond(fmt.Sprintf(clearConditionFailed, current, expectedMilestone), false)
+	}
+	if c.edited && previous == "" {
+		// the milestone was already cleared, e.g. by the command before the edit
 		return nil
 	}
+	if remaining := changeCooldowns.remaining(issueTarget(org, repo, c.e.Number)); remaining > 0 {
+		return c.respond(fmt.Sprintf(milestoneInCooldown, formatCooldown(remaining)), false)
+	}
+	if err := c.gc.ClearMilestone(c.ctx, org, repo, c.e.Number); err != nil {
+		c.log.WithError(err).Errorf("Error clearing the milestone for %s/%s#%d.", org, repo, c.e.Number)
+		return err
+	}
+	changeCooldowns.record(issueTarget(org, repo, c.e.Number), milestone.ChangeCooldownDuration)
+	if undoable {
+		milestoneUndos.record(issueTarget(org, repo, c.e.Number), previous)
+	}
+	countCommand(org, repo, outcomeCleared)
+	if milestone.TracksViaLabel() {
+		trackMilestone(c.ctx, c.gc, c.log, org, repo, c.e.Number, "")
+	}
+	if milestone.NotifyURL != "" {
+		notify(c.log, milestone, milestoneEvent{Org: org, Repo: repo, Number: c.e.Number, OldMilestone: previous, Actor: c.e.User.Login})
+	}
+	// clearing the milestone is always confirmed, as it leaves nothing to see
+	// on the issue, mentioning the previous milestone if it could be found
+	msg := milestoneCleared
+	if undoable {
+		msg += fmt.Sprintf(milestoneChangedBy, c.e.User.Login, orNone(previous), noMilestone)
+	}
+	return c.respond(msg, true)
+}
+
+// lookupMilestone looks up the number of the proposed milestone, creating the
+// milestone with `--create`, and returns the milestones including the created
+// one. It returns whether the command was handled already, e.g. as the
+// milestone doesn't exist, along with the error of handling it.
+func (c *command) lookupMilestone(milestones []github.Milestone, proposedMilestone string, quoted, create bool) ([]github.Milestone, int, bool, error) {
+	org, repo, milestone := c.org, c.repo, c.milestone
+	var alias string
+	if title, isAlias := resolveAlias(milestone.MilestoneAliases, proposedMilestone); isAlias && !quoted {
+		alias, proposedMilestone = NormalizeTitle(proposedMilestone), title
+	}
+	milestoneNumber, ok := ResolveMilestone(milestones, proposedMilestone)
+	if !ok && !quoted && milestone.MatchesVersionPrefix() {
+		if toggled, isVersion := toggleVersionPrefix(proposedMilestone); isVersion {
+			if number, found := ResolveMilestone(milestones, toggled); found {
+				proposedMilestone, milestoneNumber, ok = toggled, number, true
+			}
+		}
+	}
+	log := c.log.WithFields(logrus.Fields{"proposedMilestone": proposedMilestone, "milestoneNumber": milestoneNumber})
+	log.Info("Looked up the proposed milestone.")
+	if colliding := collidingMilestones(milestones, proposedMilestone); !ok && len(colliding) > 0 {
+		countCommand(org, repo, outcomeInvalid)
+		return nil, 0, true, c.respond(fmt.Sprintf(ambiguousMilestone, proposedMilestone, FormatMilestoneList(colliding)), false)
+	}
+	if !ok {
+		// look among the closed milestones only now, to explain why the
+		// milestone cannot be set
+		var closedMilestones []github.Milestone
+		if !milestone.AllowsClosedMilestones() {
+			var err error
+			if closedMilestones, err = c.gc.ListMilestonesByState(c.ctx, org, repo, github.MilestoneStateClosed); err != nil {
+				log.WithError(err).Errorf("Error listing the closed milestones in the %s/%s repo", org, repo)
+				return nil, 0, true, handleRateLimit(c.ctx, c.gc, c.e, milestone, err)
+			}
+		}
+		closedNumber, isClosed := ResolveMilestone(closedMilestones, proposedMilestone)
+		if !create || isClosed || numberRegex.MatchString(proposedMilestone) {
+			countCommand(org, repo, outcomeInvalid)
+			var msg string
+			if !isClosed && alias != "" {
+				// the alias is misconfigured rather than the command invalid
+				return nil, 0, true, c.respond(fmt.Sprintf(aliasNotFound, alias, proposedMilestone), false)
+			}
+			if !isClosed && milestone.IsSilentOnInvalid() {
+				log.Info("Not commenting on the invalid milestone, as the milestone plugin is configured to be silent on invalid milestones.")
+				return nil, 0, true, nil
+			}
+			if isClosed {
+				msg = fmt.Sprintf(closedMilestone, milestoneTitle(closedMilestones, closedNumber), FormatMilestoneList(OpenMilestones(milestones)), clearCommands())
+			} else {
+				msg = fmt.Sprintf(invalidMilestone, formatMilestoneListWithDueDates(milestones), clearCommands())
+				if suggestion, ok := closestMilestone(milestones, proposedMilestone); ok {
+					msg = fmt.Sprintf(didYouMean, FormatMilestoneList([]github.Milestone{suggestion})) + msg
+				}
+			}
+			return nil, 0, true, c.respond(msg, false)
+		}
+
+		// special case, create the missing milestone with `--create`
+		if !milestone.AllowsCreate() {
+			countCommand(org, repo, outcomeInvalid)
+			return nil, 0, true, c.respond(createNotAllowed, false)
+		}
+		if milestone.TitleRe != nil && !milestone.TitleRe.MatchString(proposedMilestone) {
+			countCommand(org, repo, outcomeInvalid)
+			return nil, 0, true, c.respond(fmt.Sprintf(titleNotAllowed, proposedMilestone, milestone.TitlePattern), false)
+		}
+		if milestone.IsDryRun() {
+			return nil, 0, true, c.reportDryRun(fmt.Sprintf(wouldCreateMilestone, proposedMilestone))
+		}
+		var err error
+		if milestoneNumber, err = c.gc.CreateMilestone(c.ctx, org, repo, proposedMilestone); err != nil {
+			log.WithError(err).Errorf("Error creating the milestone %s in the %s/%s repo", proposedMilestone, org, repo)
+			return nil, 0, true, handleRateLimit(c.ctx, c.gc, c.e, milestone, err)
+		}
+		milestones = append(milestones, github.Milestone{Title: proposedMilestone, Number: milestoneNumber, State: github.MilestoneStateOpen})
+	}
+	return milestones, milestoneNumber, false, nil
+}
+
+// setMilestone handles setting the proposed milestone, creating it first with
+// `--create`, on the issue or PR, on the referenced issues, or on the open PRs
+// against bulkBase.
+func (c *command) setMilestone(milestones []github.Milestone, proposedMilestone string, quoted, create bool, issues []int, bulkBase string) error {
+	org, repo, milestone := c.org, c.repo, c.milestone
+	milestones, milestoneNumber, handled, err := c.lookupMilestone(milestones, proposedMilestone, quoted, create)
+	if handled {
+		return err
+	}
+	title := milestoneTitle(milestones, milestoneNumber)
+	log := c.log.WithFields(logrus.Fields{"milestone": title, "milestoneNumber": milestoneNumber})
+	if outcome, msg, err := c.rejection(title); err != nil {
+		return handleRateLimit(c.ctx, c.gc, c.e, milestone, err)
+	} else if msg != "" {
+		countCommand(org, repo, outcome)
+		return c.respond(msg, false)
+	}
 
-	milestoneMatch := milestoneRegex.FindStringSubmatch(e.Body)
-	if len(milestoneMatch) != 2 {
+	// special case, set the milestone on all the open PRs against a branch
+	if bulkBase != "" {
+		if allowed, err := authorizeBulk(c.ctx, c.gc, c.authorizer, c.e, milestone); !allowed {
+			return err
+		}
+		prs, err := c.gc.GetPullRequests(c.ctx, org, repo)
+		if err != nil {
+			log.WithError(err).Errorf("Error listing the pull requests in the %s/%s repo", org, repo)
+			return handleRateLimit(c.ctx, c.gc, c.e, milestone, err)
+		}
+		issues = openPRsAgainst(prs, bulkBase)
+		if len(issues) == 0 {
+			return c.respond(fmt.Sprintf(noOpenPRs, bulkBase), false)
+		}
+	}
+
+	if milestone.IsDryRun() {
+		msg := fmt.Sprintf(wouldSetMilestone, title)
+		if len(issues) > 0 {
+			refs := make([]string, 0, len(issues))
+			for _, issue := range issues {
+				refs = append(refs, fmt.Sprintf("#%d", issue))
+			}
+			msg = fmt.Sprintf(wouldSetMilestoneOn, title, strings.Join(refs, ", "))
+		}
+		return c.reportDryRun(msg)
+	}
+	if len(issues) > 0 {
+		countCommand(org, repo, outcomeSet)
+		outcomes, allSet := setMilestoneOnIssues(c.ctx, c.gc, log, org, repo, issues, milestoneNumber)
+		msg := fmt.Sprintf(milestoneSetOnIssues, title, outcomes)
+		if bulkBase != "" {
+			msg = fmt.Sprintf(milestoneSetOnPRs, title, bulkBase, outcomes)
+		}
+		return c.respond(msg, allSet)
+	}
+	// the previous milestone is also recorded to undo the change, which is
+	// only offered if it can be determined
+	previous, err := currentMilestone(c.ctx, c.gc, org, repo, c.e.Number)
+	if err != nil && (c.confirm() || milestone.NotifyURL != "" || c.edited) {
+		log.WithError(err).Errorf("Error getting the milestone of %s/%s#%d.", org, repo, c.e.Number)
+		return err
+	}
+	undoable := err == nil
+	if c.edited && previous == title {
+		// the milestone was already set, e.g. by the command before the edit
 		return nil
 	}
+	if previous == title {
+		countCommand(org, repo, outcomeUnchanged)
+		return c.respond(fmt.Sprintf(milestoneAlreadySet, previous), true)
+	}
+	if remaining := changeCooldowns.remaining(issueTarget(org, repo, c.e.Number)); remaining > 0 {
+		return c.respond(fmt.Sprintf(milestoneInCooldown, formatCooldown(remaining)), false)
+	}
+	if err := c.applyMilestone(log, milestoneNumber, title, previous, undoable); err != nil {
+		return err
+	}
+	if milestone.WarnsOnClosedIssue() {
+		warnIfClosed(c.ctx, c.gc, log, c.e, title)
+	}
+
+	if milestone.PropagatesToLinkedIssues() && c.e.IsPR {
+		pr, err := c.gc.GetPullRequest(c.ctx, org, repo, c.e.Number)
+		if err != nil {
+			log.WithError(err).Errorf("Error getting the pull request %s/%s#%d.", org, repo, c.e.Number)
+			return err
+		}
+		for _, issue := range linkedIssues(pr.Body) {
+			if issue == c.e.Number {
+				continue
+			}
+			if err := c.gc.SetMilestone(c.ctx, org, repo, issue, milestoneNumber); err != nil {
+				log.WithError(err).Errorf("Error adding the milestone %s to %s/%s#%d.", title, org, repo, issue)
+				return err
+			}
+		}
+	}
+
+	if c.confirm() {
+		msg := fmt.Sprintf(milestoneSet, title) + fmt.Sprintf(milestoneChangedBy, c.e.User.Login, orNone(previous), title)
+		return c.respond(msg, true)
+	}
+	return nil
+}
+
+// rejection returns why the user may not set the milestone titled title, if
+// they may not: the title doesn't match the title_pattern, the milestone is
+// owned by other users, or it is frozen. The outcome of the command is
+// returned along with the message.
+func (c *command) rejection(title string) (string, string, error) {
+	org, milestone := c.org, c.milestone
+	if milestone.TitleRe != nil && !milestone.TitleRe.MatchString(title) {
+		return outcomeInvalid, fmt.Sprintf(titleNotAllowed, title, milestone.TitlePattern), nil
+	}
+	if len(milestone.MilestoneMaintainers) > 0 {
+		owners := c.maintainers.ForMilestone(title)
+		allowed, err := c.authorizer.IsAuthorized(org, c.e.User.Login, owners)
+		if err != nil {
+			return "", "", err
+		}
+		if !allowed {
+			return outcomeUnauthorized, fmt.Sprintf(mustBeMilestoneOwner, teamLinks(org, owners.MaintainersTeamSlugs()), title, milestone.FriendlyName()), nil
+		}
+	}
+	if freeze, ok := milestoneFreeze(milestone.MilestoneFreezes, title, time.Now()); ok {
+		allowed := false
+		if freeze.FreezeTeam != "" {
+			freezeTeam := plugins.Milestone{MaintainersTeam: freeze.FreezeTeam}
+			var err error
+			if allowed, err = c.authorizer.IsAuthorized(org, c.e.User.Login, freezeTeam); err != nil {
+				return "", "", err
+			}
+		}
+		if !allowed {
+			msg := fmt.Sprintf(milestoneFrozen, freeze.Milestone, freeze.FreezeTime.Format(time.RFC3339))
+			if freeze.FreezeTeam != "" {
+				msg += fmt.Sprintf(frozenExceptFor, teamLinks(org, []string{freeze.FreezeTeam}))
+			}
+			return outcomeUnauthorized, msg, nil
+		}
+	}
+	return "", "", nil
+}
 
+// applyMilestone sets the milestone of the issue or PR, replacing previous,
+// and records the change for the cooldown and to undo it if it is undoable.
+func (c *command) applyMilestone(log *logrus.Entry, milestoneNumber int, title, previous string, undoable bool) error {
+	org, repo, milestone := c.org, c.repo, c.milestone
+	if err := c.gc.SetMilestone(c.ctx, org, repo, c.e.Number, milestoneNumber); err != nil {
+		log.WithError(err).Errorf("Error adding the milestone %s to %s/%s#%d.", title, org, repo, c.e.Number)
+		return err
+	}
+	changeCooldowns.record(issueTarget(org, repo, c.e.Number), milestone.ChangeCooldownDuration)
+	if undoable {
+		milestoneUndos.record(issueTarget(org, repo, c.e.Number), previous)
+	}
+	countCommand(org, repo, outcomeSet)
+	if milestone.TracksViaLabel() {
+		trackMilestone(c.ctx, c.gc, log, org, repo, c.e.Number, title)
+	}
+	if milestone.NotifyURL != "" {
+		notify(log, milestone, milestoneEvent{Org: org, Repo: repo, Number: c.e.Number, OldMilestone: previous, NewMilestone: title, Actor: c.e.User.Login})
+	}
+	return nil
+}
+
+// authorizeBulk returns whether the user may set the milestone of many issues or
+// PRs at once, having responded with the reason if not.
+func authorizeBulk(ctx context.Context, gc githubClient, authorizer plugins.Authorizer, e *github.GenericCommentEvent, milestone plugins.Milestone) (bool, error) {
 	org := e.Repo.Owner.Login
 	repo := e.Repo.Name
-
-	milestone, exists := repoMilestone[fmt.Sprintf("%s/%s", org, repo)]
-	if !exists {
-		// fallback default
-		milestone = repoMilestone[""]
+	if milestone.BulkTeam == "" {
+		countCommand(org, repo, outcomeUnauthorized)
+		return false, respond(ctx, gc, e, milestone, bulkNotAllowed, false)
 	}
-	milestoneMaintainers, err := determineMaintainers(gc, milestone, org)
+	bulkTeam := plugins.Milestone{MaintainersTeam: milestone.BulkTeam}
+	allowed, err := authorizer.IsAuthorized(org, e.User.Login, bulkTeam)
 	if err != nil {
+		return false, handleRateLimit(ctx, gc, e, milestone, err)
+	}
+	if !allowed {
+		countCommand(org, repo, outcomeUnauthorized)
+		msg := fmt.Sprintf(mustBeAuthorizedForBulk, teamLinks(org, []string{milestone.BulkTeam}))
+		return false, respond(ctx, gc, e, milestone, msg, false)
+	}
+	return true, nil
+}
+
+// moveMilestone moves the open issues and PRs of the milestone titled from to
+// the milestone titled to, e.g. when closing out a release, and comments with
+// a summary.
+func moveMilestone(ctx context.Context, gc githubClient, authorizer plugins.Authorizer, log *logrus.Entry, e *github.GenericCommentEvent, milestone plugins.Milestone, milestones []github.Milestone, from, to string) error {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+	if allowed, err := authorizeBulk(ctx, gc, authorizer, e, milestone); !allowed {
 		return err
 	}
-	found := false
-	for _, person := range milestoneMaintainers {
-		login := github.NormLogin(e.User.Login)
-		if github.NormLogin(person.Login) == login {
-			found = true
-			break
+	var numbers []int
+	for _, title := range []string{from, to} {
+		number, ok := ResolveMilestone(milestones, title)
+		if !ok {
+			countCommand(org, repo, outcomeInvalid)
+			msg := fmt.Sprintf(unknownMoveMilestone, title, FormatMilestoneList(milestones))
+			return respond(ctx, gc, e, milestone, msg, false)
+		}
+		numbers = append(numbers, number)
+	}
+	from, to = milestoneTitle(milestones, numbers[0]), milestoneTitle(milestones, numbers[1])
+
+	// the search is paginated by the client, so all the issues are found. It
+	// is made as the org, as searching without one fails for GitHub apps.
+	query := fmt.Sprintf("repo:%s/%s is:open milestone:%q", org, repo, from)
+	issues, err := gc.FindIssuesWithOrg(ctx, org, query, "", false)
+	if err != nil {
+		log.WithError(err).Errorf("Error searching the issues in the milestone %s of %s/%s.", from, org, repo)
+		return handleRateLimit(ctx, gc, e, milestone, err)
+	}
+	if len(issues) == 0 {
+		return respond(ctx, gc, e, milestone, fmt.Sprintf(nothingToMove, from), false)
+	}
+	if milestone.IsDryRun() {
+		msg := fmt.Sprintf(wouldMoveMilestones, len(issues), from, to)
+		return gc.CreateComment(ctx, org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, msg))
+	}
+	countCommand(org, repo, outcomeSet)
+	var failed []string
+	for _, issue := range issues {
+		if err := gc.SetMilestone(ctx, org, repo, issue.Number, numbers[1]); err != nil {
+			log.WithError(err).Errorf("Error moving %s/%s#%d to the milestone %s.", org, repo, issue.Number, to)
+			failed = append(failed, fmt.Sprintf("#%d", issue.Number))
 		}
 	}
-	if !found {
-		// not in the milestone maintainers team
-		msg := fmt.Sprintf(mustBeAuthorized, org, milestone.MaintainersTeam, org, milestone.MaintainersTeam, milestone.MaintainersFriendlyName)
-		return gc.CreateComment(org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, msg))
+	msg := fmt.Sprintf(milestonesMoved, len(issues)-len(failed), from, to)
+	if len(failed) > 0 {
+		msg += fmt.Sprintf(milestonesNotMoved, len(failed), strings.Join(failed, ", "))
 	}
+	return respond(ctx, gc, e, milestone, msg, len(failed) == 0)
+}
 
-	milestones, err := gc.ListMilestones(org, repo)
+// milestoneFreeze returns the freeze of the milestone titled title if it is
+// frozen at now.
+func milestoneFreeze(freezes []plugins.MilestoneFreeze, title string, now time.Time) (plugins.MilestoneFreeze, bool) {
+	for _, freeze := range freezes {
+		if NormalizeTitle(freeze.Milestone) == NormalizeTitle(title) && !now.Before(freeze.FreezeTime) {
+			return freeze, true
+		}
+	}
+	return plugins.MilestoneFreeze{}, false
+}
+
+// warnIfClosed comments with a warning if the issue or PR which milestone was
+// set is closed. The warning is best-effort, as the milestone is already set.
+func warnIfClosed(ctx context.Context, gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent, title string) {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+	issue, err := gc.GetIssue(ctx, org, repo, e.Number)
+	if err != nil {
+		log.WithError(err).Warnf("Error getting %s/%s#%d to check whether it is closed.", org, repo, e.Number)
+		return
+	}
+	if issue.State != "closed" {
+		return
+	}
+	msg := fmt.Sprintf(closedIssueWarning, title)
+	if err := gc.CreateComment(ctx, org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, msg)); err != nil {
+		log.WithError(err).Warnf("Error warning that %s/%s#%d is closed.", org, repo, e.Number)
+	}
+}
+
+// reportMilestone comments with the milestone of the issue or PR and its due
+// date, if any.
+func reportMilestone(ctx context.Context, gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent) error {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+	issue, err := gc.GetIssue(ctx, org, repo, e.Number)
+	if err != nil {
+		log.WithError(err).Errorf("Error getting %s/%s#%d.", org, repo, e.Number)
+		return err
+	}
+	countCommand(org, repo, outcomeQueried)
+	msg := noMilestoneIsSet
+	if issue.Milestone.Title != "" {
+		msg = fmt.Sprintf(milestoneIs, issue.Milestone.Title)
+		if issue.Milestone.DueOn != nil {
+			msg += fmt.Sprintf(milestoneDueOn, issue.Milestone.DueOn.Format("2006-01-02"))
+		}
+	}
+	return gc.CreateComment(ctx, org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, msg))
+}
+
+// listOpenMilestones comments with the open milestones of the repo.
+func listOpenMilestones(ctx context.Context, gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent) error {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+	milestones, err := gc.ListMilestonesByState(ctx, org, repo, github.MilestoneStateOpen)
 	if err != nil {
 		log.WithError(err).Errorf("Error listing the milestones in the %s/%s repo", org, repo)
 		return err
 	}
-	proposedMilestone := milestoneMatch[1]
+	countCommand(org, repo, outcomeQueried)
+	msg := noOpenMilestones
+	if len(milestones) > 0 {
+		msg = fmt.Sprintf(openMilestones, FormatMilestoneList(OpenMilestones(milestones)))
+	}
+	return gc.CreateComment(ctx, org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, msg))
+}
 
-	// special case, if the clear keyword is used
-	if proposedMilestone == clearKeyword {
-		if err := gc.ClearMilestone(org, repo, e.Number); err != nil {
-			log.WithError(err).Errorf("Error clearing the milestone for %s/%s#%d.", org, repo, e.Number)
+// listMaintainers comments with the milestone maintainers, listing at most the
+// configured number of them.
+func listMaintainers(ctx context.Context, gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent, milestone plugins.Milestone) error {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+	countCommand(org, repo, outcomeQueried)
+	if milestone.AuthorizesOrgMembers() {
+		msg := fmt.Sprintf(orgMembersMaintainers, fmt.Sprintf(orgLink, org, org))
+		return gc.CreateComment(ctx, org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, msg))
+	}
+	logins, err := plugins.MilestoneMaintainerLogins(&maintainersClient{ctx: ctx, gc: gc}, milestone, org)
+	var notFound plugins.TeamNotFoundError
+	if errors.As(err, &notFound) {
+		log.WithError(err).Error("The milestone maintainers team is misconfigured.")
+		return gc.CreateComment(ctx, org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, fmt.Sprintf(teamNotFound, notFound)))
+	}
+	if err != nil {
+		log.WithError(err).Errorf("Error listing the milestone maintainers of the %s/%s repo.", org, repo)
+		return handleRateLimit(ctx, gc, e, milestone, err)
+	}
+	if len(logins) == 0 {
+		return gc.CreateComment(ctx, org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, noMaintainers))
+	}
+	limit := milestone.MaintainersListLimit
+	if limit == 0 {
+		limit = defaultMaintainersListLimit
+	}
+	listed := logins
+	if len(logins) > limit {
+		listed = logins[:limit]
+	}
+	// the logins aren't mentioned, so as not to notify every maintainer
+	names := make([]string, 0, len(listed))
+	for _, login := range listed {
+		names = append(names, fmt.Sprintf("`%s`", login))
+	}
+	list := strings.Join(names, ", ")
+	if len(logins) > limit {
+		list = fmt.Sprintf(moreMaintainers, list, len(logins)-limit, teamLinks(org, milestone.MaintainersTeamSlugs()))
+	}
+	return gc.CreateComment(ctx, org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, fmt.Sprintf(maintainersAre, list)))
+}
+
+// FormatMilestoneList returns the titles of the milestones in backticks,
+// sorted and separated by commas, or the empty string if there are none.
+func FormatMilestoneList(milestones []github.Milestone) string {
+	titles := make([]string, 0, len(milestones))
+	for _, ms := range milestones {
+		titles = append(titles, fmt.Sprintf("`%s`", ms.Title))
+	}
+	sort.Strings(titles)
+	return strings.Join(titles, ", ")
+}
+
+// formatMilestoneListWithDueDates formats the milestones as FormatMilestoneList
+// does, followed by the due dates of the open milestones which have one, e.g.
+// `v1.20` (due 2024-06-01), so that users can tell the milestones apart.
+func formatMilestoneListWithDueDates(milestones []github.Milestone) string {
+	titles := make([]string, 0, len(milestones))
+	for _, ms := range milestones {
+		title := fmt.Sprintf("`%s`", ms.Title)
+		if ms.DueOn != nil && ms.State != github.MilestoneStateClosed {
+			title += fmt.Sprintf(" (due %s)", ms.DueOn.Format("2006-01-02"))
 		}
-		return nil
+		titles = append(titles, title)
 	}
+	sort.Strings(titles)
+	return strings.Join(titles, ", ")
+}
 
-	milestoneMap := BuildMilestoneMap(milestones)
-	milestoneNumber, ok := milestoneMap[proposedMilestone]
-	if !ok {
-		slice := make([]string, 0, len(milestoneMap))
-		for k := range milestoneMap {
-			slice = append(slice, fmt.Sprintf("`%s`", k))
+// trackMilestone labels the issue or PR with the tracking label of the milestone
+// with the given title, or with none if title is empty, and removes any other
+// tracking label. Failures are only logged, as the milestone is already set.
+func trackMilestone(ctx context.Context, gc githubClient, log *logrus.Entry, org, repo string, number int, title string) {
+	labels, err := gc.GetIssueLabels(ctx, org, repo, number)
+	if err != nil {
+		log.WithError(err).Errorf("Error getting the labels of %s/%s#%d.", org, repo, number)
+		return
+	}
+	tracking := ""
+	if title != "" {
+		tracking = trackingLabelPrefix + title
+	}
+	tracked := false
+	for _, label := range labels {
+		if label.Name == tracking {
+			tracked = true
+			continue
+		}
+		if !strings.HasPrefix(label.Name, trackingLabelPrefix) {
+			continue
 		}
-		sort.Strings(slice)
+		if err := gc.RemoveLabel(ctx, org, repo, number, label.Name); err != nil {
+			log.WithError(err).Errorf("Error removing the label %q from %s/%s#%d.", label.Name, org, repo, number)
+		}
+	}
+	if tracking == "" || tracked {
+		return
+	}
+	if err := gc.AddLabel(ctx, org, repo, number, tracking); err != nil {
+		log.WithError(err).Errorf("Error adding the label %q to %s/%s#%d.", tracking, org, repo, number)
+	}
+}
 
-		msg := fmt.Sprintf(invalidMilestone, strings.Join(slice, ", "), clearKeyword)
-		return gc.CreateComment(org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, msg))
+// handleRateLimit asks the user to retry the command shortly and returns
+// ErrRateLimited if err is due to GitHub rate limiting, and otherwise err.
+func handleRateLimit(ctx context.Context, gc githubClient, e *github.GenericCommentEvent, milestone plugins.Milestone, err error) error {
+	if !github.IsRateLimited(err) {
+		return err
+	}
+	countCommand(e.Repo.Owner.Login, e.Repo.Name, outcomeRateLimited)
+	if commentErr := respond(ctx, gc, e, milestone, rateLimited, false); commentErr != nil {
+		return fmt.Errorf("%w: %v, and failed to comment: %v", ErrRateLimited, err, commentErr)
 	}
+	return fmt.Errorf("%w: %v", ErrRateLimited, err)
+}
 
-	if err := gc.SetMilestone(org, repo, e.Number, milestoneNumber); err != nil {
-		log.WithError(err).Errorf("Error adding the milestone %s to %s/%s#%d.", proposedMilestone, org, repo, e.Number)
+// handleAuthorizationError responds to an error authorizing the user: a team
+// which doesn't exist or can't be looked up is reported as a misconfiguration,
+// and rate limiting is handled by handleRateLimit.
+func handleAuthorizationError(ctx context.Context, gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent, milestone plugins.Milestone, err error) error {
+	var notFound plugins.TeamNotFoundError
+	if errors.As(err, &notFound) {
+		// a reaction can't explain a misconfiguration, so this is always a comment
+		log.WithError(err).Error("The milestone maintainers team is misconfigured.")
+		return gc.CreateComment(ctx, e.Repo.Owner.Login, e.Repo.Name, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, fmt.Sprintf(teamNotFound, notFound)))
 	}
+	var missing plugins.MissingPermissionError
+	if errors.As(err, &missing) {
+		log.WithError(err).Error("Prow lacks the permission to look up the milestone maintainers.")
+		return gc.CreateComment(ctx, e.Repo.Owner.Login, e.Repo.Name, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, fmt.Sprintf(missingPermission, missing.Org)))
+	}
+	return handleRateLimit(ctx, gc, e, milestone, err)
+}
 
-	return nil
+// respond comments msg on the issue or PR of the command, or reacts to the
+// comment with the command if reactions are configured to be used instead.
+func respond(ctx context.Context, gc githubClient, e *github.GenericCommentEvent, milestone plugins.Milestone, msg string, success bool) error {
+	if milestone.UsesReactions() && e.CommentID != nil {
+		reaction := failureReaction
+		if success {
+			reaction = successReaction
+		}
+		return gc.CreateCommentReaction(ctx, e.Repo.Owner.Login, e.Repo.Name, *e.CommentID, reaction)
+	}
+	return gc.CreateComment(ctx, e.Repo.Owner.Login, e.Repo.Name, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, msg))
+}
+
+// isClearKeyword returns whether proposed is one of the keywords which clear the milestone.
+func isClearKeyword(proposed string) bool {
+	normalized := NormalizeTitle(proposed)
+	for _, keyword := range clearKeywords {
+		if normalized == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// clearCommands formats the commands which clear the milestone, e.g. for invalidMilestone.
+func clearCommands() string {
+	commands := make([]string, 0, len(clearKeywords))
+	for _, keyword := range clearKeywords {
+		commands = append(commands, fmt.Sprintf("`/milestone %s`", keyword))
+	}
+	return strings.Join(commands[:len(commands)-1], ", ") + " or " + commands[len(commands)-1]
+}
+
+// currentMilestone returns the title of the milestone of an issue or PR, or ""
+// if it has none.
+func currentMilestone(ctx context.Context, gc githubClient, org, repo string, number int) (string, error) {
+	issue, err := gc.GetIssue(ctx, org, repo, number)
+	if err != nil {
+		return "", err
+	}
+	return issue.Milestone.Title, nil
+}
+
+// orNone returns title, or noMilestone if title is empty.
+func orNone(title string) string {
+	if title == "" {
+		return noMilestone
+	}
+	return title
+}
+
+// splitCreateFlag splits the `--create` flag off the end of the argument, as in
+// `/milestone v1.21 --create`, and returns whether it was given.
+func splitCreateFlag(argument string) (string, bool) {
+	fields := strings.Fields(argument)
+	if len(fields) == 0 || fields[len(fields)-1] != createFlag {
+		return argument, false
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(argument), createFlag)), true
+}
+
+// splitClearConfirmation splits the confirmation keyword off a clear keyword,
+// e.g. `/milestone clear confirm`, and returns whether it was there.
+func splitClearConfirmation(proposed string) (string, bool) {
+	fields := strings.Fields(proposed)
+	if len(fields) != 2 || fields[1] != confirmKeyword || !isClearKeyword(fields[0]) {
+		return proposed, false
+	}
+	return fields[0], true
+}
+
+// splitClearCondition splits the milestone expected to be cleared off a clear
+// keyword, e.g. `/milestone clear if:v1.20`, and returns it, or "" if there is
+// no condition.
+func splitClearCondition(proposed string) (string, string) {
+	command, condition, found := strings.Cut(proposed, " "+clearConditionPrefix)
+	fields := strings.Fields(command)
+	if !found || len(fields) == 0 || !isClearKeyword(fields[0]) {
+		return proposed, ""
+	}
+	expected, _ := unquote(strings.TrimSpace(condition))
+	if expected == "" {
+		return proposed, ""
+	}
+	return strings.TrimSpace(command), expected
+}
+
+// splitAllOpenPRs splits the `--all-open-prs base:<branch>` flag off the end of
+// the argument, as in `/milestone v1.20 --all-open-prs base:release-1.20`, and
+// returns the branch, or "" if the flag wasn't given.
+func splitAllOpenPRs(argument string) (string, string) {
+	fields := strings.Fields(argument)
+	if len(fields) < 2 || fields[len(fields)-2] != allOpenPRsFlag || !strings.HasPrefix(fields[len(fields)-1], basePrefix) {
+		return argument, ""
+	}
+	base := strings.TrimPrefix(fields[len(fields)-1], basePrefix)
+	if base == "" {
+		return argument, ""
+	}
+	return strings.Join(fields[:len(fields)-2], " "), base
+}
+
+// openPRsAgainst returns the numbers of the open PRs against the base branch.
+func openPRsAgainst(prs []github.PullRequest, base string) []int {
+	var numbers []int
+	for _, pr := range prs {
+		if pr.State == github.PullRequestStateOpen && pr.Base.Ref == base {
+			numbers = append(numbers, pr.Number)
+		}
+	}
+	return numbers
+}
+
+// splitIssueRefs splits the issue references off the end of the argument of a
+// batch command such as `/milestone v1.20 #101 #102`.
+func splitIssueRefs(argument string) (string, []int) {
+	match := issueRefsRegex.FindStringSubmatch(argument)
+	if match == nil {
+		return argument, nil
+	}
+	var issues []int
+	seen := map[int]bool{}
+	for _, ref := range strings.Fields(match[2]) {
+		number, err := strconv.Atoi(strings.TrimPrefix(ref, "#"))
+		if err != nil || seen[number] {
+			continue
+		}
+		seen[number] = true
+		issues = append(issues, number)
+	}
+	return match[1], issues
+}
+
+// setMilestoneOnIssues sets the milestone on each of the given issues which
+// exists. It returns a summary of the outcome for each issue, and whether the
+// milestone was set on all the issues.
+func setMilestoneOnIssues(ctx context.Context, gc githubClient, log *logrus.Entry, org, repo string, issues []int, milestoneNumber int) (string, bool) {
+	outcomes := make([]string, 0, len(issues))
+	allSet := true
+	for _, issue := range issues {
+		if _, err := gc.GetIssue(ctx, org, repo, issue); err != nil {
+			log.WithError(err).Infof("Could not get the issue %s/%s#%d.", org, repo, issue)
+			outcomes = append(outcomes, fmt.Sprintf(issueNotFound, issue))
+			allSet = false
+			continue
+		}
+		if err := gc.SetMilestone(ctx, org, repo, issue, milestoneNumber); err != nil {
+			log.WithError(err).Errorf("Error adding the milestone %d to %s/%s#%d.", milestoneNumber, org, repo, issue)
+			outcomes = append(outcomes, fmt.Sprintf(issueMilestoneFailed, issue))
+			allSet = false
+			continue
+		}
+		outcomes = append(outcomes, fmt.Sprintf(issueMilestoneSet, issue))
+	}
+	return strings.Join(outcomes, "\n"), allSet
+}
+
+// unquote strips the double quotes around a milestone title such as
+// `"Q3 Planning"`, and returns whether the title was quoted.
+func unquote(title string) (string, bool) {
+	if len(title) >= 2 && strings.HasPrefix(title, `"`) && strings.HasSuffix(title, `"`) {
+		return title[1 : len(title)-1], true
+	}
+	return title, false
+}
+
+// resolveAlias returns the title of the milestone the alias proposed refers
+// to, matching aliases regardless of case, and whether it is an alias.
+func resolveAlias(aliases map[string]string, proposed string) (string, bool) {
+	normalized := NormalizeTitle(proposed)
+	for alias, title := range aliases {
+		if NormalizeTitle(alias) == normalized {
+			return title, true
+		}
+	}
+	return "", false
+}
+
+// milestoneForBranch returns the milestone of the first branch milestone matching branch.
+func milestoneForBranch(branchMilestones []plugins.BranchMilestone, branch string) (string, bool) {
+	for _, bm := range branchMilestones {
+		if bm.BranchRe != nil && bm.BranchRe.MatchString(branch) {
+			return bm.Milestone, true
+		}
+	}
+	return "", false
+}
+
+// milestonesForLabels maps the distinct milestones which labels map to, per
+// the first matching entry of labelMilestones, to the first label mapping to
+// each of them.
+func milestonesForLabels(labelMilestones []plugins.LabelMilestone, labels []github.Label) map[string]string {
+	milestones := map[string]string{}
+	for _, label := range labels {
+		for _, lm := range labelMilestones {
+			if lm.LabelRe == nil || !lm.LabelRe.MatchString(label.Name) {
+				continue
+			}
+			if _, ok := milestones[lm.Milestone]; !ok {
+				milestones[lm.Milestone] = label.Name
+			}
+			break
+		}
+	}
+	return milestones
 }
 
-func determineMaintainers(gc githubClient, milestone plugins.Milestone, org string) ([]github.TeamMember, error) {
-	if milestone.MaintainersTeam != "" {
-		return gc.ListTeamMembersBySlug(org, milestone.MaintainersTeam, github.RoleAll)
+// linkedIssues returns the numbers of the issues in the same repo which a PR body
+// closes using one of GitHub's closing keywords, e.g. "Fixes #123".
+func linkedIssues(body string) []int {
+	var issues []int
+	seen := map[int]bool{}
+	for _, match := range closingRegex.FindAllStringSubmatch(body, -1) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil || seen[number] {
+			continue
+		}
+		seen[number] = true
+		issues = append(issues, number)
+	}
+	return issues
+}
+
+// ResolveMilestone returns the number of the milestone referred to by title,
+// which is either a milestone title or a milestone number in the form `#42` or
+// `number:42`. Titles match regardless of case and whitespace, as with
+// NormalizeTitle, unless several titles match, in which case only the exact
+// title matches.
+func ResolveMilestone(milestones []github.Milestone, title string) (int, bool) {
+	if match := numberRegex.FindStringSubmatch(title); match != nil {
+		number, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, false
+		}
+		for _, ms := range milestones {
+			if ms.Number == number {
+				return number, true
+			}
+		}
+		return 0, false
+	}
+	return ResolveMilestoneTitle(milestones, title)
+}
+
+// ResolveMilestoneTitle returns the number of the milestone with the given
+// title, preferring a milestone with exactly that title to those matching it
+// under normalization.
+func ResolveMilestoneTitle(milestones []github.Milestone, title string) (int, bool) {
+	for _, ms := range milestones {
+		if ms.Title == title {
+			return ms.Number, true
+		}
+	}
+	number, ok := BuildMilestoneMap(milestones)[NormalizeTitle(title)]
+	return number, ok
+}
+
+// toggleVersionPrefix adds the leading `v` to a version such as `1.20`, or
+// removes it from a version such as `v1.20`, and returns whether the title is
+// such a version.
+func toggleVersionPrefix(title string) (string, bool) {
+	if len(title) > 1 && (title[0] == 'v' || title[0] == 'V') && unicode.IsDigit(rune(title[1])) {
+		return title[1:], true
+	}
+	if len(title) > 0 && unicode.IsDigit(rune(title[0])) {
+		return "v" + title, true
+	}
+	return title, false
+}
+
+// nextMilestone returns the open milestone with the earliest due date in the
+// future. Milestones without a due date are never the next one.
+func nextMilestone(milestones []github.Milestone) (github.Milestone, bool) {
+	now := time.Now()
+	var next github.Milestone
+	found := false
+	for _, ms := range OpenMilestones(milestones) {
+		if ms.DueOn == nil || !ms.DueOn.After(now) {
+			continue
+		}
+		if !found || ms.DueOn.Before(*next.DueOn) {
+			next = ms
+			found = true
+		}
+	}
+	return next, found
+}
+
+// OpenMilestones returns the milestones which have not been closed.
+func OpenMilestones(milestones []github.Milestone) []github.Milestone {
+	var open []github.Milestone
+	for _, ms := range milestones {
+		if ms.State != github.MilestoneStateClosed {
+			open = append(open, ms)
+		}
+	}
+	return open
+}
+
+// milestoneTitle returns the canonical GitHub title of the milestone with the given number.
+func milestoneTitle(milestones []github.Milestone, number int) string {
+	for _, ms := range milestones {
+		if ms.Number == number {
+			return ms.Title
+		}
+	}
+	return ""
+}
+
+// closestMilestone returns the milestone nearest to the proposed one, and
+// whether it is within maxSuggestionDistance.
+func closestMilestone(milestones []github.Milestone, proposed string) (github.Milestone, bool) {
+	proposed = NormalizeTitle(proposed)
+	var closest github.Milestone
+	best := maxSuggestionDistance + 1
+	for _, ms := range milestones {
+		d := levenshtein(proposed, NormalizeTitle(ms.Title))
+		if d < best || (d == best && ms.Title < closest.Title) {
+			best = d
+			closest = ms
+		}
+	}
+	return closest, best <= maxSuggestionDistance
+}
+
+// levenshtein computes the edit distance between a and b.
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
+
+// teamLinks formats links to the given maintainers teams for use in mustBeAuthorized.
+func teamLinks(org string, teams []string) string {
+	if len(teams) == 0 {
+		return "the milestone maintainers GitHub team"
+	}
+	links := make([]string, 0, len(teams))
+	for _, team := range teams {
+		links = append(links, fmt.Sprintf(teamLink, org, team, org, plugins.TeamSlug(team)))
 	}
-	return gc.ListTeamMembersBySlug(org, milestone.MaintainersID, github.RoleAll)
+	return strings.Join(links, " or ")
 }
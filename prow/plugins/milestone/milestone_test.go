@@ -17,9 +17,24 @@ limitations under the License.
 package milestone
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	utilpointer "k8s.io/utils/pointer"
 
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/github/fakegithub"
@@ -58,6 +73,27 @@ func TestMilestoneStatus(t *testing.T) {
 			previousMilestone: 0,
 			expectedMilestone: 0,
 		},
+		{
+			name:              "Update the milestone when the title differs in case",
+			body:              "/milestone V1.0",
+			commenter:         "sig-lead",
+			previousMilestone: 0,
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Update the milestone when the title differs in case and has trailing whitespace",
+			body:              "/milestone V1.0 ",
+			commenter:         "sig-lead",
+			previousMilestone: 0,
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Update the milestone when a sig-lead with different login casing uses the command",
+			body:              "/milestone v1.0",
+			commenter:         "SIG-Lead",
+			previousMilestone: 0,
+			expectedMilestone: 1,
+		},
 		{
 			name:              "Don't update the milestone if a sig-follow enters a valid milestone",
 			body:              "/milestone v1.0",
@@ -124,7 +160,7 @@ func TestMilestoneStatus(t *testing.T) {
 			repoMilestone["org/repo"] = plugins.Milestone{MaintainersTeam: maintainersTeamName}
 		}
 
-		if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
 			t.Errorf("(%s): Unexpected error from handle: %v.", tc.name, err)
 			continue
 		}
@@ -135,3 +171,4002 @@ func TestMilestoneStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestMultipleMaintainersTeams(t *testing.T) {
+	testcases := []struct {
+		name              string
+		commenter         string
+		milestone         plugins.Milestone
+		expectedMilestone int
+	}{
+		{
+			name:              "Authorize a member of the second of two configured teams",
+			commenter:         "sig-lead",
+			milestone:         plugins.Milestone{MaintainersTeams: []string{"admins", "leads"}},
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Authorize a member of an additional team alongside the single team",
+			commenter:         "sig-lead",
+			milestone:         plugins.Milestone{MaintainersTeam: "admins", MaintainersTeams: []string{"leads"}},
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Authorize a member of the single team when additional teams are configured",
+			commenter:         "default-sig-lead",
+			milestone:         plugins.Milestone{MaintainersTeam: "admins", MaintainersTeams: []string{"leads"}},
+			expectedMilestone: 1,
+		},
+		{
+			name:      "Don't authorize a user who is in none of the configured teams",
+			commenter: "sig-follow",
+			milestone: plugins.Milestone{MaintainersTeams: []string{"admins", "leads"}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": tc.milestone}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+		})
+	}
+}
+
+// parentTeamsClient lists the leads team as a child of the admins team.
+type parentTeamsClient struct {
+	*fakegithub.FakeClient
+}
+
+func (c *parentTeamsClient) ListTeams(org string) ([]github.Team, error) {
+	return []github.Team{
+		{ID: 0, Slug: "admins", Name: "Admins"},
+		{ID: 42, Slug: "leads", Name: "Leads", Parent: &github.Team{ID: 0, Slug: "admins", Name: "Admins"}},
+	}, nil
+}
+
+func TestParentTeamMemberHint(t *testing.T) {
+	testcases := []struct {
+		name            string
+		commenter       string
+		maintainersTeam string
+		expectHint      bool
+	}{
+		{
+			name:            "Hint a member of the parent team",
+			commenter:       "default-sig-lead",
+			maintainersTeam: "leads",
+			expectHint:      true,
+		},
+		{
+			name:            "Hint a member of the parent team of a nested team path",
+			commenter:       "default-sig-lead",
+			maintainersTeam: "admins/leads",
+			expectHint:      true,
+		},
+		{
+			name:            "Don't hint an unrelated user",
+			commenter:       "sig-follow",
+			maintainersTeam: "leads",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &parentTeamsClient{FakeClient: fakegithub.NewFakeClient()}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: tc.maintainersTeam, MaintainersFriendlyName: "SIG Chairs/TLs"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 0 {
+				t.Errorf("Expected no milestone to be set, got %d.", fakeClient.Milestone)
+			}
+			if len(fakeClient.IssueComments[1]) != 1 {
+				t.Fatalf("Expected 1 comment, got %d.", len(fakeClient.IssueComments[1]))
+			}
+			comment := fakeClient.IssueComments[1][0].Body
+			if hinted := strings.Contains(comment, parentTeamMember); hinted != tc.expectHint {
+				t.Errorf("Expected the parent team hint: %t, got comment %q.", tc.expectHint, comment)
+			}
+		})
+	}
+}
+
+// emptyTeamsClient reports that the teams have no members.
+type emptyTeamsClient struct {
+	*fakegithub.FakeClient
+}
+
+func (c *emptyTeamsClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	return []github.TeamMember{}, nil
+}
+
+func (c *emptyTeamsClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	return false, nil
+}
+
+func TestEmptyMaintainersTeam(t *testing.T) {
+	fakeClient := &emptyTeamsClient{FakeClient: fakegithub.NewFakeClient()}
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/milestone v1.0",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+	if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		t.Fatalf("Unexpected error from handle: %v.", err)
+	}
+	if fakeClient.Milestone != 0 {
+		t.Errorf("Expected no milestone to be set, got %d.", fakeClient.Milestone)
+	}
+	if len(fakeClient.IssueComments[1]) != 1 {
+		t.Fatalf("Expected 1 comment, got %d.", len(fakeClient.IssueComments[1]))
+	}
+	if comment := fakeClient.IssueComments[1][0].Body; !strings.Contains(comment, noMaintainers) {
+		t.Errorf("Expected the comment to report the empty team, got %q.", comment)
+	}
+}
+
+// teamMembersClient lists the given members of the teams.
+type teamMembersClient struct {
+	*fakegithub.FakeClient
+	members map[string][]string
+}
+
+func (c *teamMembersClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	var members []github.TeamMember
+	for _, login := range c.members[teamSlug] {
+		members = append(members, github.TeamMember{Login: login})
+	}
+	return members, nil
+}
+
+func TestListMaintainers(t *testing.T) {
+	var large []string
+	for i := 0; i < 25; i++ {
+		large = append(large, fmt.Sprintf("lead-%02d", i))
+	}
+	testcases := []struct {
+		name            string
+		milestone       plugins.Milestone
+		expectedComment string
+	}{
+		{
+			name:            "List the members of a small team",
+			milestone:       plugins.Milestone{MaintainersTeam: "leads"},
+			expectedComment: fmt.Sprintf(maintainersAre, "`alice`, `bob`"),
+		},
+		{
+			name:            "List the members of several teams once",
+			milestone:       plugins.Milestone{MaintainersTeams: []string{"leads", "admins"}},
+			expectedComment: fmt.Sprintf(maintainersAre, "`alice`, `bob`, `carol`"),
+		},
+		{
+			name:            "Truncate the list of a large team to the default limit",
+			milestone:       plugins.Milestone{MaintainersTeam: "large"},
+			expectedComment: fmt.Sprintf(maintainersAre, fmt.Sprintf(moreMaintainers, "`"+strings.Join(large[:20], "`, `")+"`", 5, teamLinks("org", []string{"large"}))),
+		},
+		{
+			name:            "Truncate the list to the configured limit",
+			milestone:       plugins.Milestone{MaintainersTeam: "large", MaintainersListLimit: 2},
+			expectedComment: fmt.Sprintf(maintainersAre, fmt.Sprintf(moreMaintainers, "`lead-00`, `lead-01`", 23, teamLinks("org", []string{"large"}))),
+		},
+		{
+			name:            "Report an empty team",
+			milestone:       plugins.Milestone{MaintainersTeam: "empty"},
+			expectedComment: noMaintainers,
+		},
+		{
+			name:            "Report that all the org members are maintainers",
+			milestone:       plugins.Milestone{AllowOrgMembers: utilpointer.BoolPtr(true)},
+			expectedComment: fmt.Sprintf(orgMembersMaintainers, fmt.Sprintf(orgLink, "org", "org")),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &teamMembersClient{
+				FakeClient: fakegithub.NewFakeClient(),
+				members:    map[string][]string{"leads": {"bob", "alice"}, "admins": {"carol", "Alice"}, "large": large},
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone who",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "contributor"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": tc.milestone}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+// teamRolesClient lists the maintainers of the leads team only when asked for
+// the maintainers, and its regular members otherwise.
+type teamRolesClient struct {
+	*fakegithub.FakeClient
+}
+
+func (c *teamRolesClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	if role == github.RoleMaintainer {
+		return []github.TeamMember{{Login: "sig-lead"}}, nil
+	}
+	return []github.TeamMember{{Login: "sig-lead"}, {Login: "sig-member"}}, nil
+}
+
+// TeamBySlugHasMember can't tell the roles apart, as on GitHub.
+func (c *teamRolesClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	return memberLogin == "sig-lead" || memberLogin == "sig-member", nil
+}
+
+func TestRequireTeamMaintainerRole(t *testing.T) {
+	testcases := []struct {
+		name              string
+		login             string
+		requireRole       bool
+		expectedMilestone int
+	}{
+		{
+			name:              "A regular team member sets the milestone by default",
+			login:             "sig-member",
+			expectedMilestone: 1,
+		},
+		{
+			name:        "A regular team member is rejected if the maintainer role is required",
+			login:       "sig-member",
+			requireRole: true,
+		},
+		{
+			name:              "A team maintainer sets the milestone if the maintainer role is required",
+			login:             "sig-lead",
+			requireRole:       true,
+			expectedMilestone: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &teamRolesClient{FakeClient: fakegithub.NewFakeClient()}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.login},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", RequireTeamMaintainerRole: utilpointer.BoolPtr(tc.requireRole)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			rejected := len(fakeClient.IssueComments[1]) == 1 && strings.Contains(fakeClient.IssueComments[1][0].Body, "You must be a member of")
+			if rejected != (tc.expectedMilestone == 0) {
+				t.Errorf("Expected the user to be rejected: %t, got comments %v.", tc.expectedMilestone == 0, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestCustomUnauthorizedMessage(t *testing.T) {
+	fakeClient := fakegithub.NewFakeClient()
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/milestone v1.0",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-follow"},
+	}
+	unauthorized := "@{{.Login}}, see https://example.com/{{.Org}}/release-team to join {{index .Teams 0}}."
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", UnauthorizedMessage: unauthorized}}
+
+	if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		t.Fatalf("Unexpected error from handle: %v.", err)
+	}
+	if len(fakeClient.IssueComments[1]) != 1 {
+		t.Fatalf("Expected 1 comment, got %d.", len(fakeClient.IssueComments[1]))
+	}
+	expected := "@sig-follow, see https://example.com/org/release-team to join leads."
+	if comment := fakeClient.IssueComments[1][0].Body; !strings.Contains(comment, expected) {
+		t.Errorf("Expected the comment to contain %q, got %q.", expected, comment)
+	}
+}
+
+func TestEmptyFriendlyName(t *testing.T) {
+	testcases := []struct {
+		name         string
+		friendlyName string
+		expected     string
+	}{
+		{
+			name:     "Refer to the org administrators without a friendly name",
+			expected: "please contact your org administrators and",
+		},
+		{
+			name:         "Trim the configured friendly name",
+			friendlyName: " SIG Chairs/TLs ",
+			expected:     "please contact your SIG Chairs/TLs and",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-follow"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", MaintainersFriendlyName: tc.friendlyName}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if len(fakeClient.IssueComments[1]) != 1 {
+				t.Fatalf("Expected 1 comment, got %d.", len(fakeClient.IssueComments[1]))
+			}
+			if comment := fakeClient.IssueComments[1][0].Body; !strings.Contains(comment, tc.expected) {
+				t.Errorf("Expected the comment to contain %q, got %q.", tc.expected, comment)
+			}
+		})
+	}
+}
+
+func TestMaintainersTeamNotFound(t *testing.T) {
+	fakeClient := fakegithub.NewFakeClient()
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	commentID := 1
+	e := &github.GenericCommentEvent{
+		Action:    github.GenericCommentActionCreated,
+		Body:      "/milestone v1.0",
+		Number:    1,
+		CommentID: &commentID,
+		Repo:      github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:      github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "missing", UseReactions: utilpointer.BoolPtr(true)}}
+
+	if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		t.Fatalf("Unexpected error from handle: %v.", err)
+	}
+	if fakeClient.Milestone != 0 {
+		t.Errorf("Expected no milestone to be set, got %d.", fakeClient.Milestone)
+	}
+	if len(fakeClient.IssueComments[1]) != 1 {
+		t.Fatalf("Expected 1 comment, got %d.", len(fakeClient.IssueComments[1]))
+	}
+	if comment := fakeClient.IssueComments[1][0].Body; !strings.Contains(comment, "team `missing` does not exist in org `org`") {
+		t.Errorf("Expected the comment to name the missing team, got %q.", comment)
+	}
+}
+
+// forbiddenClient is forbidden to look up the members of the teams, as a
+// GitHub App without the members:read permission.
+type forbiddenClient struct {
+	*fakegithub.FakeClient
+}
+
+func (c *forbiddenClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	return false, github.NewForbidden()
+}
+
+func TestMissingMembersPermission(t *testing.T) {
+	fakeClient := &forbiddenClient{fakegithub.NewFakeClient()}
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/milestone v1.0",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+	if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		t.Fatalf("Unexpected error from handle: %v.", err)
+	}
+	if fakeClient.Milestone != 0 {
+		t.Errorf("Expected no milestone to be set, got %d.", fakeClient.Milestone)
+	}
+	if len(fakeClient.IssueComments[1]) != 1 {
+		t.Fatalf("Expected 1 comment, got %d.", len(fakeClient.IssueComments[1]))
+	}
+	if comment := fakeClient.IssueComments[1][0].Body; !strings.Contains(comment, "may lack the members:read permission") {
+		t.Errorf("Expected the comment to name the missing permission, got %q.", comment)
+	}
+}
+
+func TestEditedComments(t *testing.T) {
+	testcases := []struct {
+		name               string
+		body               string
+		handleEdited       bool
+		previousMilestone  github.Milestone
+		expectedMilestone  int
+		expectConfirmation bool
+	}{
+		{
+			name: "Ignore an edited comment by default",
+			body: "/milestone v1.0",
+		},
+		{
+			name:               "Set the milestone of a corrected command",
+			body:               "/milestone v1.0",
+			handleEdited:       true,
+			expectedMilestone:  1,
+			expectConfirmation: true,
+		},
+		{
+			name:              "Don't set the milestone again when it is already set",
+			body:              "/milestone v1.0",
+			handleEdited:      true,
+			previousMilestone: github.Milestone{Title: "v1.0", Number: 1},
+			expectedMilestone: 1,
+		},
+		{
+			name:               "Clear the milestone of a corrected command",
+			body:               "/milestone clear",
+			handleEdited:       true,
+			previousMilestone:  github.Milestone{Title: "v1.0", Number: 1},
+			expectConfirmation: true,
+		},
+		{
+			name:         "Don't clear the milestone again when it is already cleared",
+			body:         "/milestone clear",
+			handleEdited: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Issues[1] = &github.Issue{Number: 1, Milestone: tc.previousMilestone}
+			fakeClient.Milestone = tc.previousMilestone.Number
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionEdited,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", ConfirmMilestone: utilpointer.BoolPtr(true), HandleEditedComments: utilpointer.BoolPtr(tc.handleEdited)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			// only a command which sets or clears the milestone is confirmed
+			if confirmed := len(fakeClient.IssueComments[1]) > 0; confirmed != tc.expectConfirmation {
+				t.Errorf("Expected a confirmation: %t, got comments %v.", tc.expectConfirmation, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestTrackViaLabel(t *testing.T) {
+	testcases := []struct {
+		name            string
+		body            string
+		trackViaLabel   bool
+		existingLabels  []string
+		expectedAdded   []string
+		expectedRemoved []string
+	}{
+		{
+			name: "Don't track the milestone by default",
+			body: "/milestone v1.0",
+		},
+		{
+			name:          "Add the tracking label of the milestone",
+			body:          "/milestone v1.0",
+			trackViaLabel: true,
+			expectedAdded: []string{"org/repo#1:milestone-set/v1.0"},
+		},
+		{
+			name:            "Replace the stale tracking label",
+			body:            "/milestone v1.0",
+			trackViaLabel:   true,
+			existingLabels:  []string{"org/repo#1:milestone-set/v0.9", "org/repo#1:kind/bug"},
+			expectedAdded:   []string{"org/repo#1:milestone-set/v1.0"},
+			expectedRemoved: []string{"org/repo#1:milestone-set/v0.9"},
+		},
+		{
+			name:           "Keep the tracking label of the milestone",
+			body:           "/milestone v1.0",
+			trackViaLabel:  true,
+			existingLabels: []string{"org/repo#1:milestone-set/v1.0"},
+		},
+		{
+			name:            "Remove the tracking label when the milestone is cleared",
+			body:            "/milestone clear",
+			trackViaLabel:   true,
+			existingLabels:  []string{"org/repo#1:milestone-set/v0.9"},
+			expectedRemoved: []string{"org/repo#1:milestone-set/v0.9"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.IssueLabelsExisting = tc.existingLabels
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", TrackViaLabel: utilpointer.BoolPtr(tc.trackViaLabel)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if !reflect.DeepEqual(tc.expectedAdded, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, got %q.", tc.expectedAdded, fakeClient.IssueLabelsAdded)
+			}
+			if !reflect.DeepEqual(tc.expectedRemoved, fakeClient.IssueLabelsRemoved) {
+				t.Errorf("Expected labels %q to be removed, got %q.", tc.expectedRemoved, fakeClient.IssueLabelsRemoved)
+			}
+		})
+	}
+}
+
+func TestDefaultMaintainersTeam(t *testing.T) {
+	testcases := []struct {
+		name              string
+		commenter         string
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Authorize a member of the default team",
+			commenter:         "sig-lead",
+			expectedMilestone: 1,
+		},
+		{
+			name:            "Refer to the friendly name of the repo",
+			commenter:       "sig-follow",
+			expectedComment: "please contact your Release Team",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{
+				"":         {MaintainersTeam: "leads", MaintainersFriendlyName: "SIG Chairs/TLs"},
+				"org/repo": {MaintainersFriendlyName: "Release Team"},
+			}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestPathMaintainers(t *testing.T) {
+	testcases := []struct {
+		name              string
+		commenter         string
+		isPR              bool
+		changes           []github.PullRequestChange
+		expectedMilestone int
+	}{
+		{
+			name:              "Authorize the owner of a path changed by the PR",
+			commenter:         "sig-lead",
+			isPR:              true,
+			changes:           []github.PullRequestChange{{Filename: "README.md"}, {Filename: "staging/src/pkg/main.go"}},
+			expectedMilestone: 1,
+		},
+		{
+			name:      "Don't authorize the repo team for a path owned by another team",
+			commenter: "default-sig-lead",
+			isPR:      true,
+			changes:   []github.PullRequestChange{{Filename: "staging/src/pkg/main.go"}},
+		},
+		{
+			name:              "Fall back to the repo team when no path is owned by a team",
+			commenter:         "default-sig-lead",
+			isPR:              true,
+			changes:           []github.PullRequestChange{{Filename: "cmd/main.go"}},
+			expectedMilestone: 1,
+		},
+		{
+			name:      "Don't authorize the owner of a path for a PR not changing it",
+			commenter: "sig-lead",
+			isPR:      true,
+			changes:   []github.PullRequestChange{{Filename: "cmd/main.go"}},
+		},
+		{
+			name:              "Authorize the repo team for issues",
+			commenter:         "default-sig-lead",
+			expectedMilestone: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.PullRequestChanges = map[int][]github.PullRequestChange{1: tc.changes}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				IsPR:   tc.isPR,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{
+				"": {
+					MaintainersTeam: "admins",
+					PathMaintainers: []plugins.PathMaintainers{{Paths: []string{"staging/**/*"}, MaintainersTeams: []string{"leads"}}},
+				},
+			}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedMilestone == 0 && len(fakeClient.IssueComments[1]) != 1 {
+				t.Errorf("Expected the user to be told they're not authorized, got %v.", fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestMilestoneMaintainers(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		user              string
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "The owners of a milestone set it",
+			body:              "/milestone backlog",
+			user:              "default-sig-lead",
+			expectedMilestone: 2,
+		},
+		{
+			name:            "The owners of a milestone can't set another one",
+			body:            "/milestone v1.20",
+			user:            "default-sig-lead",
+			expectedComment: fmt.Sprintf(mustBeMilestoneOwner, teamLinks("org", []string{"leads"}), "v1.20", "org administrators"),
+		},
+		{
+			name:              "The milestone maintainers set the milestones owned by no team",
+			body:              "/milestone v1.20",
+			user:              "sig-lead",
+			expectedMilestone: 1,
+		},
+		{
+			name:            "The milestone maintainers can't set a milestone owned by a team",
+			body:            "/milestone backlog",
+			user:            "sig-lead",
+			expectedComment: fmt.Sprintf(mustBeMilestoneOwner, teamLinks("org", []string{"admins"}), "backlog", "org administrators"),
+		},
+		{
+			name:            "The owners of a milestone can't clear the milestone",
+			body:            "/milestone clear",
+			user:            "default-sig-lead",
+			expectedComment: fmt.Sprintf(mustBeAuthorizedToClear, teamLinks("org", []string{"leads"}), "org administrators"),
+		},
+		{
+			name:            "Users owning no milestone are rejected",
+			body:            "/milestone backlog",
+			user:            "sig-follow",
+			expectedComment: "You must be a member of",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 1, "backlog": 2}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.user},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {
+				MaintainersTeam:      "leads",
+				MilestoneMaintainers: []plugins.MilestoneMaintainers{{Milestone: "Backlog", MaintainersTeams: []string{"admins"}}},
+			}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestAllowedBots(t *testing.T) {
+	testcases := []struct {
+		name              string
+		commenter         string
+		expectedMilestone int
+	}{
+		{
+			name:              "Authorize an allowed bot",
+			commenter:         "release-bot",
+			expectedMilestone: 1,
+		},
+		{
+			name:      "Reject a bot which isn't allowed",
+			commenter: "other-bot",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads", AllowedBots: []string{"Release-Bot"}}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+		})
+	}
+}
+
+func TestResolvedMilestoneLogFields(t *testing.T) {
+	testcases := []struct {
+		name           string
+		body           string
+		expectedFields logrus.Fields
+	}{
+		{
+			name:           "existing milestone",
+			body:           "/milestone v2.0",
+			expectedFields: logrus.Fields{"proposedMilestone": "v2.0", "milestoneNumber": 2, "milestoneCount": 2},
+		},
+		{
+			name:           "unknown milestone",
+			body:           "/milestone v3.0",
+			expectedFields: logrus.Fields{"proposedMilestone": "v3.0", "milestoneNumber": 0, "milestoneCount": 2},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1, "v2.0": 2}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+			logger, hook := logrustest.NewNullLogger()
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logger.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			var entry *logrus.Entry
+			for _, logged := range hook.AllEntries() {
+				if logged.Message == "Looked up the proposed milestone." {
+					entry = logged
+				}
+			}
+			if entry == nil {
+				t.Fatalf("Expected the proposed milestone to be logged, got %v.", hook.AllEntries())
+			}
+			for field, expected := range tc.expectedFields {
+				if actual, ok := entry.Data[field]; !ok || actual != expected {
+					t.Errorf("Expected the field %s to be %v, got %v.", field, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestWarnOnClosedIssue(t *testing.T) {
+	testcases := []struct {
+		name         string
+		state        string
+		warn         bool
+		expectWarned bool
+	}{
+		{
+			name:         "Warn when setting the milestone of a closed issue",
+			state:        "closed",
+			warn:         true,
+			expectWarned: true,
+		},
+		{
+			name:  "Don't warn when setting the milestone of an open issue",
+			state: "open",
+			warn:  true,
+		},
+		{
+			name:  "Don't warn about closed issues by default",
+			state: "closed",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Issues = map[int]*github.Issue{1: {Number: 1, State: tc.state}}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads", WarnOnClosedIssue: utilpointer.BoolPtr(tc.warn)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 1 {
+				t.Errorf("Expected the milestone to be set regardless, got %d.", fakeClient.Milestone)
+			}
+			if !tc.expectWarned {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			expected := fmt.Sprintf(closedIssueWarning, "v1.0")
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, expected) {
+				t.Errorf("Expected a comment containing %q, got %v.", expected, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestQueryMilestone(t *testing.T) {
+	dueOn := time.Date(2022, time.August, 23, 7, 0, 0, 0, time.UTC)
+	testcases := []struct {
+		name            string
+		body            string
+		milestone       github.Milestone
+		expectedComment string
+	}{
+		{
+			name:            "Report the milestone and its due date",
+			body:            "/milestone?",
+			milestone:       github.Milestone{Title: "v1.0", Number: 1, DueOn: &dueOn},
+			expectedComment: "The milestone of this issue or PR is `v1.0`. It is due on 2022-08-23.",
+		},
+		{
+			name:            "Report a milestone without a due date",
+			body:            "/milestone status",
+			milestone:       github.Milestone{Title: "v1.0", Number: 1},
+			expectedComment: "The milestone of this issue or PR is `v1.0`.",
+		},
+		{
+			name:            "Report that there is no milestone",
+			body:            "/milestone?",
+			expectedComment: noMilestoneIsSet,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Issues[1] = &github.Issue{Number: 1, Milestone: tc.milestone}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				// anyone may query the milestone
+				User: github.User{Login: "sig-follow"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 0 {
+				t.Errorf("Expected the milestone to be unchanged, got %d.", fakeClient.Milestone)
+			}
+			if len(fakeClient.IssueComments[1]) != 1 {
+				t.Fatalf("Expected 1 comment, got %d.", len(fakeClient.IssueComments[1]))
+			}
+			if comment := fakeClient.IssueComments[1][0].Body; !strings.Contains(comment, tc.expectedComment) {
+				t.Errorf("Expected the comment to contain %q, got %q.", tc.expectedComment, comment)
+			}
+		})
+	}
+}
+
+func TestListMilestones(t *testing.T) {
+	testcases := []struct {
+		name            string
+		milestones      map[string]int
+		expectedComment string
+	}{
+		{
+			name:            "List the open milestones sorted",
+			milestones:      map[string]int{"v1.9": 1, "v1.10": 2, "v1.0": 3},
+			expectedComment: "Open milestones in this repository: [`v1.0`, `v1.10`, `v1.9`]",
+		},
+		{
+			name:            "Report that there are no open milestones",
+			expectedComment: noOpenMilestones,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = tc.milestones
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone list",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				// anyone may list the milestones
+				User: github.User{Login: "sig-follow"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 0 {
+				t.Errorf("Expected the milestone to be unchanged, got %d.", fakeClient.Milestone)
+			}
+			if len(fakeClient.IssueComments[1]) != 1 {
+				t.Fatalf("Expected 1 comment, got %d.", len(fakeClient.IssueComments[1]))
+			}
+			if comment := fakeClient.IssueComments[1][0].Body; !strings.Contains(comment, tc.expectedComment) {
+				t.Errorf("Expected the comment to contain %q, got %q.", tc.expectedComment, comment)
+			}
+		})
+	}
+}
+
+func TestAllowOrgMembers(t *testing.T) {
+	testcases := []struct {
+		name              string
+		commenter         string
+		expectedMilestone int
+		shouldComment     bool
+	}{
+		{
+			name:              "Authorize an org member",
+			commenter:         "org-member",
+			expectedMilestone: 1,
+		},
+		{
+			name:          "Reject a user who isn't an org member",
+			commenter:     "outsider",
+			shouldComment: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.OrgMembers["org"] = []string{"org-member"}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {AllowOrgMembers: utilpointer.BoolPtr(true)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			comments := len(fakeClient.IssueComments[1])
+			if tc.shouldComment && comments != 1 {
+				t.Errorf("1 comment should have been made, but %d comments were made.", comments)
+			} else if !tc.shouldComment && comments != 0 {
+				t.Errorf("No comment should have been made, but %d comments were made.", comments)
+			}
+		})
+	}
+}
+
+func TestClearTeam(t *testing.T) {
+	testcases := []struct {
+		name              string
+		commenter         string
+		milestone         plugins.Milestone
+		expectedMilestone int
+		shouldComment     bool
+	}{
+		{
+			name:              "Don't clear the milestone if the user can set but not clear it",
+			commenter:         "default-sig-lead",
+			milestone:         plugins.Milestone{MaintainersTeams: []string{"admins", "leads"}, ClearTeam: "leads"},
+			expectedMilestone: 1,
+			shouldComment:     true,
+		},
+		{
+			name:          "Clear the milestone if the user is in the clear team",
+			commenter:     "sig-lead",
+			milestone:     plugins.Milestone{MaintainersTeams: []string{"admins", "leads"}, ClearTeam: "leads"},
+			shouldComment: true,
+		},
+		{
+			name:          "Clear the milestone if the user is in the clear team configured by ID",
+			commenter:     "sig-lead",
+			milestone:     plugins.Milestone{MaintainersTeams: []string{"admins", "leads"}, ClearTeamID: 42},
+			shouldComment: true,
+		},
+		{
+			name:          "Fall back to the maintainers team when no clear team is configured",
+			commenter:     "default-sig-lead",
+			milestone:     plugins.Milestone{MaintainersTeams: []string{"admins", "leads"}},
+			shouldComment: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Milestone = 1
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone clear",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": tc.milestone}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			comments := len(fakeClient.IssueComments[1])
+			if tc.shouldComment && comments != 1 {
+				t.Errorf("1 comment should have been made, but %d comments were made.", comments)
+			} else if !tc.shouldComment && comments != 0 {
+				t.Errorf("No comment should have been made, but %d comments were made.", comments)
+			}
+		})
+	}
+}
+
+// rateLimitedTeamClient is rate limited looking up the members of a team.
+type rateLimitedTeamClient struct {
+	*fakegithub.FakeClient
+	team string
+}
+
+func (c *rateLimitedTeamClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	if teamSlug == c.team {
+		return false, github.RateLimitError{Wait: time.Hour}
+	}
+	return c.FakeClient.TeamBySlugHasMember(org, teamSlug, memberLogin)
+}
+
+func TestClearTeamErrors(t *testing.T) {
+	testcases := []struct {
+		name              string
+		clearTeam         string
+		rateLimitedTeam   string
+		expectedComment   string
+		expectRateLimited bool
+	}{
+		{
+			name:            "Report a clear team which doesn't exist",
+			clearTeam:       "missing",
+			expectedComment: fmt.Sprintf(teamNotFound, plugins.TeamNotFoundError{Org: "org", Team: "missing"}),
+		},
+		{
+			name:              "Ask to try again when rate limited looking up the clear team",
+			clearTeam:         "leads",
+			rateLimitedTeam:   "leads",
+			expectedComment:   rateLimited,
+			expectRateLimited: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &rateLimitedTeamClient{FakeClient: fakegithub.NewFakeClient(), team: tc.rateLimitedTeam}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Milestone = 1
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone clear",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "default-sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "admins", ClearTeam: tc.clearTeam}}
+
+			err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone)
+			if errors.Is(err, ErrRateLimited) != tc.expectRateLimited {
+				t.Errorf("Expected the error to be ErrRateLimited: %t, got: %v.", tc.expectRateLimited, err)
+			}
+			if !tc.expectRateLimited && err != nil {
+				t.Errorf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 1 {
+				t.Errorf("Expected the milestone not to be cleared, got %d.", fakeClient.Milestone)
+			}
+			comments := fakeClient.IssueComments[1]
+			if len(comments) != 1 || !strings.Contains(comments[0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, comments)
+			}
+		})
+	}
+}
+
+// settingClient counts the calls setting a milestone, fails the first
+// failures of them with err, or an injected failure if it is nil, and records
+// the milestone set on each issue if milestones isn't nil. If setting is set,
+// each call is signaled on it and waits until release is closed.
+type settingClient struct {
+	*fakegithub.FakeClient
+	lock       sync.Mutex
+	calls      int
+	failures   int
+	err        error
+	milestones map[int]int
+	setting    chan struct{}
+	release    chan struct{}
+}
+
+func (c *settingClient) SetMilestone(org, repo string, issueNum, milestoneNum int) error {
+	c.lock.Lock()
+	c.calls++
+	fail := c.failures > 0
+	if fail {
+		c.failures--
+	}
+	c.lock.Unlock()
+	if c.setting != nil {
+		c.setting <- struct{}{}
+		<-c.release
+	}
+	if fail {
+		if c.err != nil {
+			return c.err
+		}
+		return errors.New("injected failure")
+	}
+	if c.milestones != nil {
+		c.lock.Lock()
+		c.milestones[issueNum] = milestoneNum
+		c.lock.Unlock()
+	}
+	return c.FakeClient.SetMilestone(org, repo, issueNum, milestoneNum)
+}
+
+func TestPropagateToLinkedIssues(t *testing.T) {
+	testcases := []struct {
+		name               string
+		isPR               bool
+		propagate          bool
+		expectedMilestones map[int]int
+	}{
+		{
+			name:               "Set the milestone on the issues a PR closes",
+			isPR:               true,
+			propagate:          true,
+			expectedMilestones: map[int]int{1: 1, 2: 1, 3: 1},
+		},
+		{
+			name:               "Don't propagate the milestone when disabled",
+			isPR:               true,
+			expectedMilestones: map[int]int{1: 1},
+		},
+		{
+			name:               "Don't propagate the milestone from an issue",
+			propagate:          true,
+			expectedMilestones: map[int]int{1: 1},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &settingClient{FakeClient: fakegithub.NewFakeClient(), milestones: map[int]int{}}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.PullRequests[1] = &github.PullRequest{Number: 1, Body: "This PR does things.\n\nFixes #2\nCloses: #3\nfixes #2\nRelated to #4"}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				IsPR:   tc.isPR,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", PropagateToLinkedIssues: utilpointer.BoolPtr(tc.propagate)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if !reflect.DeepEqual(tc.expectedMilestones, fakeClient.milestones) {
+				t.Errorf("Expected milestones %v, got %v.", tc.expectedMilestones, fakeClient.milestones)
+			}
+		})
+	}
+}
+
+type failingIssueClient struct {
+	*settingClient
+	failing int
+}
+
+func (c *failingIssueClient) SetMilestone(org, repo string, issueNum, milestoneNum int) error {
+	if issueNum == c.failing {
+		return errors.New("injected SetMilestone error")
+	}
+	return c.settingClient.SetMilestone(org, repo, issueNum, milestoneNum)
+}
+
+func TestBatchMilestone(t *testing.T) {
+	testcases := []struct {
+		name               string
+		body               string
+		expectedMilestones map[int]int
+		expectedComment    string
+	}{
+		{
+			name:               "Set the milestone on all the referenced issues",
+			body:               "/milestone v1.0 #101 #102 #101",
+			expectedMilestones: map[int]int{101: 1, 102: 1},
+			expectedComment:    fmt.Sprintf(milestoneSetOnIssues, "v1.0", "- #101: set\n- #102: set"),
+		},
+		{
+			name:               "Report the referenced issues which don't exist",
+			body:               "/milestone v1.0 #101 #999 #102",
+			expectedMilestones: map[int]int{101: 1, 102: 1},
+			expectedComment:    fmt.Sprintf(milestoneSetOnIssues, "v1.0", "- #101: set\n"+fmt.Sprintf(issueNotFound, 999)+"\n- #102: set"),
+		},
+		{
+			name:               "Report the referenced issues on which setting the milestone failed",
+			body:               "/milestone v1.0 #101 #103",
+			expectedMilestones: map[int]int{101: 1},
+			expectedComment:    fmt.Sprintf(milestoneSetOnIssues, "v1.0", "- #101: set\n"+fmt.Sprintf(issueMilestoneFailed, 103)),
+		},
+		{
+			name:               "Set a milestone referred to by number on the referenced issues",
+			body:               "/milestone #1 #101",
+			expectedMilestones: map[int]int{101: 1},
+			expectedComment:    fmt.Sprintf(milestoneSetOnIssues, "v1.0", "- #101: set"),
+		},
+		{
+			name:               "Set a milestone referred to by number without referenced issues",
+			body:               "/milestone #1",
+			expectedMilestones: map[int]int{1: 1},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &failingIssueClient{
+				settingClient: &settingClient{FakeClient: fakegithub.NewFakeClient(), milestones: map[int]int{}},
+				failing:       103,
+			}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			for _, number := range []int{1, 101, 102, 103} {
+				fakeClient.Issues[number] = &github.Issue{Number: number}
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if !reflect.DeepEqual(tc.expectedMilestones, fakeClient.milestones) {
+				t.Errorf("Expected milestones %v, got %v.", tc.expectedMilestones, fakeClient.milestones)
+			}
+			var expected []string
+			if tc.expectedComment != "" {
+				expected = []string{plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, tc.expectedComment)}
+			}
+			var actual []string
+			for _, c := range fakeClient.IssueComments[1] {
+				actual = append(actual, c.Body)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Expected comments %q, got %q.", expected, actual)
+			}
+		})
+	}
+}
+
+func TestBulkMilestone(t *testing.T) {
+	testcases := []struct {
+		name               string
+		body               string
+		bulkTeam           string
+		expectedMilestones map[int]int
+		expectedComment    string
+	}{
+		{
+			name:               "Set the milestone on all the open PRs against the branch",
+			body:               "/milestone v1.20 --all-open-prs base:release-1.20",
+			bulkTeam:           "leads",
+			expectedMilestones: map[int]int{101: 1, 102: 1},
+			expectedComment:    fmt.Sprintf(milestoneSetOnPRs, "v1.20", "release-1.20", "- #101: set\n- #102: set"),
+		},
+		{
+			name:               "Report a branch without open PRs",
+			body:               "/milestone v1.20 --all-open-prs base:release-1.19",
+			bulkTeam:           "leads",
+			expectedMilestones: map[int]int{},
+			expectedComment:    fmt.Sprintf(noOpenPRs, "release-1.19"),
+		},
+		{
+			name:               "Reject the bulk command from outside the bulk team",
+			body:               "/milestone v1.20 --all-open-prs base:release-1.20",
+			bulkTeam:           "admins",
+			expectedMilestones: map[int]int{},
+			expectedComment:    fmt.Sprintf(mustBeAuthorizedForBulk, teamLinks("org", []string{"admins"})),
+		},
+		{
+			name:               "Reject the bulk command if no bulk team is configured",
+			body:               "/milestone v1.20 --all-open-prs base:release-1.20",
+			expectedMilestones: map[int]int{},
+			expectedComment:    bulkNotAllowed,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &settingClient{FakeClient: fakegithub.NewFakeClient(), milestones: map[int]int{}}
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 1}
+			prs := []github.PullRequest{
+				{Number: 101, State: github.PullRequestStateOpen, Base: github.PullRequestBranch{Ref: "release-1.20"}},
+				{Number: 102, State: github.PullRequestStateOpen, Base: github.PullRequestBranch{Ref: "release-1.20"}},
+				{Number: 103, State: github.PullRequestStateOpen, Base: github.PullRequestBranch{Ref: "master"}},
+				{Number: 104, State: github.PullRequestStateClosed, Base: github.PullRequestBranch{Ref: "release-1.20"}},
+			}
+			for i := range prs {
+				fakeClient.PullRequests[prs[i].Number] = &prs[i]
+				fakeClient.Issues[prs[i].Number] = &github.Issue{Number: prs[i].Number}
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", BulkTeam: tc.bulkTeam}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if !reflect.DeepEqual(tc.expectedMilestones, fakeClient.milestones) {
+				t.Errorf("Expected milestones %v, got %v.", tc.expectedMilestones, fakeClient.milestones)
+			}
+			expected := []string{plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, tc.expectedComment)}
+			var actual []string
+			for _, c := range fakeClient.IssueComments[1] {
+				actual = append(actual, c.Body)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Expected comments %q, got %q.", expected, actual)
+			}
+		})
+	}
+}
+
+// searchingClient finds the issues whose milestone is the one searched for and
+// records the queries. Searching without an org fails, as it does for GitHub
+// apps.
+type searchingClient struct {
+	*failingIssueClient
+	queries []string
+}
+
+func (c *searchingClient) FindIssuesWithOrg(org, query, order string, asc bool) ([]github.Issue, error) {
+	if org == "" {
+		return nil, errors.New("searching requires an org for GitHub apps")
+	}
+	c.queries = append(c.queries, query)
+	var issues []github.Issue
+	for _, issue := range c.Issues {
+		if strings.Contains(query, fmt.Sprintf("milestone:%q", issue.Milestone.Title)) {
+			issues = append(issues, *issue)
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Number < issues[j].Number })
+	return issues, nil
+}
+
+func TestMoveMilestone(t *testing.T) {
+	testcases := []struct {
+		name               string
+		body               string
+		bulkTeam           string
+		expectedMilestones map[int]int
+		expectedQueries    []string
+		expectedComment    string
+	}{
+		{
+			name:               "Move the issues of a milestone to another",
+			body:               "/milestone move from:v1.20 to:v1.21",
+			bulkTeam:           "leads",
+			expectedMilestones: map[int]int{101: 2, 102: 2},
+			expectedQueries:    []string{`repo:org/repo is:open milestone:"v1.20"`},
+			expectedComment:    fmt.Sprintf(milestonesMoved, 2, "v1.20", "v1.21") + fmt.Sprintf(milestonesNotMoved, 1, "#103"),
+		},
+		{
+			name:               "Report a milestone without issues",
+			body:               "/milestone move from:v1.21 to:v1.20",
+			bulkTeam:           "leads",
+			expectedMilestones: map[int]int{},
+			expectedQueries:    []string{`repo:org/repo is:open milestone:"v1.21"`},
+			expectedComment:    fmt.Sprintf(nothingToMove, "v1.21"),
+		},
+		{
+			name:               "Report an unknown milestone",
+			body:               "/milestone move from:v1.20 to:v2.0",
+			bulkTeam:           "leads",
+			expectedMilestones: map[int]int{},
+			expectedComment:    fmt.Sprintf(unknownMoveMilestone, "v2.0", "`v1.20`, `v1.21`"),
+		},
+		{
+			name:               "Reject the move from outside the bulk team",
+			body:               "/milestone move from:v1.20 to:v1.21",
+			bulkTeam:           "admins",
+			expectedMilestones: map[int]int{},
+			expectedComment:    fmt.Sprintf(mustBeAuthorizedForBulk, teamLinks("org", []string{"admins"})),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &searchingClient{failingIssueClient: &failingIssueClient{
+				settingClient: &settingClient{FakeClient: fakegithub.NewFakeClient(), milestones: map[int]int{}},
+				failing:       103,
+			}}
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 1, "v1.21": 2}
+			for _, number := range []int{101, 102, 103} {
+				fakeClient.Issues[number] = &github.Issue{Number: number, Milestone: github.Milestone{Title: "v1.20", Number: 1}}
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", BulkTeam: tc.bulkTeam}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if !reflect.DeepEqual(tc.expectedMilestones, fakeClient.milestones) {
+				t.Errorf("Expected milestones %v, got %v.", tc.expectedMilestones, fakeClient.milestones)
+			}
+			if !reflect.DeepEqual(tc.expectedQueries, fakeClient.queries) {
+				t.Errorf("Expected the queries %q, got %q.", tc.expectedQueries, fakeClient.queries)
+			}
+			expected := []string{plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, tc.expectedComment)}
+			var actual []string
+			for _, c := range fakeClient.IssueComments[1] {
+				actual = append(actual, c.Body)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Expected comments %q, got %q.", expected, actual)
+			}
+		})
+	}
+}
+
+func TestLinkedIssues(t *testing.T) {
+	testcases := []struct {
+		body     string
+		expected []int
+	}{
+		{body: "Fixes #1", expected: []int{1}},
+		{body: "fixed #1, closes #2 and resolves #3", expected: []int{1, 2, 3}},
+		{body: "Close: #1\nResolved #2", expected: []int{1, 2}},
+		{body: "Fixes #1\nFixes #1", expected: []int{1}},
+		{body: "Fixes org/other#1 and prefixes #2"},
+		{body: "Related to #1"},
+	}
+	for _, tc := range testcases {
+		if actual := linkedIssues(tc.body); !reflect.DeepEqual(tc.expected, actual) {
+			t.Errorf("linkedIssues(%q): expected %v, got %v.", tc.body, tc.expected, actual)
+		}
+	}
+}
+
+func TestBranchMilestones(t *testing.T) {
+	branchMilestones := []plugins.BranchMilestone{
+		{BranchRegexp: `^release-1\.20$`, BranchRe: regexp.MustCompile(`^release-1\.20$`), Milestone: "v1.20"},
+		{BranchRegexp: `^release-1\.21$`, BranchRe: regexp.MustCompile(`^release-1\.21$`), Milestone: "V1.21"},
+		{BranchRegexp: `^main$`, BranchRe: regexp.MustCompile(`^main$`), Milestone: "v1.22"},
+	}
+	testcases := []struct {
+		name              string
+		body              string
+		isPR              bool
+		baseRef           string
+		branchMilestones  []plugins.BranchMilestone
+		expectedMilestone int
+		shouldComment     bool
+	}{
+		{
+			name:              "Resolve the milestone from the base branch without an argument",
+			body:              "/milestone",
+			isPR:              true,
+			baseRef:           "release-1.20",
+			branchMilestones:  branchMilestones,
+			expectedMilestone: 20,
+		},
+		{
+			name:              "Resolve the milestone from the base branch with the auto keyword",
+			body:              "/milestone auto",
+			isPR:              true,
+			baseRef:           "release-1.21",
+			branchMilestones:  branchMilestones,
+			expectedMilestone: 21,
+		},
+		{
+			name:              "Fall through to the default branch mapping",
+			body:              "/milestone auto",
+			isPR:              true,
+			baseRef:           "main",
+			branchMilestones:  branchMilestones,
+			expectedMilestone: 22,
+		},
+		{
+			name:             "Comment when no milestone is configured for the base branch",
+			body:             "/milestone auto",
+			isPR:             true,
+			baseRef:          "release-1.19",
+			branchMilestones: branchMilestones,
+			shouldComment:    true,
+		},
+		{
+			name:             "Comment when the milestone is determined automatically for an issue",
+			body:             "/milestone",
+			branchMilestones: branchMilestones,
+			shouldComment:    true,
+		},
+		{
+			name:    "Ignore the command without an argument when no branch milestones are configured",
+			body:    "/milestone",
+			isPR:    true,
+			baseRef: "release-1.20",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 20, "v1.21": 21, "v1.22": 22}
+			fakeClient.PullRequests[1] = &github.PullRequest{Number: 1, Base: github.PullRequestBranch{Ref: tc.baseRef}}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				IsPR:   tc.isPR,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", BranchMilestones: tc.branchMilestones}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			comments := len(fakeClient.IssueComments[1])
+			if tc.shouldComment && comments != 1 {
+				t.Errorf("1 comment should have been made, but %d comments were made.", comments)
+			} else if !tc.shouldComment && comments != 0 {
+				t.Errorf("No comment should have been made, but %d comments were made.", comments)
+			}
+		})
+	}
+}
+
+func TestLabelMilestones(t *testing.T) {
+	labelMilestones := []plugins.LabelMilestone{
+		{LabelRegexp: `^area/release-1\.20$`, LabelRe: regexp.MustCompile(`^area/release-1\.20$`), Milestone: "v1.20"},
+		{LabelRegexp: `^area/release-1\.21$`, LabelRe: regexp.MustCompile(`^area/release-1\.21$`), Milestone: "v1.21"},
+		{LabelRegexp: `^priority/release-1\.21$`, LabelRe: regexp.MustCompile(`^priority/release-1\.21$`), Milestone: "v1.21"},
+	}
+	testcases := []struct {
+		name              string
+		labels            []string
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Set the milestone of the single matching label",
+			labels:            []string{"kind/bug", "area/release-1.20"},
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Set the milestone of several labels mapping to it",
+			labels:            []string{"area/release-1.21", "priority/release-1.21"},
+			expectedMilestone: 2,
+		},
+		{
+			name:            "Report that no label maps to a milestone",
+			labels:          []string{"kind/bug"},
+			expectedComment: noLabelMilestone,
+		},
+		{
+			name:            "Report labels mapping to different milestones",
+			labels:          []string{"area/release-1.21", "area/release-1.20"},
+			expectedComment: fmt.Sprintf(conflictingLabels, "`area/release-1.20` → `v1.20`, `area/release-1.21` → `v1.21`"),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 1, "v1.21": 2}
+			for _, label := range tc.labels {
+				fakeClient.IssueLabelsExisting = append(fakeClient.IssueLabelsExisting, "org/repo#1:"+label)
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone from-labels",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", LabelMilestones: labelMilestones}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestMilestoneFreezes(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	testcases := []struct {
+		name              string
+		commenter         string
+		freeze            plugins.MilestoneFreeze
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Set a milestone before its freeze",
+			commenter:         "sig-lead",
+			freeze:            plugins.MilestoneFreeze{Milestone: "v1.0", FreezeTime: future},
+			expectedMilestone: 1,
+		},
+		{
+			name:            "Reject a frozen milestone",
+			commenter:       "sig-lead",
+			freeze:          plugins.MilestoneFreeze{Milestone: "v1.0", FreezeTime: past},
+			expectedComment: fmt.Sprintf(milestoneFrozen, "v1.0", past.Format(time.RFC3339)),
+		},
+		{
+			name:              "Set a frozen milestone as a member of the freeze team",
+			commenter:         "default-sig-lead",
+			freeze:            plugins.MilestoneFreeze{Milestone: "v1.0", FreezeTime: past, FreezeTeam: "admins"},
+			expectedMilestone: 1,
+		},
+		{
+			name:            "Reject a frozen milestone for the other milestone maintainers",
+			commenter:       "sig-lead",
+			freeze:          plugins.MilestoneFreeze{Milestone: "v1.0", FreezeTime: past, FreezeTeam: "admins"},
+			expectedComment: "Only the members of the [org/admins]",
+		},
+		{
+			name:              "Set a milestone which isn't frozen",
+			commenter:         "sig-lead",
+			freeze:            plugins.MilestoneFreeze{Milestone: "v2.0", FreezeTime: past},
+			expectedMilestone: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1, "v2.0": 2}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeams: []string{"admins", "leads"}, MilestoneFreezes: []plugins.MilestoneFreeze{tc.freeze}}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestCurrentMilestone(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		currentMilestone  string
+		branchMilestones  []plugins.BranchMilestone
+		expectedMilestone int
+		shouldComment     bool
+	}{
+		{
+			name:              "Set the current milestone without an argument",
+			body:              "/milestone",
+			currentMilestone:  "v1.21",
+			expectedMilestone: 21,
+		},
+		{
+			name:              "Prefer the current milestone over the branch milestones",
+			body:              "/milestone",
+			currentMilestone:  "v1.21",
+			branchMilestones:  []plugins.BranchMilestone{{BranchRegexp: `^release-1\.20$`, BranchRe: regexp.MustCompile(`^release-1\.20$`), Milestone: "v1.20"}},
+			expectedMilestone: 21,
+		},
+		{
+			name:              "Set the given milestone rather than the current one",
+			body:              "/milestone v1.22",
+			currentMilestone:  "v1.21",
+			expectedMilestone: 22,
+		},
+		{
+			name:             "Comment when the current milestone doesn't exist",
+			body:             "/milestone",
+			currentMilestone: "v2.0",
+			shouldComment:    true,
+		},
+		{
+			name: "Ignore the command without an argument when no current milestone is configured",
+			body: "/milestone",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 20, "v1.21": 21, "v1.22": 22}
+			fakeClient.PullRequests[1] = &github.PullRequest{Number: 1, Base: github.PullRequestBranch{Ref: "release-1.20"}}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				IsPR:   true,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", CurrentMilestone: tc.currentMilestone, BranchMilestones: tc.branchMilestones}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			comments := len(fakeClient.IssueComments[1])
+			if tc.shouldComment && comments != 1 {
+				t.Errorf("1 comment should have been made, but %d comments were made.", comments)
+			} else if !tc.shouldComment && comments != 0 {
+				t.Errorf("No comment should have been made, but %d comments were made.", comments)
+			}
+		})
+	}
+}
+
+func TestNextMilestone(t *testing.T) {
+	due := func(d time.Duration) *time.Time {
+		at := time.Now().Add(d)
+		return &at
+	}
+	testcases := []struct {
+		name       string
+		milestones []github.Milestone
+		expected   int
+		expectedOk bool
+	}{
+		{
+			name: "Pick the open milestone due soonest",
+			milestones: []github.Milestone{
+				{Title: "v1.22", Number: 22, State: github.MilestoneStateOpen, DueOn: due(60 * 24 * time.Hour)},
+				{Title: "v1.21", Number: 21, State: github.MilestoneStateOpen, DueOn: due(30 * 24 * time.Hour)},
+				{Title: "v1.23", Number: 23, State: github.MilestoneStateOpen, DueOn: due(90 * 24 * time.Hour)},
+			},
+			expected:   21,
+			expectedOk: true,
+		},
+		{
+			name: "Exclude milestones without a due date",
+			milestones: []github.Milestone{
+				{Title: "backlog", Number: 1, State: github.MilestoneStateOpen},
+				{Title: "v1.22", Number: 22, State: github.MilestoneStateOpen, DueOn: due(60 * 24 * time.Hour)},
+			},
+			expected:   22,
+			expectedOk: true,
+		},
+		{
+			name: "Exclude milestones which were due in the past",
+			milestones: []github.Milestone{
+				{Title: "v1.20", Number: 20, State: github.MilestoneStateOpen, DueOn: due(-30 * 24 * time.Hour)},
+				{Title: "v1.22", Number: 22, State: github.MilestoneStateOpen, DueOn: due(60 * 24 * time.Hour)},
+			},
+			expected:   22,
+			expectedOk: true,
+		},
+		{
+			name: "Exclude closed milestones",
+			milestones: []github.Milestone{
+				{Title: "v1.21", Number: 21, State: github.MilestoneStateClosed, DueOn: due(30 * 24 * time.Hour)},
+				{Title: "v1.22", Number: 22, State: github.MilestoneStateOpen, DueOn: due(60 * 24 * time.Hour)},
+			},
+			expected:   22,
+			expectedOk: true,
+		},
+		{
+			name: "No next milestone without due dates",
+			milestones: []github.Milestone{
+				{Title: "backlog", Number: 1, State: github.MilestoneStateOpen},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			next, ok := nextMilestone(tc.milestones)
+			if ok != tc.expectedOk {
+				t.Fatalf("Expected ok to be %t, got %t.", tc.expectedOk, ok)
+			}
+			if next.Number != tc.expected {
+				t.Errorf("Expected milestone %d, got %d.", tc.expected, next.Number)
+			}
+		})
+	}
+}
+
+func TestSetNextMilestone(t *testing.T) {
+	due := time.Now().Add(30 * 24 * time.Hour)
+	testcases := []struct {
+		name              string
+		body              string
+		milestones        []github.Milestone
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name: "Set the milestone due next",
+			body: "/milestone next",
+			milestones: []github.Milestone{
+				{Title: "backlog", Number: 1, State: github.MilestoneStateOpen},
+				{Title: "v1.21", Number: 21, State: github.MilestoneStateOpen, DueOn: &due},
+			},
+			expectedMilestone: 21,
+		},
+		{
+			name: "Comment when no milestone is due next",
+			body: "/milestone next",
+			milestones: []github.Milestone{
+				{Title: "backlog", Number: 1, State: github.MilestoneStateOpen},
+			},
+			expectedComment: noNextMilestone,
+		},
+		{
+			name: "Set a milestone titled next when quoted",
+			body: `/milestone "next"`,
+			milestones: []github.Milestone{
+				{Title: "next", Number: 2, State: github.MilestoneStateOpen},
+				{Title: "v1.21", Number: 21, State: github.MilestoneStateOpen, DueOn: &due},
+			},
+			expectedMilestone: 2,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &listMilestonesClient{FakeClient: fakegithub.NewFakeClient(), milestones: tc.milestones}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			var actual string
+			if len(fakeClient.IssueComments[1]) > 0 {
+				actual = fakeClient.IssueComments[1][0].Body
+			}
+			if tc.expectedComment == "" && actual != "" {
+				t.Errorf("Expected no comment, got %q.", actual)
+			}
+			if !strings.Contains(actual, tc.expectedComment) {
+				t.Errorf("Expected comment to contain %q, got %q.", tc.expectedComment, actual)
+			}
+		})
+	}
+}
+
+func TestCreateMissingMilestone(t *testing.T) {
+	testcases := []struct {
+		name               string
+		body               string
+		allowCreate        bool
+		expectedMilestone  int
+		expectedMilestones map[string]int
+		expectedComment    string
+	}{
+		{
+			name:               "Create and set a missing milestone",
+			body:               "/milestone v1.21 --create",
+			allowCreate:        true,
+			expectedMilestone:  21,
+			expectedMilestones: map[string]int{"v1.20": 20, "v1.21": 21},
+		},
+		{
+			name:               "Set an existing milestone without creating it",
+			body:               "/milestone v1.20 --create",
+			allowCreate:        true,
+			expectedMilestone:  20,
+			expectedMilestones: map[string]int{"v1.20": 20},
+		},
+		{
+			name:               "Don't create a milestone when creation is disabled",
+			body:               "/milestone v1.21 --create",
+			expectedMilestones: map[string]int{"v1.20": 20},
+			expectedComment:    createNotAllowed,
+		},
+		{
+			name:               "Don't create a milestone without the flag",
+			body:               "/milestone v1.21",
+			allowCreate:        true,
+			expectedMilestones: map[string]int{"v1.20": 20},
+			expectedComment:    "The provided milestone is not valid for this repository.",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 20}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", AllowCreate: utilpointer.BoolPtr(tc.allowCreate)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if !reflect.DeepEqual(tc.expectedMilestones, fakeClient.MilestoneMap) {
+				t.Errorf("Expected the milestones %v, got %v.", tc.expectedMilestones, fakeClient.MilestoneMap)
+			}
+			var actual string
+			if len(fakeClient.IssueComments[1]) > 0 {
+				actual = fakeClient.IssueComments[1][0].Body
+			}
+			if tc.expectedComment == "" && actual != "" {
+				t.Errorf("Expected no comment, got %q.", actual)
+			}
+			if !strings.Contains(actual, tc.expectedComment) {
+				t.Errorf("Expected comment to contain %q, got %q.", tc.expectedComment, actual)
+			}
+		})
+	}
+}
+
+func TestBuildMilestoneMap(t *testing.T) {
+	milestones := []github.Milestone{
+		{Title: "v1.0", Number: 1},
+		{Title: "V2.0-Beta", Number: 2},
+	}
+	expected := map[string]int{"v1.0": 1, "v2.0-beta": 2}
+	if actual := BuildMilestoneMap(milestones); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected milestone map %v, got %v.", expected, actual)
+	}
+	for _, title := range []string{"V1.0", "v1.0", " v1.0 ", "\tv1.0"} {
+		if number := BuildMilestoneMap(milestones)[NormalizeTitle(title)]; number != 1 {
+			t.Errorf("Expected %q to resolve to milestone 1, got %d.", title, number)
+		}
+	}
+}
+
+func TestBuildMilestoneMapCollisions(t *testing.T) {
+	milestones := []github.Milestone{
+		{Title: "v1.0", Number: 1},
+		{Title: "V1.0", Number: 2},
+		{Title: "v2.0", Number: 3},
+	}
+	expected := map[string]int{"v2.0": 3}
+	if actual := BuildMilestoneMap(milestones); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected milestone map %v, got %v.", expected, actual)
+	}
+}
+
+func TestResolveMilestone(t *testing.T) {
+	milestones := []github.Milestone{
+		{Title: "v1.0", Number: 1},
+		{Title: "Q3 Planning", Number: 2},
+		{Title: "42", Number: 3},
+	}
+	testcases := []struct {
+		name       string
+		title      string
+		extra      []github.Milestone
+		expected   int
+		expectedOk bool
+	}{
+		{
+			name:       "Resolve an exact title",
+			title:      "v1.0",
+			expected:   1,
+			expectedOk: true,
+		},
+		{
+			name:       "Resolve a title regardless of case",
+			title:      "V1.0",
+			expected:   1,
+			expectedOk: true,
+		},
+		{
+			name:       "Resolve a title regardless of whitespace",
+			title:      " q3   planning\t",
+			expected:   2,
+			expectedOk: true,
+		},
+		{
+			name:       "Resolve a number",
+			title:      "#2",
+			expected:   2,
+			expectedOk: true,
+		},
+		{
+			name:       "Resolve a numeric title",
+			title:      "42",
+			expected:   3,
+			expectedOk: true,
+		},
+		{
+			name:  "Don't resolve an unknown title",
+			title: "v2.0",
+		},
+		{
+			name:  "Don't resolve an unknown number",
+			title: "number:42",
+		},
+		{
+			name:       "Resolve the exact title among colliding titles",
+			title:      "V1.0",
+			extra:      []github.Milestone{{Title: "V1.0", Number: 4}},
+			expected:   4,
+			expectedOk: true,
+		},
+		{
+			name:  "Don't resolve a title matching colliding titles",
+			title: "v1.0 ",
+			extra: []github.Milestone{{Title: "V1.0", Number: 4}},
+		},
+		{
+			name:       "Resolve a title with parentheses and a plus sign",
+			title:      "v1.20 (RC)+build",
+			extra:      []github.Milestone{{Title: "v1.20 (rc)+build", Number: 4}},
+			expected:   4,
+			expectedOk: true,
+		},
+		{
+			name:  "Don't treat the dots of a title as wildcards",
+			title: "v1x0",
+		},
+		{
+			name:  "Don't treat a plus sign of a title as a repetition",
+			title: "v1.200",
+			extra: []github.Milestone{{Title: "v1.20+", Number: 4}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			number, ok := ResolveMilestone(append(milestones, tc.extra...), tc.title)
+			if ok != tc.expectedOk {
+				t.Fatalf("Expected ok to be %t, got %t.", tc.expectedOk, ok)
+			}
+			if number != tc.expected {
+				t.Errorf("Expected milestone %d, got %d.", tc.expected, number)
+			}
+		})
+	}
+}
+
+func TestMatchVersionPrefix(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		milestones        map[string]int
+		matchPrefix       bool
+		expectedMilestone int
+	}{
+		{
+			name:              "Add the missing prefix",
+			body:              "/milestone 1.20",
+			milestones:        map[string]int{"v1.20": 1},
+			matchPrefix:       true,
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Remove the extra prefix",
+			body:              "/milestone v1.20",
+			milestones:        map[string]int{"1.20": 1},
+			matchPrefix:       true,
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Prefer the exact title",
+			body:              "/milestone 1.20",
+			milestones:        map[string]int{"v1.20": 1, "1.20": 2},
+			matchPrefix:       true,
+			expectedMilestone: 2,
+		},
+		{
+			name:       "Don't match the prefix by default",
+			body:       "/milestone 1.20",
+			milestones: map[string]int{"v1.20": 1},
+		},
+		{
+			name:        "Don't match a quoted title",
+			body:        `/milestone "1.20"`,
+			milestones:  map[string]int{"v1.20": 1},
+			matchPrefix: true,
+		},
+		{
+			name:        "Don't match titles which aren't versions",
+			body:        "/milestone vNext",
+			milestones:  map[string]int{"Next": 1},
+			matchPrefix: true,
+		},
+		{
+			name:              "Add the missing prefix to a title with parentheses",
+			body:              "/milestone 1.20 (rc)",
+			milestones:        map[string]int{"v1.20 (rc)": 1},
+			matchPrefix:       true,
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Remove the extra prefix from a title with a plus sign",
+			body:              "/milestone v1.20+k8s",
+			milestones:        map[string]int{"1.20+k8s": 1},
+			matchPrefix:       true,
+			expectedMilestone: 1,
+		},
+		{
+			name:        "Don't treat the dots of a title as wildcards",
+			body:        "/milestone 1x20",
+			milestones:  map[string]int{"v1.20": 1},
+			matchPrefix: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = tc.milestones
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads", MatchVersionPrefix: utilpointer.BoolPtr(tc.matchPrefix)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+		})
+	}
+}
+
+func TestMilestoneAliases(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Set the milestone an alias refers to",
+			body:              "/milestone lts",
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Match aliases regardless of case",
+			body:              "/milestone Current",
+			expectedMilestone: 2,
+		},
+		{
+			name:            "Report an alias referring to a nonexistent milestone",
+			body:            "/milestone future",
+			expectedComment: fmt.Sprintf(aliasNotFound, "future", "v2.0"),
+		},
+		{
+			name:            "Don't resolve a quoted alias",
+			body:            `/milestone "lts"`,
+			expectedComment: "The provided milestone is not valid for this repository.",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.18": 1, "v1.20": 2}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			aliases := map[string]string{"lts": "v1.18", "CURRENT": "v1.20", "future": "v2.0"}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", MilestoneAliases: aliases}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestAmbiguousMilestone(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Set the milestone with the exact title",
+			body:              "/milestone release a",
+			expectedMilestone: 2,
+		},
+		{
+			name:            "Report titles colliding under normalization",
+			body:            "/milestone RELEASE A",
+			expectedComment: "The milestone `RELEASE A` is ambiguous, as it matches the milestones `Release A`, `release a`.",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"Release A": 1, "release a": 2}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestInvalidMilestoneSuggestion(t *testing.T) {
+	testcases := []struct {
+		name               string
+		body               string
+		expectedSuggestion string
+	}{
+		{
+			name:               "Suggest the closest milestone for a typo",
+			body:               "/milestone v1.2O",
+			expectedSuggestion: "v1.20",
+		},
+		{
+			name:               "Suggest the closest milestone for a missing character",
+			body:               "/milestone v1.1",
+			expectedSuggestion: "v1.10",
+		},
+		{
+			name: "Don't suggest anything when no milestone is close",
+			body: "/milestone something-else",
+		},
+	}
+
+	milestones := []github.Milestone{{Title: "v1.10", Number: 1}, {Title: "v1.20", Number: 2}, {Title: "v1.30", Number: 3}}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			for _, ms := range milestones {
+				fakeClient.MilestoneMap[ms.Title] = ms.Number
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 0 {
+				t.Errorf("Expected the milestone not to be set, got %d.", fakeClient.Milestone)
+			}
+			if len(fakeClient.IssueComments[1]) != 1 {
+				t.Fatalf("Expected one comment, got %d.", len(fakeClient.IssueComments[1]))
+			}
+			comment := fakeClient.IssueComments[1][0].Body
+			if tc.expectedSuggestion == "" {
+				if strings.Contains(comment, "Did you mean") {
+					t.Errorf("Expected no suggestion, got comment %q.", comment)
+				}
+				return
+			}
+			if !strings.Contains(comment, fmt.Sprintf(didYouMean, "`"+tc.expectedSuggestion+"`")) {
+				t.Errorf("Expected a suggestion of %q, got comment %q.", tc.expectedSuggestion, comment)
+			}
+			if strings.Index(comment, "Did you mean") > strings.Index(comment, "Milestones in this repository") {
+				t.Errorf("Expected the suggestion to precede the list of milestones, got comment %q.", comment)
+			}
+		})
+	}
+}
+
+func TestSilentOnInvalid(t *testing.T) {
+	testcases := []struct {
+		name             string
+		body             string
+		silent           bool
+		expectedComments int
+	}{
+		{
+			name:             "Comment on an invalid milestone by default",
+			body:             "/milestone v2.0",
+			expectedComments: 1,
+		},
+		{
+			name:   "Don't comment on an invalid milestone when silent",
+			body:   "/milestone v2.0",
+			silent: true,
+		},
+		{
+			name:             "Still comment on a closed milestone when silent",
+			body:             "/milestone v0.9",
+			silent:           true,
+			expectedComments: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &listMilestonesClient{
+				FakeClient: fakegithub.NewFakeClient(),
+				milestones: []github.Milestone{
+					{Title: "v0.9", Number: 1, State: github.MilestoneStateClosed},
+					{Title: "v1.0", Number: 2, State: github.MilestoneStateOpen},
+				},
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", SilentOnInvalid: utilpointer.BoolPtr(tc.silent)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 0 {
+				t.Errorf("Expected the milestone not to be set, got %d.", fakeClient.Milestone)
+			}
+			if len(fakeClient.IssueComments[1]) != tc.expectedComments {
+				t.Errorf("Expected %d comments, got %v.", tc.expectedComments, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestFormatMilestoneList(t *testing.T) {
+	testcases := []struct {
+		name       string
+		milestones []github.Milestone
+		openOnly   bool
+		expected   string
+	}{
+		{
+			name:     "no milestones",
+			expected: "",
+		},
+		{
+			name:       "single milestone",
+			milestones: []github.Milestone{{Title: "v1.0"}},
+			expected:   "`v1.0`",
+		},
+		{
+			name:       "milestones are sorted by title",
+			milestones: []github.Milestone{{Title: "v1.2"}, {Title: "v1.0", Number: 3}, {Title: "Backlog"}},
+			expected:   "`Backlog`, `v1.0`, `v1.2`",
+		},
+		{
+			name:       "closed milestones are listed",
+			milestones: []github.Milestone{{Title: "v1.1"}, {Title: "v1.0", State: github.MilestoneStateClosed}},
+			expected:   "`v1.0`, `v1.1`",
+		},
+		{
+			name:       "closed milestones are excluded",
+			milestones: []github.Milestone{{Title: "v1.1"}, {Title: "v1.0", State: github.MilestoneStateClosed}},
+			openOnly:   true,
+			expected:   "`v1.1`",
+		},
+		{
+			name:       "no open milestones",
+			milestones: []github.Milestone{{Title: "v1.0", State: github.MilestoneStateClosed}},
+			openOnly:   true,
+			expected:   "",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			milestones := tc.milestones
+			if tc.openOnly {
+				milestones = OpenMilestones(milestones)
+			}
+			if actual := FormatMilestoneList(milestones); actual != tc.expected {
+				t.Errorf("Expected %q, got %q.", tc.expected, actual)
+			}
+			// the order of the milestones doesn't matter
+			reversed := make([]github.Milestone, 0, len(milestones))
+			for i := len(milestones) - 1; i >= 0; i-- {
+				reversed = append(reversed, milestones[i])
+			}
+			if actual := FormatMilestoneList(reversed); actual != tc.expected {
+				t.Errorf("Expected %q for the reversed milestones, got %q.", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFormatMilestoneListWithDueDates(t *testing.T) {
+	due := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	testcases := []struct {
+		name       string
+		milestones []github.Milestone
+		expected   string
+	}{
+		{
+			name:       "milestones without due dates are listed by title",
+			milestones: []github.Milestone{{Title: "v1.21"}, {Title: "v1.20"}},
+			expected:   "`v1.20`, `v1.21`",
+		},
+		{
+			name:       "the due dates of the milestones are listed",
+			milestones: []github.Milestone{{Title: "v1.21"}, {Title: "v1.20", DueOn: &due}},
+			expected:   "`v1.20` (due 2024-06-01), `v1.21`",
+		},
+		{
+			name:       "the due dates of closed milestones are omitted",
+			milestones: []github.Milestone{{Title: "v1.20", DueOn: &due, State: github.MilestoneStateClosed}},
+			expected:   "`v1.20`",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := formatMilestoneListWithDueDates(tc.milestones); actual != tc.expected {
+				t.Errorf("Expected %q, got %q.", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInvalidMilestoneDueDates(t *testing.T) {
+	due := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	fakeClient := &listMilestonesClient{
+		FakeClient: fakegithub.NewFakeClient(),
+		milestones: []github.Milestone{
+			{Title: "v1.20", Number: 1, State: github.MilestoneStateOpen, DueOn: &due},
+			{Title: "v1.21", Number: 2, State: github.MilestoneStateOpen},
+		},
+	}
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/milestone v3.0",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+	if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		t.Fatalf("Unexpected error from handle: %v.", err)
+	}
+	expected := fmt.Sprintf(invalidMilestone, "`v1.20` (due 2024-06-01), `v1.21`", clearCommands())
+	if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, expected) {
+		t.Errorf("Expected a comment containing %q, got %v.", expected, fakeClient.IssueComments[1])
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	testcases := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "", b: "", expected: 0},
+		{a: "v1.20", b: "v1.20", expected: 0},
+		{a: "v1.2o", b: "v1.20", expected: 1},
+		{a: "v1.1", b: "v1.10", expected: 1},
+		{a: "kitten", b: "sitting", expected: 3},
+		{a: "", b: "abc", expected: 3},
+	}
+	for _, tc := range testcases {
+		if actual := levenshtein(tc.a, tc.b); actual != tc.expected {
+			t.Errorf("levenshtein(%q, %q): expected %d, got %d.", tc.a, tc.b, tc.expected, actual)
+		}
+	}
+}
+
+// listMilestonesClient lists the given milestones and records the states
+// they were requested with.
+type listMilestonesClient struct {
+	*fakegithub.FakeClient
+	milestones []github.Milestone
+	states     []string
+}
+
+func (c *listMilestonesClient) ListMilestonesByState(org, repo, state string) ([]github.Milestone, error) {
+	c.states = append(c.states, state)
+	var milestones []github.Milestone
+	for _, ms := range c.milestones {
+		if state == github.MilestoneStateAll || ms.State == state {
+			milestones = append(milestones, ms)
+		}
+	}
+	return milestones, nil
+}
+
+func TestListMilestonesState(t *testing.T) {
+	testcases := []struct {
+		name                  string
+		body                  string
+		allowClosedMilestones bool
+		expectedStates        []string
+	}{
+		{
+			name:           "List only open milestones by default",
+			body:           "/milestone v1.1",
+			expectedStates: []string{github.MilestoneStateOpen},
+		},
+		{
+			name:                  "List all milestones when closed milestones are allowed",
+			body:                  "/milestone v1.0",
+			allowClosedMilestones: true,
+			expectedStates:        []string{github.MilestoneStateAll},
+		},
+		{
+			name:           "List the closed milestones to explain an unknown milestone",
+			body:           "/milestone v1.0",
+			expectedStates: []string{github.MilestoneStateOpen, github.MilestoneStateClosed},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &listMilestonesClient{
+				FakeClient: fakegithub.NewFakeClient(),
+				milestones: []github.Milestone{
+					{Title: "v1.0", Number: 1, State: github.MilestoneStateClosed},
+					{Title: "v1.1", Number: 2, State: github.MilestoneStateOpen},
+				},
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", AllowClosedMilestones: utilpointer.BoolPtr(tc.allowClosedMilestones)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if !reflect.DeepEqual(tc.expectedStates, fakeClient.states) {
+				t.Errorf("Expected milestones to be listed with the states %q, got %q.", tc.expectedStates, fakeClient.states)
+			}
+		})
+	}
+}
+
+func TestClosedMilestones(t *testing.T) {
+	testcases := []struct {
+		name                  string
+		body                  string
+		allowClosedMilestones bool
+		expectedMilestone     int
+		expectedComment       string
+	}{
+		{
+			name:              "Set an open milestone",
+			body:              "/milestone v1.1",
+			expectedMilestone: 2,
+		},
+		{
+			name:            "Reject a closed milestone",
+			body:            "/milestone v1.0",
+			expectedComment: fmt.Sprintf(closedMilestone, "v1.0", "`v1.1`, `v1.2`", "`/milestone clear`, `/milestone none` or `/milestone -`"),
+		},
+		{
+			name:                  "Set a closed milestone when closed milestones are allowed",
+			body:                  "/milestone v1.0",
+			allowClosedMilestones: true,
+			expectedMilestone:     1,
+		},
+		{
+			name:            "List only open milestones for an invalid milestone",
+			body:            "/milestone abc",
+			expectedComment: fmt.Sprintf(invalidMilestone, "`v1.1`, `v1.2`", "`/milestone clear`, `/milestone none` or `/milestone -`"),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &listMilestonesClient{
+				FakeClient: fakegithub.NewFakeClient(),
+				milestones: []github.Milestone{
+					{Title: "v1.0", Number: 1, State: github.MilestoneStateClosed},
+					{Title: "v1.1", Number: 2, State: github.MilestoneStateOpen},
+					{Title: "v1.2", Number: 3, State: github.MilestoneStateOpen},
+				},
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", AllowClosedMilestones: utilpointer.BoolPtr(tc.allowClosedMilestones)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			var actual string
+			if len(fakeClient.IssueComments[1]) > 0 {
+				actual = fakeClient.IssueComments[1][0].Body
+			}
+			if tc.expectedComment == "" && actual != "" {
+				t.Errorf("Expected no comment, got %q.", actual)
+			}
+			if !strings.Contains(actual, tc.expectedComment) {
+				t.Errorf("Expected comment to contain %q, got %q.", tc.expectedComment, actual)
+			}
+		})
+	}
+}
+
+func TestMilestoneByNumber(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		expectedMilestone int
+		shouldComment     bool
+	}{
+		{
+			name:              "Set the milestone by number with a hash",
+			body:              "/milestone #42",
+			expectedMilestone: 42,
+		},
+		{
+			name:              "Set the milestone by number with a prefix",
+			body:              "/milestone number:42",
+			expectedMilestone: 42,
+		},
+		{
+			name:          "Don't set a milestone which doesn't exist",
+			body:          "/milestone #43",
+			shouldComment: true,
+		},
+		{
+			name:          "Don't set a milestone for a malformed number",
+			body:          "/milestone #4x",
+			shouldComment: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1, "v1.1": 42}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			comments := len(fakeClient.IssueComments[1])
+			if tc.shouldComment && comments != 1 {
+				t.Errorf("1 comment should have been made, but %d comments were made.", comments)
+			} else if !tc.shouldComment && comments != 0 {
+				t.Errorf("No comment should have been made, but %d comments were made.", comments)
+			}
+		})
+	}
+}
+
+func TestCommandsInLongerComments(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		expectedMilestone int
+	}{
+		{
+			name:              "Command between other lines",
+			body:              "Thanks for the fix!\n/milestone v1.0\nLet's ship it.",
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Command followed by a windows line ending",
+			body:              "/milestone v1.0 \r\nLet's ship it.",
+			expectedMilestone: 1,
+		},
+		{
+			name: "Argument on the next line isn't part of the command",
+			body: "/milestone\nv1.0",
+		},
+		{
+			name: "Command in the middle of a line is ignored",
+			body: "Please use /milestone v1.0 for this one.",
+		},
+		{
+			name: "Inline code is ignored",
+			body: "Use `/milestone v1.0` to set the milestone.",
+		},
+		{
+			name: "Quoted command is ignored",
+			body: "> /milestone v1.0\n\nI don't think so.",
+		},
+		{
+			name:              "Command after a quoted reply is handled",
+			body:              "> /milestone v1.0\n\n/milestone v1.1",
+			expectedMilestone: 2,
+		},
+		{
+			name: "Command in a code fence is ignored",
+			body: "To set the milestone:\n```\n/milestone v1.0\n```",
+		},
+		{
+			name: "Command in an unterminated code fence is ignored",
+			body: "```shell\n/milestone v1.0",
+		},
+		{
+			name:              "Command after a code fence is handled",
+			body:              "~~~\n/milestone v1.0\n~~~\n/milestone v1.1",
+			expectedMilestone: 2,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1, "v1.1": 2}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if len(fakeClient.IssueComments[1]) != 0 {
+				t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestMilestoneWhitespace(t *testing.T) {
+	testcases := []struct {
+		name string
+		body string
+	}{
+		{name: "leading whitespace", body: "/milestone    Release 1.20"},
+		{name: "trailing whitespace", body: "/milestone Release 1.20  \t"},
+		{name: "internal whitespace", body: "/milestone Release  \t 1.20"},
+		{name: "tab separator", body: "/milestone\tRelease 1.20"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"Release 1.20": 1, "Release 1.21": 2}
+			fakeClient.Issues[1] = &github.Issue{Number: 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", ConfirmMilestone: utilpointer.BoolPtr(true)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 1 {
+				t.Errorf("Expected milestone 1, got %d.", fakeClient.Milestone)
+			}
+			expected := fmt.Sprintf(milestoneSet, "Release 1.20")
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, expected) {
+				t.Errorf("Expected a comment confirming the canonical title with %q, got %v.", expected, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestClearKeywords(t *testing.T) {
+	for _, body := range []string{"/milestone clear", "/milestone none", "/milestone -", "/milestone None"} {
+		t.Run(body, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Milestone = 1
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 0 {
+				t.Errorf("Expected the milestone to be cleared, got %d.", fakeClient.Milestone)
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, milestoneCleared) {
+				t.Errorf("Expected a comment confirming the milestone was cleared, got %v.", fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestRequireClearConfirmation(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		requireConfirm    bool
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Ask for a confirmation to clear the milestone",
+			body:              "/milestone clear",
+			requireConfirm:    true,
+			expectedMilestone: 1,
+			expectedComment:   fmt.Sprintf(confirmClear, "clear", confirmKeyword),
+		},
+		{
+			name:              "Ask for a confirmation with the keyword used",
+			body:              "/milestone None",
+			requireConfirm:    true,
+			expectedMilestone: 1,
+			expectedComment:   fmt.Sprintf(confirmClear, "none", confirmKeyword),
+		},
+		{
+			name:            "Clear the milestone once confirmed",
+			body:            "/milestone clear confirm",
+			requireConfirm:  true,
+			expectedComment: milestoneCleared,
+		},
+		{
+			name:            "Clear the milestone without a confirmation by default",
+			body:            "/milestone clear",
+			expectedComment: milestoneCleared,
+		},
+		{
+			name:            "Accept a confirmation which isn't required",
+			body:            "/milestone - confirm",
+			expectedComment: milestoneCleared,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Milestone = 1
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", RequireClearConfirmation: utilpointer.BoolPtr(tc.requireConfirm)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestClearCondition(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		current           int
+		requireConfirm    bool
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:            "Clear the milestone if it is the expected one",
+			body:            "/milestone clear if:v1.20",
+			current:         1,
+			expectedComment: milestoneCleared,
+		},
+		{
+			name:            "Compare the milestones regardless of case",
+			body:            "/milestone none if:V1.20",
+			current:         1,
+			expectedComment: milestoneCleared,
+		},
+		{
+			name:              "Keep a milestone which changed in the meantime",
+			body:              "/milestone clear if:v1.20",
+			current:           2,
+			expectedMilestone: 2,
+			expectedComment:   fmt.Sprintf(clearConditionFailed, "`v1.21`", "v1.20"),
+		},
+		{
+			name:            "Report a milestone cleared in the meantime",
+			body:            "/milestone clear if:v1.20",
+			expectedComment: fmt.Sprintf(clearConditionFailed, noMilestone, "v1.20"),
+		},
+		{
+			name:            "Clear the milestone once confirmed with a condition",
+			body:            "/milestone clear confirm if:v1.20",
+			current:         1,
+			requireConfirm:  true,
+			expectedComment: milestoneCleared,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 1, "v1.21": 2}
+			fakeClient.Milestone = tc.current
+			issue := &github.Issue{Number: 1}
+			for title, number := range fakeClient.MilestoneMap {
+				if number == tc.current {
+					issue.Milestone = github.Milestone{Title: title, Number: number}
+				}
+			}
+			fakeClient.Issues = map[int]*github.Issue{1: issue}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", RequireClearConfirmation: utilpointer.BoolPtr(tc.requireConfirm)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestMilestoneURL(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Set the milestone the URL refers to",
+			body:              "/milestone https://github.com/org/repo/milestone/2",
+			expectedMilestone: 2,
+		},
+		{
+			name:              "Accept a URL with a trailing slash and a query",
+			body:              "/milestone https://github.com/Org/Repo/milestone/2/?closed=1",
+			expectedMilestone: 2,
+		},
+		{
+			name:            "Reject a URL of another repository",
+			body:            "/milestone https://github.com/org/other/milestone/2",
+			expectedComment: fmt.Sprintf(foreignMilestoneURL, "org", "other"),
+		},
+		{
+			name:            "Reject a URL of a milestone which doesn't exist",
+			body:            "/milestone https://github.com/org/repo/milestone/3",
+			expectedComment: "The provided milestone is not valid for this repository.",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 1, "v1.21": 2}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || !strings.Contains(fakeClient.IssueComments[1][0].Body, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestQuotedMilestone(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		expectedMilestone int
+	}{
+		{
+			name:              "quoted title",
+			body:              `/milestone "Q3 Planning"`,
+			expectedMilestone: 1,
+		},
+		{
+			name:              "unquoted multi-word title",
+			body:              "/milestone Q3 Planning",
+			expectedMilestone: 1,
+		},
+		{
+			name:              "quoted title with trailing spaces",
+			body:              `/milestone "Q3 Planning"   `,
+			expectedMilestone: 1,
+		},
+		{
+			name:              "quoted title with spaces inside the quotes",
+			body:              `/milestone " Q3 Planning "`,
+			expectedMilestone: 1,
+		},
+		{
+			name:              "unquoted title containing the clear keyword",
+			body:              "/milestone clear skies",
+			expectedMilestone: 2,
+		},
+		{
+			name:              "quoted clear keyword is a title",
+			body:              `/milestone "clear"`,
+			expectedMilestone: 3,
+		},
+		{
+			name: "unquoted clear keyword clears the milestone",
+			body: "/milestone clear",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"Q3 Planning": 1, "clear skies": 2, "clear": 3}
+			fakeClient.Milestone = 10
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+		})
+	}
+}
+
+func TestTitlePattern(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Set a milestone whose title matches the pattern",
+			body:              "/milestone v1.20",
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Set a milestone referred to by number whose title matches the pattern",
+			body:              "/milestone #1",
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Reject an existing milestone whose title does not match the pattern",
+			body:              "/milestone v1.20-typo",
+			expectedMilestone: 10,
+			expectedComment:   fmt.Sprintf(titleNotAllowed, "v1.20-typo", `^v\d+\.\d+$`),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 1, "v1.20-typo": 2}
+			fakeClient.Milestone = 10
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {
+				MaintainersTeam: "leads",
+				TitlePattern:    `^v\d+\.\d+$`,
+				TitleRe:         regexp.MustCompile(`^v\d+\.\d+$`),
+			}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			var expected []string
+			if tc.expectedComment != "" {
+				expected = []string{plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, tc.expectedComment)}
+			}
+			var actual []string
+			for _, c := range fakeClient.IssueComments[1] {
+				actual = append(actual, c.Body)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Expected comments %q, got %q.", expected, actual)
+			}
+		})
+	}
+}
+
+func TestMilestoneReactions(t *testing.T) {
+	commentID := 42
+	testcases := []struct {
+		name              string
+		body              string
+		commenter         string
+		commentID         *int
+		expectedMilestone int
+		expectedReactions []string
+		expectedComments  int
+	}{
+		{
+			name:              "React with a thumbs up when the milestone is set",
+			body:              "/milestone v1.0",
+			commenter:         "sig-lead",
+			commentID:         &commentID,
+			expectedMilestone: 1,
+			expectedReactions: []string{"org/repo#42:+1"},
+		},
+		{
+			name:              "React with a thumbs up when the milestone is cleared",
+			body:              "/milestone clear",
+			commenter:         "sig-lead",
+			commentID:         &commentID,
+			expectedReactions: []string{"org/repo#42:+1"},
+		},
+		{
+			name:              "React with a thumbs down when the milestone is invalid",
+			body:              "/milestone v2.0",
+			commenter:         "sig-lead",
+			commentID:         &commentID,
+			expectedMilestone: 10,
+			expectedReactions: []string{"org/repo#42:-1"},
+		},
+		{
+			name:              "React with a thumbs down when the user is unauthorized",
+			body:              "/milestone v1.0",
+			commenter:         "sig-follow",
+			commentID:         &commentID,
+			expectedMilestone: 10,
+			expectedReactions: []string{"org/repo#42:-1"},
+		},
+		{
+			name:              "Comment on failure when there is no comment to react to",
+			body:              "/milestone v2.0",
+			commenter:         "sig-lead",
+			expectedMilestone: 10,
+			expectedComments:  1,
+		},
+		{
+			name:              "Don't comment on success when there is no comment to react to",
+			body:              "/milestone v1.0",
+			commenter:         "sig-lead",
+			expectedMilestone: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Milestone = 10
+			fakeClient.Issues[1] = &github.Issue{Number: 1}
+			e := &github.GenericCommentEvent{
+				Action:    github.GenericCommentActionCreated,
+				Body:      tc.body,
+				Number:    1,
+				CommentID: tc.commentID,
+				Repo:      github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:      github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", UseReactions: utilpointer.BoolPtr(true)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if !reflect.DeepEqual(tc.expectedReactions, fakeClient.CommentReactionsAdded) {
+				t.Errorf("Expected reactions %v, got %v.", tc.expectedReactions, fakeClient.CommentReactionsAdded)
+			}
+			if comments := len(fakeClient.IssueComments[1]); comments != tc.expectedComments {
+				t.Errorf("Expected %d comments, got %d.", tc.expectedComments, comments)
+			}
+		})
+	}
+}
+
+func TestMilestoneConfirmation(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		previousMilestone int
+		previousTitle     string
+		expectedMilestone int
+		confirmMilestone  bool
+		expectedComments  []string
+	}{
+		{
+			name:              "Confirm the milestone when enabled",
+			body:              "/milestone v1.0",
+			expectedMilestone: 1,
+			confirmMilestone:  true,
+			expectedComments:  []string{fmt.Sprintf(milestoneSet, "v1.0") + fmt.Sprintf(milestoneChangedBy, "sig-lead", "none", "v1.0")},
+		},
+		{
+			name:              "Confirm the milestone using its canonical title",
+			body:              "/milestone V1.0",
+			expectedMilestone: 1,
+			confirmMilestone:  true,
+			expectedComments:  []string{fmt.Sprintf(milestoneSet, "v1.0") + fmt.Sprintf(milestoneChangedBy, "sig-lead", "none", "v1.0")},
+		},
+		{
+			name:              "Confirm changing the milestone from the previous one",
+			body:              "/milestone v1.0",
+			previousMilestone: 2,
+			previousTitle:     "v0.9",
+			expectedMilestone: 1,
+			confirmMilestone:  true,
+			expectedComments:  []string{fmt.Sprintf(milestoneSet, "v1.0") + fmt.Sprintf(milestoneChangedBy, "sig-lead", "v0.9", "v1.0")},
+		},
+		{
+			name:              "Don't confirm the milestone when disabled",
+			body:              "/milestone v1.0",
+			expectedMilestone: 1,
+			confirmMilestone:  false,
+		},
+		{
+			name:              "Confirm clearing the milestone when enabled",
+			body:              "/milestone clear",
+			previousMilestone: 1,
+			previousTitle:     "v1.0",
+			confirmMilestone:  true,
+			expectedComments:  []string{milestoneCleared + fmt.Sprintf(milestoneChangedBy, "sig-lead", "v1.0", "none")},
+		},
+		{
+			name:              "Confirm clearing the milestone even when disabled",
+			body:              "/milestone clear",
+			previousMilestone: 1,
+			previousTitle:     "v1.0",
+			confirmMilestone:  false,
+			expectedComments:  []string{milestoneCleared + fmt.Sprintf(milestoneChangedBy, "sig-lead", "v1.0", "none")},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1, "v0.9": 2}
+			fakeClient.Milestone = tc.previousMilestone
+			fakeClient.Issues[1] = &github.Issue{Number: 1, Milestone: github.Milestone{Title: tc.previousTitle, Number: tc.previousMilestone}}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", ConfirmMilestone: utilpointer.BoolPtr(tc.confirmMilestone)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+
+			var expected []string
+			for _, c := range tc.expectedComments {
+				expected = append(expected, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, c))
+			}
+			var actual []string
+			for _, c := range fakeClient.IssueComments[1] {
+				actual = append(actual, c.Body)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Expected comments %q, got %q.", expected, actual)
+			}
+		})
+	}
+}
+
+type erroringClient struct {
+	*fakegithub.FakeClient
+	setErr   error
+	clearErr error
+}
+
+func (c *erroringClient) SetMilestone(org, repo string, issueNum, milestoneNum int) error {
+	if c.setErr != nil {
+		return c.setErr
+	}
+	return c.FakeClient.SetMilestone(org, repo, issueNum, milestoneNum)
+}
+
+func (c *erroringClient) ClearMilestone(org, repo string, num int) error {
+	if c.clearErr != nil {
+		return c.clearErr
+	}
+	return c.FakeClient.ClearMilestone(org, repo, num)
+}
+
+func TestMilestoneErrors(t *testing.T) {
+	testcases := []struct {
+		name     string
+		body     string
+		setErr   error
+		clearErr error
+	}{
+		{
+			name:   "SetMilestone error is returned",
+			body:   "/milestone v1.0",
+			setErr: errors.New("injected SetMilestone error"),
+		},
+		{
+			name:     "ClearMilestone error is returned",
+			body:     "/milestone clear",
+			clearErr: errors.New("injected ClearMilestone error"),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &erroringClient{FakeClient: fakegithub.NewFakeClient(), setErr: tc.setErr, clearErr: tc.clearErr}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone)
+			if err == nil {
+				t.Fatal("Expected an error from handle, but got none.")
+			}
+		})
+	}
+}
+
+func TestMilestoneDryRun(t *testing.T) {
+	testcases := []struct {
+		name            string
+		body            string
+		expectedComment string
+	}{
+		{
+			name:            "Preview setting the milestone",
+			body:            "/milestone V1.0",
+			expectedComment: fmt.Sprintf(wouldSetMilestone, "v1.0"),
+		},
+		{
+			name:            "Preview clearing the milestone",
+			body:            "/milestone clear",
+			expectedComment: wouldClearMilestone,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// any call to SetMilestone or ClearMilestone makes handle fail
+			fakeClient := &erroringClient{
+				FakeClient: fakegithub.NewFakeClient(),
+				setErr:     errors.New("unexpected SetMilestone call"),
+				clearErr:   errors.New("unexpected ClearMilestone call"),
+			}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			fakeClient.Milestone = 2
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", DryRun: utilpointer.BoolPtr(true)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.Milestone != 2 {
+				t.Errorf("Expected the milestone to remain 2, got %d.", fakeClient.Milestone)
+			}
+			expected := []string{plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, tc.expectedComment)}
+			var actual []string
+			for _, c := range fakeClient.IssueComments[1] {
+				actual = append(actual, c.Body)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Expected comments %q, got %q.", expected, actual)
+			}
+		})
+	}
+}
+
+type rateLimitedClient struct {
+	*fakegithub.FakeClient
+	err error
+}
+
+func (c *rateLimitedClient) ListMilestonesByState(org, repo, state string) ([]github.Milestone, error) {
+	return nil, c.err
+}
+
+func TestMilestoneRateLimited(t *testing.T) {
+	testcases := []struct {
+		name            string
+		err             error
+		expectedComment bool
+	}{
+		{
+			name:            "Ask to try again when rate limited",
+			err:             fmt.Errorf("listing milestones: %w", github.RateLimitError{Wait: time.Hour}),
+			expectedComment: true,
+		},
+		{
+			name: "Return other errors",
+			err:  errors.New("injected ListMilestones error"),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &rateLimitedClient{FakeClient: fakegithub.NewFakeClient(), err: tc.err}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone)
+			if err == nil {
+				t.Fatal("Expected an error from handle, but got none.")
+			}
+			if errors.Is(err, ErrRateLimited) != tc.expectedComment {
+				t.Errorf("Expected the error to be ErrRateLimited: %t, got: %v.", tc.expectedComment, err)
+			}
+			var expected []string
+			if tc.expectedComment {
+				expected = []string{plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, rateLimited)}
+			}
+			var actual []string
+			for _, c := range fakeClient.IssueComments[1] {
+				actual = append(actual, c.Body)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Expected comments %q, got %q.", expected, actual)
+			}
+		})
+	}
+}
+
+func TestMilestoneAlreadySet(t *testing.T) {
+	testcases := []struct {
+		name              string
+		initial           int
+		body              string
+		confirm           bool
+		expectedSets      int
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Don't set the milestone again",
+			initial:           1,
+			body:              "/milestone v1.20",
+			expectedMilestone: 1,
+			expectedComment:   fmt.Sprintf(milestoneAlreadySet, "v1.20"),
+		},
+		{
+			name:              "Don't set the milestone again when confirming changes",
+			initial:           1,
+			body:              "/milestone v1.20",
+			confirm:           true,
+			expectedMilestone: 1,
+			expectedComment:   fmt.Sprintf(milestoneAlreadySet, "v1.20"),
+		},
+		{
+			name:              "Set a different milestone",
+			initial:           1,
+			body:              "/milestone v1.21",
+			expectedSets:      1,
+			expectedMilestone: 2,
+		},
+		{
+			name:              "Confirm a different milestone",
+			initial:           1,
+			body:              "/milestone v1.21",
+			confirm:           true,
+			expectedSets:      1,
+			expectedMilestone: 2,
+			expectedComment:   fmt.Sprintf(milestoneSet, "v1.21") + fmt.Sprintf(milestoneChangedBy, "sig-lead", "v1.20", "v1.21"),
+		},
+		{
+			name:              "Set the first milestone",
+			body:              "/milestone v1.20",
+			expectedSets:      1,
+			expectedMilestone: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &settingClient{FakeClient: fakegithub.NewFakeClient()}
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 1, "v1.21": 2}
+			fakeClient.Milestone = tc.initial
+			fakeClient.Issues[1] = &github.Issue{Number: 1}
+			if tc.initial != 0 {
+				fakeClient.Issues[1].Milestone = github.Milestone{Title: fmt.Sprintf("v1.%d", 19+tc.initial), Number: tc.initial}
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "already-set-repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads", ConfirmMilestone: utilpointer.BoolPtr(tc.confirm)}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if fakeClient.calls != tc.expectedSets {
+				t.Errorf("Expected the milestone to be set %d times, got %d.", tc.expectedSets, fakeClient.calls)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			var actual string
+			if comments := fakeClient.IssueComments[1]; len(comments) > 0 {
+				actual = comments[len(comments)-1].Body
+			}
+			if tc.expectedComment == "" {
+				if actual != "" {
+					t.Errorf("Expected no comment, got %q.", actual)
+				}
+			} else if !strings.Contains(actual, tc.expectedComment) {
+				t.Errorf("Expected a comment containing %q, got %q.", tc.expectedComment, actual)
+			}
+		})
+	}
+}
+
+func TestMilestoneCommandsMetric(t *testing.T) {
+	testcases := []struct {
+		name            string
+		body            string
+		commenter       string
+		expectedOutcome string
+	}{
+		{
+			name:            "Count a set milestone",
+			body:            "/milestone v1.0",
+			commenter:       "sig-lead",
+			expectedOutcome: outcomeSet,
+		},
+		{
+			name:            "Count a cleared milestone",
+			body:            "/milestone clear",
+			commenter:       "sig-lead",
+			expectedOutcome: outcomeCleared,
+		},
+		{
+			name:            "Count an unauthorized command",
+			body:            "/milestone v1.0",
+			commenter:       "sig-follow",
+			expectedOutcome: outcomeUnauthorized,
+		},
+		{
+			name:            "Count an invalid milestone",
+			body:            "/milestone v2.0",
+			commenter:       "sig-lead",
+			expectedOutcome: outcomeInvalid,
+		},
+	}
+
+	outcomes := []string{outcomeSet, outcomeCleared, outcomeUnauthorized, outcomeInvalid}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "metrics-repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+
+			before := map[string]float64{}
+			beforeStats := map[string]int{}
+			for _, outcome := range outcomes {
+				before[outcome] = testutil.ToFloat64(milestoneCommands.WithLabelValues("org", "metrics-repo", outcome))
+				beforeStats[outcome] = milestoneStats.Count("org", "metrics-repo", outcome)
+			}
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			for _, outcome := range outcomes {
+				expected, expectedStats := before[outcome], beforeStats[outcome]
+				if outcome == tc.expectedOutcome {
+					expected++
+					expectedStats++
+				}
+				if actual := testutil.ToFloat64(milestoneCommands.WithLabelValues("org", "metrics-repo", outcome)); actual != expected {
+					t.Errorf("Expected %v commands with outcome %q, got %v.", expected, outcome, actual)
+				}
+				if actual := milestoneStats.Count("org", "metrics-repo", outcome); actual != expectedStats {
+					t.Errorf("Expected %d commands with outcome %q in the stats, got %d.", expectedStats, outcome, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestMilestoneContextCanceled(t *testing.T) {
+	fakeClient := fakegithub.NewFakeClient()
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/milestone v1.0",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := handle(ctx, &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected handle to fail with %v, got %v.", context.Canceled, err)
+	}
+	if fakeClient.Milestone != 0 {
+		t.Errorf("Expected no milestone to be set, got %d.", fakeClient.Milestone)
+	}
+	if len(fakeClient.IssueComments[1]) != 0 {
+		t.Errorf("Expected no comment, got %v.", fakeClient.IssueComments[1])
+	}
+}
+
+func TestMilestoneContextCanceledDuringCall(t *testing.T) {
+	var once sync.Once
+	received := make(chan struct{})
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(received) })
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+	ghc, err := github.NewClient(func() []byte { return nil }, func(content []byte) []byte { return content }, ts.URL, ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to create the GitHub client: %v.", err)
+	}
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/milestone v1.0",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-received
+		cancel()
+	}()
+	done := make(chan error)
+	go func() {
+		done <- handle(ctx, &contextClient{client: ghc.WithContext(ctx)}, logrus.WithField("plugin", pluginName), e, repoMilestone)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected handle to fail with %v, got %v.", context.Canceled, err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the request in flight to be aborted once the context was canceled.")
+	}
+}
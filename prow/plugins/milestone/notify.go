@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// defaultNotifyRateLimit is used when no notify_rate_limit is configured.
+const defaultNotifyRateLimit = 10
+
+var notifyClient = &http.Client{Timeout: 30 * time.Second}
+
+// notifyLimiters limit the notifications posted to each URL, so that a burst
+// of milestone changes doesn't overwhelm the receiver.
+var notifyLimiters = newNotifyLimiter(time.Now)
+
+// notifyLimiter holds a token bucket per URL which allows as many
+// notifications per second as configured, in bursts of at most as many.
+type notifyLimiter struct {
+	lock     sync.Mutex
+	now      func() time.Time
+	limiters map[string]*rate.Limiter
+}
+
+func newNotifyLimiter(now func() time.Time) *notifyLimiter {
+	return &notifyLimiter{now: now, limiters: map[string]*rate.Limiter{}}
+}
+
+// allow returns whether a notification may be posted to url right away
+// without exceeding perSecond notifications per second. It never blocks.
+func (l *notifyLimiter) allow(url string, perSecond int) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	limiter, ok := l.limiters[url]
+	if !ok || limiter.Burst() != perSecond {
+		// the limiter is replaced if the configured rate changed
+		limiter = rate.NewLimiter(rate.Limit(perSecond), perSecond)
+		l.limiters[url] = limiter
+	}
+	return limiter.AllowN(l.now(), 1)
+}
+
+// notifyJitter delays each notification by up to the interval between two
+// notifications at the rate limit, so that a burst is spread out over time.
+var notifyJitter = func(perSecond int) time.Duration {
+	return time.Duration(rand.Int63n(int64(time.Second) / int64(perSecond)))
+}
+
+// milestoneEvent is posted to the configured NotifyURL when a /milestone
+// command changes the milestone of an issue or PR. OldMilestone and
+// NewMilestone are empty if the issue or PR had or has no milestone.
+type milestoneEvent struct {
+	Org          string `json:"org"`
+	Repo         string `json:"repo"`
+	Number       int    `json:"number"`
+	OldMilestone string `json:"old_milestone"`
+	NewMilestone string `json:"new_milestone"`
+	Actor        string `json:"actor"`
+}
+
+// notify posts the event to the NotifyURL of the milestone config in the
+// background, so that a slow or failing receiver does not delay or fail the
+// command. Events exceeding the rate limit are dropped, and the events of
+// audit-only repos are only recorded. Failures are only logged.
+func notify(log *logrus.Entry, milestone plugins.Milestone, event milestoneEvent) {
+	url := milestone.NotifyURL
+	perSecond := milestone.NotifyRateLimit
+	if perSecond == 0 {
+		perSecond = defaultNotifyRateLimit
+	}
+	if !notifyLimiters.allow(url, perSecond) {
+		log.Warnf("Not notifying %s of the milestone change, as more than %d notifications per second would be posted.", url, perSecond)
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.WithError(err).Error("Error marshalling the milestone event.")
+		return
+	}
+	if milestone.IsAuditOnly() {
+		plugins.Audit(plugins.AuditRecord{Plugin: pluginName, Actor: event.Actor, Action: plugins.AuditNotify, Target: url, Value: string(body)})
+		return
+	}
+	go func() {
+		time.Sleep(notifyJitter(perSecond))
+		resp, err := notifyClient.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.WithError(err).Warnf("Error notifying %s of the milestone change.", url)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			log.Warnf("Notifying %s of the milestone change failed with status %d.", url, resp.StatusCode)
+		}
+	}()
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestMilestoneNotify(t *testing.T) {
+	testcases := []struct {
+		name          string
+		body          string
+		previousTitle string
+		status        int
+		expected      milestoneEvent
+	}{
+		{
+			name:     "Notify setting the milestone",
+			body:     "/milestone v1.0",
+			status:   http.StatusOK,
+			expected: milestoneEvent{Org: "org", Repo: "repo", Number: 1, NewMilestone: "v1.0", Actor: "sig-lead"},
+		},
+		{
+			name:          "Notify changing the milestone",
+			body:          "/milestone v1.0",
+			previousTitle: "v0.9",
+			status:        http.StatusOK,
+			expected:      milestoneEvent{Org: "org", Repo: "repo", Number: 1, OldMilestone: "v0.9", NewMilestone: "v1.0", Actor: "sig-lead"},
+		},
+		{
+			name:          "Notify clearing the milestone",
+			body:          "/milestone clear",
+			previousTitle: "v1.0",
+			status:        http.StatusOK,
+			expected:      milestoneEvent{Org: "org", Repo: "repo", Number: 1, OldMilestone: "v1.0", Actor: "sig-lead"},
+		},
+		{
+			name:     "A failing receiver doesn't fail the command",
+			body:     "/milestone v1.0",
+			status:   http.StatusInternalServerError,
+			expected: milestoneEvent{Org: "org", Repo: "repo", Number: 1, NewMilestone: "v1.0", Actor: "sig-lead"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			events := make(chan milestoneEvent, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var event milestoneEvent
+				if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+					t.Errorf("Failed to decode the event: %v.", err)
+				}
+				if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+					t.Errorf("Expected Content-Type application/json, got %q.", contentType)
+				}
+				events <- event
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1, "v0.9": 2}
+			fakeClient.Issues[1] = &github.Issue{Number: 1, Milestone: github.Milestone{Title: tc.previousTitle}}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", NotifyURL: server.URL}}
+
+			if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			select {
+			case event := <-events:
+				if !reflect.DeepEqual(tc.expected, event) {
+					t.Errorf("Expected event %+v, got %+v.", tc.expected, event)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Timed out waiting for the event.")
+			}
+		})
+	}
+}
+
+func TestNotifyLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newNotifyLimiter(func() time.Time { return now })
+	allowed := func(url string, perSecond, attempts int) int {
+		count := 0
+		for i := 0; i < attempts; i++ {
+			if limiter.allow(url, perSecond) {
+				count++
+			}
+		}
+		return count
+	}
+
+	if count := allowed("https://example.com", 3, 10); count != 3 {
+		t.Errorf("Expected a burst to be capped at 3 notifications, got %d.", count)
+	}
+	if count := allowed("https://other.example.com", 3, 10); count != 3 {
+		t.Errorf("Expected the notifications to be limited per URL, got %d for another URL.", count)
+	}
+	now = now.Add(time.Second)
+	if count := allowed("https://example.com", 3, 10); count != 3 {
+		t.Errorf("Expected 3 more notifications a second later, got %d.", count)
+	}
+	now = now.Add(time.Second)
+	if count := allowed("https://example.com", 5, 10); count != 5 {
+		t.Errorf("Expected a changed rate limit to apply, got %d notifications.", count)
+	}
+}
+
+func TestMilestoneNotifyRateLimit(t *testing.T) {
+	oldLimiters, oldJitter := notifyLimiters, notifyJitter
+	defer func() { notifyLimiters, notifyJitter = oldLimiters, oldJitter }()
+	now := time.Now()
+	notifyLimiters = newNotifyLimiter(func() time.Time { return now })
+	notifyJitter = func(int) time.Duration { return 0 }
+
+	events := make(chan milestoneEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event milestoneEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode the event: %v.", err)
+		}
+		events <- event
+	}))
+	defer server.Close()
+
+	fakeClient := fakegithub.NewFakeClient()
+	fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+	fakeClient.Issues[1] = &github.Issue{Number: 1}
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", NotifyURL: server.URL, NotifyRateLimit: 2}}
+	for i := 0; i < 5; i++ {
+		e := &github.GenericCommentEvent{
+			Action: github.GenericCommentActionCreated,
+			Body:   "/milestone v1.0",
+			Number: 1,
+			Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			User:   github.User{Login: "sig-lead"},
+		}
+		if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+			t.Fatalf("Unexpected error from handle: %v.", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-events:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Timed out waiting for event %d.", i+1)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Expected the notifications beyond the rate limit to be dropped, got %+v.", event)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
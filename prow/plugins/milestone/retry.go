@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+// defaultMaxAttempts is used when no max_attempts is configured.
+const defaultMaxAttempts = 3
+
+// initialRetryBackoff is how long the first retry of a failed call waits. The
+// wait doubles with every further retry.
+var initialRetryBackoff = time.Second
+
+// retryTimeout bounds the time spent on all the attempts of a call, including
+// the retries of the GitHub client, so that a command doesn't hold up hook.
+var retryTimeout = 30 * time.Second
+
+// retryingClient retries listing the milestones and setting the milestone of
+// an issue or PR while GitHub fails them with a 5xx status code. The GitHub
+// client already retries such a request a few times in quick succession, but
+// GitHub often keeps failing the milestone requests for longer than that, so
+// the whole call is retried after a longer backoff, within retryTimeout.
+type retryingClient struct {
+	githubClient
+	log         *logrus.Entry
+	maxAttempts int
+}
+
+func (c *retryingClient) ListMilestonesByState(ctx context.Context, org, repo, state string) ([]github.Milestone, error) {
+	var milestones []github.Milestone
+	err := c.retry(ctx, func(ctx context.Context) error {
+		var err error
+		milestones, err = c.githubClient.ListMilestonesByState(ctx, org, repo, state)
+		return err
+	})
+	return milestones, err
+}
+
+func (c *retryingClient) SetMilestone(ctx context.Context, org, repo string, issueNum, milestoneNum int) error {
+	return c.retry(ctx, func(ctx context.Context) error {
+		return c.githubClient.SetMilestone(ctx, org, repo, issueNum, milestoneNum)
+	})
+}
+
+// retry calls fn until it succeeds, fails with an error which isn't a server
+// error, has been called maxAttempts times, or the next attempt would start
+// after retryTimeout.
+func (c *retryingClient) retry(ctx context.Context, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, retryTimeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	backoff := initialRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !github.IsServerError(err) || attempt >= c.maxAttempts || time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		c.log.WithError(err).Warnf("GitHub failed attempt %d of %d, retrying in %s.", attempt, c.maxAttempts, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// failingClient fails the first calls listing the milestones with err, and
+// those setting the milestone through its settingClient.
+type failingClient struct {
+	*settingClient
+	err        error
+	failures   int
+	listCalls  int
+	listFailed int
+}
+
+func (c *failingClient) ListMilestonesByState(org, repo, state string) ([]github.Milestone, error) {
+	c.listCalls++
+	if c.listFailed < c.failures {
+		c.listFailed++
+		return nil, c.err
+	}
+	return c.FakeClient.ListMilestonesByState(org, repo, state)
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	initialRetryBackoff = time.Millisecond
+	defer func() { initialRetryBackoff = time.Second }()
+
+	testcases := []struct {
+		name              string
+		err               error
+		failures          int
+		maxAttempts       int
+		retryTimeout      time.Duration
+		expectedCalls     int
+		expectedMilestone int
+		expectErr         bool
+	}{
+		{
+			name:              "Succeed after two server errors",
+			err:               github.NewServerError(),
+			failures:          2,
+			expectedCalls:     3,
+			expectedMilestone: 1,
+		},
+		{
+			name:          "Give up after the configured attempts",
+			err:           github.NewServerError(),
+			failures:      2,
+			maxAttempts:   2,
+			expectedCalls: 2,
+			expectErr:     true,
+		},
+		{
+			name:          "Give up when the retries would take too long",
+			err:           github.NewServerError(),
+			failures:      2,
+			maxAttempts:   10,
+			retryTimeout:  time.Millisecond,
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+		{
+			name:          "Don't retry a client error",
+			err:           github.NewNotFound(),
+			failures:      1,
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.retryTimeout != 0 {
+				defer func(previous time.Duration) { retryTimeout = previous }(retryTimeout)
+				retryTimeout = tc.retryTimeout
+			}
+			fakeClient := &failingClient{
+				settingClient: &settingClient{FakeClient: fakegithub.NewFakeClient(), err: tc.err, failures: tc.failures},
+				err:           tc.err,
+				failures:      tc.failures,
+			}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/milestone v1.0",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", MaxAttempts: tc.maxAttempts}}
+
+			err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expected an error: %t, got %v.", tc.expectErr, err)
+			}
+			if fakeClient.listCalls != tc.expectedCalls {
+				t.Errorf("Expected the milestones to be listed %d times, got %d.", tc.expectedCalls, fakeClient.listCalls)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			if !tc.expectErr && fakeClient.calls != tc.expectedCalls {
+				t.Errorf("Expected the milestone to be set %d times, got %d.", tc.expectedCalls, fakeClient.calls)
+			}
+		})
+	}
+}
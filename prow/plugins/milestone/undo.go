@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// undoTTL is how long the last change of the milestone of an issue or PR can
+// be undone for.
+const undoTTL = 24 * time.Hour
+
+var milestoneUndos = newUndoStore(time.Now)
+
+// undoStore remembers the milestone which the last change of the milestone of
+// each issue or PR replaced, keyed by org/repo#number, so that the change can
+// be undone with `/milestone undo` within the undoTTL. An empty title means no
+// milestone.
+type undoStore struct {
+	lock     sync.Mutex
+	now      func() time.Time
+	previous map[string]undoEntry
+}
+
+type undoEntry struct {
+	previous string
+	expiry   time.Time
+}
+
+func newUndoStore(now func() time.Time) *undoStore {
+	return &undoStore{now: now, previous: map[string]undoEntry{}}
+}
+
+// record records that the milestone of the issue or PR replaced previous.
+func (s *undoStore) record(key, previous string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	for k, entry := range s.previous {
+		// forget the changes which can no longer be undone
+		if !now.Before(entry.expiry) {
+			delete(s.previous, k)
+		}
+	}
+	s.previous[key] = undoEntry{previous: previous, expiry: now.Add(undoTTL)}
+}
+
+// get returns the milestone which the last change replaced, and whether a
+// change which can still be undone was recorded.
+func (s *undoStore) get(key string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.previous[key]
+	if !ok {
+		return "", false
+	}
+	if !s.now().Before(entry.expiry) {
+		delete(s.previous, key)
+		return "", false
+	}
+	return entry.previous, true
+}
+
+// forget forgets the last change, once undone.
+func (s *undoStore) forget(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.previous, key)
+}
+
+// undoMilestone restores the milestone which the last change of the milestone
+// of the issue or PR replaced. Only the last change can be undone, and undoing
+// it can't be undone in turn. The cooldown doesn't apply, as undoing is meant
+// to revert a mistake right away.
+func undoMilestone(ctx context.Context, gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent, milestone plugins.Milestone) error {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+	key := issueTarget(org, repo, e.Number)
+	previous, ok := milestoneUndos.get(key)
+	if !ok {
+		countCommand(org, repo, outcomeInvalid)
+		return respond(ctx, gc, e, milestone, nothingToUndo, false)
+	}
+	restored := noMilestone
+	if previous != "" {
+		restored = fmt.Sprintf("`%s`", previous)
+	}
+	if milestone.IsDryRun() {
+		msg := fmt.Sprintf(wouldUndoMilestone, restored)
+		return gc.CreateComment(ctx, org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, e.User.Login, msg))
+	}
+
+	outcome := outcomeCleared
+	if previous == "" {
+		if err := gc.ClearMilestone(ctx, org, repo, e.Number); err != nil {
+			log.WithError(err).Errorf("Error clearing the milestone for %s/%s#%d.", org, repo, e.Number)
+			return err
+		}
+	} else {
+		// the previous milestone may have been closed since
+		milestones, err := gc.ListMilestonesByState(ctx, org, repo, github.MilestoneStateAll)
+		if err != nil {
+			log.WithError(err).Errorf("Error listing the milestones in the %s/%s repo", org, repo)
+			return handleRateLimit(ctx, gc, e, milestone, err)
+		}
+		number, found := ResolveMilestone(milestones, previous)
+		if !found {
+			countCommand(org, repo, outcomeInvalid)
+			return respond(ctx, gc, e, milestone, fmt.Sprintf(undoMilestoneGone, previous), false)
+		}
+		if err := gc.SetMilestone(ctx, org, repo, e.Number, number); err != nil {
+			log.WithError(err).Errorf("Error adding the milestone %s to %s/%s#%d.", previous, org, repo, e.Number)
+			return err
+		}
+		outcome = outcomeSet
+	}
+	milestoneUndos.forget(key)
+	changeCooldowns.record(key, milestone.ChangeCooldownDuration)
+	countCommand(org, repo, outcome)
+	if milestone.TracksViaLabel() {
+		trackMilestone(ctx, gc, log, org, repo, e.Number, previous)
+	}
+	if milestone.ConfirmsMilestone() || (milestone.UsesReactions() && e.CommentID != nil) {
+		return respond(ctx, gc, e, milestone, fmt.Sprintf(milestoneRestored, restored), true)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestone
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	utilpointer "k8s.io/utils/pointer"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// milestoneIssueClient keeps the milestone of the issue in sync with the
+// milestone set on the fake client, as GitHub does.
+type milestoneIssueClient struct {
+	*fakegithub.FakeClient
+}
+
+func (c *milestoneIssueClient) GetIssue(org, repo string, number int) (*github.Issue, error) {
+	issue := &github.Issue{Number: number}
+	for title, milestoneNumber := range c.MilestoneMap {
+		if milestoneNumber == c.Milestone {
+			issue.Milestone = github.Milestone{Title: title, Number: milestoneNumber}
+		}
+	}
+	return issue, nil
+}
+
+func TestUndoMilestone(t *testing.T) {
+	testcases := []struct {
+		name              string
+		initial           int
+		bodies            []string
+		confirm           bool
+		expectedMilestone int
+		expectedComment   string
+	}{
+		{
+			name:              "Undo setting the milestone",
+			initial:           1,
+			bodies:            []string{"/milestone v1.21", "/milestone undo"},
+			expectedMilestone: 1,
+		},
+		{
+			name:              "Undo setting the first milestone",
+			bodies:            []string{"/milestone v1.21", "/milestone undo"},
+			expectedMilestone: 0,
+		},
+		{
+			name:              "Undo clearing the milestone",
+			initial:           2,
+			bodies:            []string{"/milestone clear", "/milestone undo"},
+			expectedMilestone: 2,
+			expectedComment:   milestoneCleared,
+		},
+		{
+			name:              "Confirm the restored milestone",
+			initial:           1,
+			bodies:            []string{"/milestone v1.21", "/milestone undo"},
+			confirm:           true,
+			expectedMilestone: 1,
+			expectedComment:   fmt.Sprintf(milestoneRestored, "`v1.20`"),
+		},
+		{
+			name:              "Report that there is no change to undo",
+			initial:           1,
+			bodies:            []string{"/milestone undo"},
+			expectedMilestone: 1,
+			expectedComment:   nothingToUndo,
+		},
+		{
+			name:              "Only undo the last change once",
+			initial:           1,
+			bodies:            []string{"/milestone v1.21", "/milestone undo", "/milestone undo"},
+			expectedMilestone: 1,
+			expectedComment:   nothingToUndo,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(previous *undoStore) { milestoneUndos = previous }(milestoneUndos)
+			milestoneUndos = newUndoStore(time.Now)
+			fakeClient := &milestoneIssueClient{FakeClient: fakegithub.NewFakeClient()}
+			fakeClient.MilestoneMap = map[string]int{"v1.20": 1, "v1.21": 2}
+			fakeClient.Milestone = tc.initial
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", ConfirmMilestone: utilpointer.BoolPtr(tc.confirm)}}
+			for _, body := range tc.bodies {
+				e := &github.GenericCommentEvent{
+					Action: github.GenericCommentActionCreated,
+					Body:   body,
+					Number: 1,
+					Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+					User:   github.User{Login: "sig-lead"},
+				}
+				if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+					t.Fatalf("Unexpected error from handle: %v.", err)
+				}
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("Expected milestone %d, got %d.", tc.expectedMilestone, fakeClient.Milestone)
+			}
+			comments := fakeClient.IssueComments[1]
+			if tc.expectedComment == "" {
+				if len(comments) != 0 {
+					t.Errorf("Expected no comments, got %v.", comments)
+				}
+				return
+			}
+			if len(comments) == 0 || !strings.Contains(comments[len(comments)-1].Body, tc.expectedComment) {
+				t.Errorf("Expected the last comment to contain %q, got %v.", tc.expectedComment, comments)
+			}
+		})
+	}
+}
+
+func TestUndoExpired(t *testing.T) {
+	now := time.Now()
+	defer func(previous *undoStore) { milestoneUndos = previous }(milestoneUndos)
+	milestoneUndos = newUndoStore(func() time.Time { return now })
+	fakeClient := &milestoneIssueClient{FakeClient: fakegithub.NewFakeClient()}
+	fakeClient.MilestoneMap = map[string]int{"v1.20": 1, "v1.21": 2}
+	fakeClient.Milestone = 1
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+	for _, body := range []string{"/milestone v1.21", "/milestone undo"} {
+		e := &github.GenericCommentEvent{
+			Action: github.GenericCommentActionCreated,
+			Body:   body,
+			Number: 1,
+			Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			User:   github.User{Login: "sig-lead"},
+		}
+		if err := handle(context.Background(), &contextClient{client: fakeClient}, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+			t.Fatalf("Unexpected error from handle: %v.", err)
+		}
+		now = now.Add(undoTTL)
+	}
+	if fakeClient.Milestone != 2 {
+		t.Errorf("Expected the expired change to be kept, got milestone %d.", fakeClient.Milestone)
+	}
+	comments := fakeClient.IssueComments[1]
+	if len(comments) == 0 || !strings.Contains(comments[len(comments)-1].Body, nothingToUndo) {
+		t.Errorf("Expected the last comment to contain %q, got %v.", nothingToUndo, comments)
+	}
+	if _, ok := milestoneUndos.previous[issueTarget("org", "repo", 1)]; ok {
+		t.Error("Expected the expired change to be removed.")
+	}
+}
+
+func TestUndoStorePruning(t *testing.T) {
+	now := time.Now()
+	store := newUndoStore(func() time.Time { return now })
+	store.record("org/repo#1", "v1.20")
+	now = now.Add(undoTTL)
+	store.record("org/repo#2", "v1.21")
+	if _, ok := store.previous["org/repo#1"]; ok {
+		t.Error("Expected the expired change to be pruned.")
+	}
+	if previous, ok := store.get("org/repo#2"); !ok || previous != "v1.21" {
+		t.Errorf("Expected the fresh change to be kept, got %q, %t.", previous, ok)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Actions recorded by the milestone plugins in audit-only mode.
+const (
+	AuditSetMilestone    = "set_milestone"
+	AuditClearMilestone  = "clear_milestone"
+	AuditCreateMilestone = "create_milestone"
+	AuditAddLabel        = "add_label"
+	AuditRemoveLabel     = "remove_label"
+	AuditComment         = "comment"
+	AuditReaction        = "reaction"
+	AuditNotify          = "notify"
+)
+
+// AuditRecord is an action which a milestone plugin would have taken, had it
+// not been configured to be AuditOnly.
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Plugin string    `json:"plugin"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	// Target is the issue or PR, e.g. `org/repo#1`, or the repo acted upon.
+	Target string `json:"target"`
+	// Value is the argument of the action, e.g. the milestone or the label.
+	Value string `json:"value,omitempty"`
+}
+
+// auditLog writes the audit records as JSON lines to the audit sink.
+var auditLog = &auditWriter{sink: os.Stdout}
+
+type auditWriter struct {
+	lock sync.Mutex
+	sink io.Writer
+}
+
+// SetAuditSink makes the milestone plugins write their audit records to sink
+// and returns the previous sink. The records are written to stdout by default.
+func SetAuditSink(sink io.Writer) io.Writer {
+	auditLog.lock.Lock()
+	defer auditLog.lock.Unlock()
+	previous := auditLog.sink
+	auditLog.sink = sink
+	return previous
+}
+
+// Audit writes the record to the audit sink as a JSON line. Failures are
+// only logged, as the audit records are informational.
+func Audit(record AuditRecord) {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		logrus.WithError(err).Error("Error marshalling the audit record.")
+		return
+	}
+	auditLog.lock.Lock()
+	defer auditLog.lock.Unlock()
+	if _, err := auditLog.sink.Write(append(line, '\n')); err != nil {
+		logrus.WithError(err).Error("Error writing the audit record.")
+	}
+}
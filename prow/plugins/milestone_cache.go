@@ -0,0 +1,301 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+// defaultTeamMembershipCacheTTL is used when no team_membership_cache_ttl is configured.
+const defaultTeamMembershipCacheTTL = 5 * time.Minute
+
+// Values of the cache label of teamLookupDuration.
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+var teamLookupDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "milestone_team_lookup_duration_seconds",
+	Help: "Duration of the lookups of the milestone maintainers teams by the milestone plugins, by whether they were served from the cache.",
+}, []string{"cache"})
+
+func init() {
+	prometheus.MustRegister(teamLookupDuration)
+}
+
+// defaultTeamMembershipResolver is used by the agents which weren't given a
+// TeamMembershipResolver, e.g. in tests.
+var defaultTeamMembershipResolver = NewTeamMembershipResolver()
+
+// teamIDSlugs holds the slugs of the teams configured by their deprecated IDs.
+var teamIDSlugs = newTeamIDSlugCache()
+
+// teamIDSlugCache holds the slugs of teams, keyed by org and team ID. The ID of
+// a team never changes, so each team ID only needs to be resolved once.
+type teamIDSlugCache struct {
+	lock  sync.Mutex
+	slugs map[string]string
+	// lookups deduplicates the concurrent lookups of a team, which are made
+	// without holding the lock so that they don't block the other teams.
+	lookups singleflight.Group
+}
+
+func newTeamIDSlugCache() *teamIDSlugCache {
+	return &teamIDSlugCache{slugs: map[string]string{}}
+}
+
+// resolve returns the slug of the team with the given ID in org.
+func (c *teamIDSlugCache) resolve(gc MilestoneMaintainersClient, org string, id int) (string, error) {
+	key := fmt.Sprintf("%s/%d", org, id)
+	c.lock.Lock()
+	slug, ok := c.slugs[key]
+	c.lock.Unlock()
+	if ok {
+		return slug, nil
+	}
+	resolved, err, _ := c.lookups.Do(key, func() (interface{}, error) {
+		logrus.WithFields(logrus.Fields{"org": org, "team-id": id}).Warn("deprecated field: maintainers_id is configured for repo_milestone, maintainers_team should be used instead")
+		teams, err := gc.ListTeams(org)
+		if err != nil {
+			return "", fmt.Errorf("failed to list the teams of the %s org: %w", org, err)
+		}
+		for _, team := range teams {
+			if team.ID == id {
+				c.lock.Lock()
+				c.slugs[key] = team.Slug
+				c.lock.Unlock()
+				return team.Slug, nil
+			}
+		}
+		return "", fmt.Errorf("the %s org has no team with ID %d", org, id)
+	})
+	if err != nil {
+		return "", err
+	}
+	return resolved.(string), nil
+}
+
+// ResolveMaintainersIDs resolves the deprecated team IDs configured in
+// milestones to the slugs of the teams, so that an ID which doesn't exist is
+// reported when the config is loaded rather than when a command is handled.
+// The IDs only configured in the default config are resolved lazily, as the
+// orgs they apply to aren't known.
+func ResolveMaintainersIDs(gc MilestoneMaintainersClient, milestones map[string]Milestone) error {
+	return teamIDSlugs.resolveAll(gc, milestones)
+}
+
+func (c *teamIDSlugCache) resolveAll(gc MilestoneMaintainersClient, milestones map[string]Milestone) error {
+	repos := make([]string, 0, len(milestones))
+	for repo := range milestones {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	var errs []error
+	for _, repo := range repos {
+		if repo == "" {
+			continue
+		}
+		org, name, ok := strings.Cut(repo, "/")
+		milestone := milestones[repo].MergeOver(milestones[""])
+		if ok {
+			milestone = MilestoneFor(milestones, org, name)
+		}
+		if milestone.MaintainersID == 0 {
+			continue
+		}
+		if _, err := c.resolve(gc, org, milestone.MaintainersID); err != nil {
+			errs = append(errs, fmt.Errorf("maintainers_id of repo_milestone of %q: %w", repo, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// teamMembershipCache holds the members of teams, keyed by org and team, and
+// the results of checking whether a user is a member of a team.
+type teamMembershipCache struct {
+	lock        sync.Mutex
+	now         func() time.Time
+	members     map[string]teamMembersCacheEntry
+	memberships map[string]membershipCacheEntry
+}
+
+type teamMembersCacheEntry struct {
+	members []github.TeamMember
+	expiry  time.Time
+}
+
+type membershipCacheEntry struct {
+	member bool
+	expiry time.Time
+}
+
+func newTeamMembershipCache(now func() time.Time) *teamMembershipCache {
+	return &teamMembershipCache{
+		now:         now,
+		members:     map[string]teamMembersCacheEntry{},
+		memberships: map[string]membershipCacheEntry{},
+	}
+}
+
+func (c *teamMembershipCache) getMembers(key string) ([]github.TeamMember, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.members[key]
+	if !ok || !c.now().Before(entry.expiry) {
+		return nil, false
+	}
+	return entry.members, true
+}
+
+func (c *teamMembershipCache) setMembers(key string, members []github.TeamMember, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	for k, entry := range c.members {
+		// forget the members which expired
+		if !now.Before(entry.expiry) {
+			delete(c.members, k)
+		}
+	}
+	c.members[key] = teamMembersCacheEntry{members: members, expiry: now.Add(ttl)}
+}
+
+func (c *teamMembershipCache) getMembership(key string) (bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.memberships[key]
+	if !ok || !c.now().Before(entry.expiry) {
+		return false, false
+	}
+	return entry.member, true
+}
+
+func (c *teamMembershipCache) setMembership(key string, member bool, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	for k, entry := range c.memberships {
+		// forget the memberships which expired, as each commenter adds one
+		if !now.Before(entry.expiry) {
+			delete(c.memberships, k)
+		}
+	}
+	c.memberships[key] = membershipCacheEntry{member: member, expiry: now.Add(ttl)}
+}
+
+// TeamMembershipCachingClient serves the team membership lookups needed to
+// determine the milestone maintainers from a cache while the cached results
+// are younger than the ttl. The duration of each lookup is observed by the
+// milestone_team_lookup_duration_seconds histogram.
+type TeamMembershipCachingClient struct {
+	client MilestoneMaintainersClient
+	cache  *teamMembershipCache
+	ttl    time.Duration
+}
+
+// TeamMembershipResolver resolves the members of the milestone maintainers
+// teams from a single cache, so that the members looked up by a command of one
+// milestone plugin are reused by the following commands of both plugins. It is
+// constructed once and injected into the plugins through the Agent.
+type TeamMembershipResolver struct {
+	cache *teamMembershipCache
+}
+
+// NewTeamMembershipResolver returns a TeamMembershipResolver with an empty cache.
+func NewTeamMembershipResolver() *TeamMembershipResolver {
+	return &TeamMembershipResolver{cache: newTeamMembershipCache(time.Now)}
+}
+
+// Client returns a TeamMembershipCachingClient for gc using the cache of the
+// resolver, or of the default resolver if r is nil. A ttl of 0 means the
+// default of 5m.
+func (r *TeamMembershipResolver) Client(gc MilestoneMaintainersClient, ttl time.Duration) *TeamMembershipCachingClient {
+	if r == nil {
+		r = defaultTeamMembershipResolver
+	}
+	if ttl == 0 {
+		ttl = defaultTeamMembershipCacheTTL
+	}
+	return &TeamMembershipCachingClient{client: gc, cache: r.cache, ttl: ttl}
+}
+
+func (c *TeamMembershipCachingClient) ListTeams(org string) ([]github.Team, error) {
+	return c.client.ListTeams(org)
+}
+
+func (c *TeamMembershipCachingClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	start := time.Now()
+	key := fmt.Sprintf("%s/%s/%s", org, teamSlug, role)
+	if members, ok := c.cache.getMembers(key); ok {
+		teamLookupDuration.WithLabelValues(cacheHit).Observe(time.Since(start).Seconds())
+		return members, nil
+	}
+	defer func() { teamLookupDuration.WithLabelValues(cacheMiss).Observe(time.Since(start).Seconds()) }()
+	members, err := c.client.ListTeamMembersBySlug(org, teamSlug, role)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.setMembers(key, members, c.ttl)
+	return members, nil
+}
+
+func (c *TeamMembershipCachingClient) IsMember(org, user string) (bool, error) {
+	return c.client.IsMember(org, user)
+}
+
+// TeamBySlugHasMember checks the membership directly if the wrapped client
+// supports it, and otherwise looks for the user in the members of the team.
+func (c *TeamMembershipCachingClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	checker, ok := c.client.(TeamMembershipClient)
+	if !ok {
+		members, err := c.ListTeamMembersBySlug(org, teamSlug, github.RoleAll)
+		if err != nil {
+			return false, err
+		}
+		for _, member := range members {
+			if github.NormLogin(member.Login) == github.NormLogin(memberLogin) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	start := time.Now()
+	key := fmt.Sprintf("%s/%s/%s", org, teamSlug, github.NormLogin(memberLogin))
+	if member, ok := c.cache.getMembership(key); ok {
+		teamLookupDuration.WithLabelValues(cacheHit).Observe(time.Since(start).Seconds())
+		return member, nil
+	}
+	defer func() { teamLookupDuration.WithLabelValues(cacheMiss).Observe(time.Since(start).Seconds()) }()
+	member, err := checker.TeamBySlugHasMember(org, teamSlug, memberLogin)
+	if err != nil {
+		return false, err
+	}
+	c.cache.setMembership(key, member, c.ttl)
+	return member, nil
+}
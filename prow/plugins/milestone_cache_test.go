@@ -0,0 +1,244 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+// countingListingClient counts the calls listing the members of a team.
+type countingListingClient struct {
+	listingClient
+	calls int
+}
+
+func (c *countingListingClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	c.calls++
+	return c.listingClient.ListTeamMembersBySlug(org, teamSlug, role)
+}
+
+// countingMembershipClient counts the calls checking the membership of a team.
+type countingMembershipClient struct {
+	*fakegithub.FakeClient
+	calls int
+}
+
+func (c *countingMembershipClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	c.calls++
+	return c.FakeClient.TeamBySlugHasMember(org, teamSlug, memberLogin)
+}
+
+func TestTeamMembershipCachingClient(t *testing.T) {
+	testcases := []struct {
+		name          string
+		elapsed       time.Duration
+		expectedCalls int
+	}{
+		{
+			name:          "second check within the ttl is served from the cache",
+			elapsed:       time.Minute,
+			expectedCalls: 1,
+		},
+		{
+			name:          "second check after the ttl calls the client again",
+			elapsed:       10 * time.Minute,
+			expectedCalls: 2,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			listing := &countingListingClient{listingClient: listingClient{fake: fakegithub.NewFakeClient()}}
+			membership := &countingMembershipClient{FakeClient: fakegithub.NewFakeClient()}
+			clients := map[string]MilestoneMaintainersClient{"listing": listing, "membership": membership}
+			for name, client := range clients {
+				now := time.Now()
+				gc := &TeamMembershipCachingClient{
+					client: client,
+					cache:  newTeamMembershipCache(func() time.Time { return now }),
+					ttl:    defaultTeamMembershipCacheTTL,
+				}
+				for i := 0; i < 2; i++ {
+					found, err := IsMilestoneMaintainer(gc, Milestone{MaintainersTeam: "leads"}, "org", "sig-lead")
+					if err != nil {
+						t.Fatalf("%s: unexpected error: %v", name, err)
+					}
+					if !found {
+						t.Errorf("%s: expected sig-lead to be a milestone maintainer", name)
+					}
+					now = now.Add(tc.elapsed)
+				}
+			}
+			if listing.calls != tc.expectedCalls {
+				t.Errorf("expected ListTeamMembersBySlug to be called %d times, got %d", tc.expectedCalls, listing.calls)
+			}
+			if membership.calls != tc.expectedCalls {
+				t.Errorf("expected TeamBySlugHasMember to be called %d times, got %d", tc.expectedCalls, membership.calls)
+			}
+		})
+	}
+}
+
+func TestTeamMembershipResolver(t *testing.T) {
+	shared := NewTeamMembershipResolver()
+	testcases := []struct {
+		name          string
+		first, second *TeamMembershipResolver
+		expectedCalls int
+	}{
+		{
+			name:          "clients of the same resolver share its cache",
+			first:         shared,
+			second:        shared,
+			expectedCalls: 0,
+		},
+		{
+			name:          "clients of different resolvers don't share a cache",
+			first:         NewTeamMembershipResolver(),
+			second:        NewTeamMembershipResolver(),
+			expectedCalls: 1,
+		},
+		{
+			name:          "clients of no resolver share the cache of the default resolver",
+			expectedCalls: 0,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// each plugin has its own GitHub client
+			first := &countingMembershipClient{FakeClient: fakegithub.NewFakeClient()}
+			second := &countingMembershipClient{FakeClient: fakegithub.NewFakeClient()}
+			team := Milestone{MaintainersTeam: "leads"}
+			if _, err := IsMilestoneMaintainer(tc.first.Client(first, 0), team, "org", tc.name); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := IsMilestoneMaintainer(tc.second.Client(second, 0), team, "org", tc.name); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if first.calls != 1 {
+				t.Errorf("expected the first client to be called once, got %d", first.calls)
+			}
+			if second.calls != tc.expectedCalls {
+				t.Errorf("expected the second client to be called %d times, got %d", tc.expectedCalls, second.calls)
+			}
+		})
+	}
+}
+
+// lookupCount returns how many lookups teamLookupDuration observed with the cache label.
+func lookupCount(t *testing.T, cache string) uint64 {
+	var metric dto.Metric
+	if err := teamLookupDuration.WithLabelValues(cache).(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatalf("failed to read the histogram: %v", err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestTeamLookupDuration(t *testing.T) {
+	clients := map[string]MilestoneMaintainersClient{
+		"listing":    &listingClient{fake: fakegithub.NewFakeClient()},
+		"membership": fakegithub.NewFakeClient(),
+	}
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			gc := NewTeamMembershipResolver().Client(client, 0)
+			hits, misses := lookupCount(t, cacheHit), lookupCount(t, cacheMiss)
+			for i := 0; i < 2; i++ {
+				if _, err := IsMilestoneMaintainer(gc, Milestone{MaintainersTeam: "leads"}, "org", "sig-lead"); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if actual := lookupCount(t, cacheMiss) - misses; actual != 1 {
+				t.Errorf("expected 1 lookup missing the cache to be observed, got %d", actual)
+			}
+			if actual := lookupCount(t, cacheHit) - hits; actual != 1 {
+				t.Errorf("expected 1 lookup served from the cache to be observed, got %d", actual)
+			}
+		})
+	}
+}
+
+func TestTeamMembershipCachePruning(t *testing.T) {
+	now := time.Now()
+	cache := newTeamMembershipCache(func() time.Time { return now })
+	cache.setMembership("org/leads/first", true, time.Minute)
+	cache.setMembers("org/leads/all", nil, time.Minute)
+	now = now.Add(2 * time.Minute)
+	cache.setMembership("org/leads/second", true, time.Minute)
+	cache.setMembers("org/admins/all", nil, time.Minute)
+
+	if _, ok := cache.memberships["org/leads/first"]; ok {
+		t.Error("expected the expired membership to be pruned")
+	}
+	if _, ok := cache.members["org/leads/all"]; ok {
+		t.Error("expected the expired members to be pruned")
+	}
+	if len(cache.memberships) != 1 || len(cache.members) != 1 {
+		t.Errorf("expected only the fresh entries to be kept, got %d memberships and %d members", len(cache.memberships), len(cache.members))
+	}
+}
+
+// blockingTeamsClient blocks listing the teams until it is released.
+type blockingTeamsClient struct {
+	*fakegithub.FakeClient
+	release chan struct{}
+}
+
+func (c *blockingTeamsClient) ListTeams(org string) ([]github.Team, error) {
+	<-c.release
+	return c.FakeClient.ListTeams(org)
+}
+
+func TestTeamIDSlugCacheDoesNotBlock(t *testing.T) {
+	cache := newTeamIDSlugCache()
+	slow := &blockingTeamsClient{FakeClient: fakegithub.NewFakeClient(), release: make(chan struct{})}
+	done := make(chan error)
+	go func() {
+		_, err := cache.resolve(slow, "slow-org", 42)
+		done <- err
+	}()
+
+	resolved := make(chan string)
+	go func() {
+		slug, err := cache.resolve(fakegithub.NewFakeClient(), "org", 42)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		resolved <- slug
+	}()
+	select {
+	case slug := <-resolved:
+		if slug != "leads" {
+			t.Errorf("expected the slug leads, got %q", slug)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("resolving a team blocked behind the lookup of another org")
+	}
+
+	close(slow.release)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error resolving the slow team: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CommandStats counts the commands handled by a plugin by repo and outcome.
+// Unlike the prometheus metrics of the plugins, it is kept in memory so that it
+// can be dumped on demand from the debug handler of the plugin.
+type CommandStats struct {
+	lock sync.Mutex
+	// counts maps `org/repo` to the number of commands by outcome.
+	counts map[string]map[string]int
+}
+
+// NewCommandStats returns empty command statistics.
+func NewCommandStats() *CommandStats {
+	return &CommandStats{counts: map[string]map[string]int{}}
+}
+
+// Inc counts a command with the given outcome in org/repo.
+func (s *CommandStats) Inc(org, repo, outcome string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := org + "/" + repo
+	if s.counts[key] == nil {
+		s.counts[key] = map[string]int{}
+	}
+	s.counts[key][outcome]++
+}
+
+// Count returns the number of commands with the given outcome in org/repo.
+func (s *CommandStats) Count(org, repo, outcome string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.counts[org+"/"+repo][outcome]
+}
+
+// Snapshot returns a copy of the counts, keyed by `org/repo` and outcome.
+func (s *CommandStats) Snapshot() map[string]map[string]int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	snapshot := make(map[string]map[string]int, len(s.counts))
+	for repo, outcomes := range s.counts {
+		snapshot[repo] = make(map[string]int, len(outcomes))
+		for outcome, count := range outcomes {
+			snapshot[repo][outcome] = count
+		}
+	}
+	return snapshot
+}
+
+// ServeHTTP dumps the counts as JSON.
+func (s *CommandStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Snapshot()); err != nil {
+		logrus.WithError(err).Error("Failed to write the command statistics.")
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCommandStats(t *testing.T) {
+	stats := NewCommandStats()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats.Inc("org", "repo", "set")
+			stats.Inc("org", "repo", "invalid")
+			stats.Inc("org", "other", "set")
+		}()
+	}
+	wg.Wait()
+	stats.Inc("org", "repo", "unauthorized")
+
+	expected := map[string]map[string]int{
+		"org/repo":  {"set": 10, "invalid": 10, "unauthorized": 1},
+		"org/other": {"set": 10},
+	}
+	snapshot := stats.Snapshot()
+	if !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("expected the counts %v, got %v", expected, snapshot)
+	}
+	if count := stats.Count("org", "repo", "set"); count != 10 {
+		t.Errorf("expected 10 set commands, got %d", count)
+	}
+	if count := stats.Count("org", "missing", "set"); count != 0 {
+		t.Errorf("expected no commands in an unknown repo, got %d", count)
+	}
+
+	// the snapshot is a copy
+	snapshot["org/repo"]["set"] = 0
+	if count := stats.Count("org", "repo", "set"); count != 10 {
+		t.Errorf("expected the snapshot not to change the counts, got %d set commands", count)
+	}
+
+	recorder := httptest.NewRecorder()
+	stats.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/plugin-debug/milestone", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var served map[string]map[string]int
+	if err := json.Unmarshal(recorder.Body.Bytes(), &served); err != nil {
+		t.Fatalf("failed to unmarshal the served counts: %v", err)
+	}
+	if !reflect.DeepEqual(served, expected) {
+		t.Errorf("expected the served counts %v, got %v", expected, served)
+	}
+}
@@ -0,0 +1,621 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	utilpointer "k8s.io/utils/pointer"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+func TestIsMilestoneMaintainer(t *testing.T) {
+	testcases := []struct {
+		name      string
+		milestone Milestone
+		login     string
+		expected  bool
+	}{
+		{
+			name:      "member of the maintainers team",
+			milestone: Milestone{MaintainersTeam: "leads"},
+			login:     "sig-lead",
+			expected:  true,
+		},
+		{
+			name:      "member of the maintainers team with different casing",
+			milestone: Milestone{MaintainersTeam: "leads"},
+			login:     "SIG-Lead",
+			expected:  true,
+		},
+		{
+			name:      "member of the maintainers team with a leading @",
+			milestone: Milestone{MaintainersTeam: "leads"},
+			login:     "@sig-lead",
+			expected:  true,
+		},
+		{
+			name:      "not a member of the maintainers team",
+			milestone: Milestone{MaintainersTeam: "leads"},
+			login:     "sig-follow",
+		},
+		{
+			name:      "allowed bot",
+			milestone: Milestone{MaintainersTeam: "leads", AllowedBots: []string{"Release-Bot"}},
+			login:     "@release-bot",
+			expected:  true,
+		},
+		{
+			name:      "bot which isn't allowed",
+			milestone: Milestone{MaintainersTeam: "leads", AllowedBots: []string{"release-bot"}},
+			login:     "other-bot",
+		},
+		{
+			name:      "member of the second of two maintainers teams",
+			milestone: Milestone{MaintainersTeams: []string{"admins", "leads"}},
+			login:     "sig-lead",
+			expected:  true,
+		},
+		{
+			name:      "member of a nested maintainers team",
+			milestone: Milestone{MaintainersTeam: "admins/leads"},
+			login:     "sig-lead",
+			expected:  true,
+		},
+		{
+			name:      "member of the parent of a nested maintainers team",
+			milestone: Milestone{MaintainersTeam: "admins/leads"},
+			login:     "default-sig-lead",
+		},
+		{
+			name:      "member of the maintainers team configured by ID",
+			milestone: Milestone{MaintainersID: 42},
+			login:     "sig-lead",
+			expected:  true,
+		},
+		{
+			name:      "org member when org members are allowed",
+			milestone: Milestone{AllowOrgMembers: utilpointer.BoolPtr(true)},
+			login:     "org-member",
+			expected:  true,
+		},
+		{
+			name:      "org member with different casing when org members are allowed",
+			milestone: Milestone{AllowOrgMembers: utilpointer.BoolPtr(true)},
+			login:     "Org-Member",
+			expected:  true,
+		},
+		{
+			name:      "non-member when org members are allowed",
+			milestone: Milestone{AllowOrgMembers: utilpointer.BoolPtr(true)},
+			login:     "sig-lead",
+		},
+		{
+			name:      "org member when org members are allowed but a team is configured",
+			milestone: Milestone{AllowOrgMembers: utilpointer.BoolPtr(true), MaintainersTeam: "leads"},
+			login:     "org-member",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.OrgMembers["org"] = []string{"org-member"}
+			actual, err := IsMilestoneMaintainer(fakeClient, tc.milestone, "org", tc.login)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// listingClient only supports determining the milestone maintainers by listing
+// the members of their teams.
+type listingClient struct {
+	fake *fakegithub.FakeClient
+}
+
+func (c *listingClient) ListTeams(org string) ([]github.Team, error) {
+	return c.fake.ListTeams(org)
+}
+
+func (c *listingClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	return c.fake.ListTeamMembersBySlug(org, teamSlug, role)
+}
+
+func (c *listingClient) IsMember(org, user string) (bool, error) {
+	return c.fake.IsMember(org, user)
+}
+
+// membershipClient fails when the members of a team are listed.
+type membershipClient struct {
+	*fakegithub.FakeClient
+}
+
+func (c *membershipClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	return nil, errors.New("unexpected ListTeamMembersBySlug call")
+}
+
+// rolesClient lists the members of the leads team by role: sig-lead is a
+// maintainer of the team and sig-member a regular member.
+type rolesClient struct {
+	*fakegithub.FakeClient
+}
+
+func (c *rolesClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	if teamSlug != "leads" {
+		return nil, nil
+	}
+	roles := map[string]string{"sig-lead": github.RoleMaintainer, "sig-member": github.RoleMember}
+	var listed []github.TeamMember
+	for _, login := range []string{"sig-lead", "sig-member"} {
+		if role == github.RoleAll || roles[login] == role {
+			listed = append(listed, github.TeamMember{Login: login})
+		}
+	}
+	return listed, nil
+}
+
+func (c *rolesClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	members, err := c.ListTeamMembersBySlug(org, teamSlug, github.RoleAll)
+	for _, member := range members {
+		if member.Login == memberLogin {
+			return true, err
+		}
+	}
+	return false, err
+}
+
+// notFoundClient fails to list the members of any team as GitHub does for a
+// team which doesn't exist.
+type notFoundClient struct {
+	listingClient
+}
+
+func (c *notFoundClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	return nil, github.NewNotFound()
+}
+
+func TestIsMilestoneMaintainerTeamNotFound(t *testing.T) {
+	testcases := []struct {
+		name   string
+		client MilestoneMaintainersClient
+	}{
+		{
+			name:   "membership check",
+			client: &membershipClient{fakegithub.NewFakeClient()},
+		},
+		{
+			name:   "listing",
+			client: &notFoundClient{listingClient{fakegithub.NewFakeClient()}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := IsMilestoneMaintainer(tc.client, Milestone{MaintainersTeam: "missing"}, "org", "sig-lead")
+			var notFound TeamNotFoundError
+			if !errors.As(err, &notFound) {
+				t.Fatalf("expected a TeamNotFoundError, got %v", err)
+			}
+			if notFound.Org != "org" || notFound.Team != "missing" {
+				t.Errorf("expected the missing team org/missing, got %s/%s", notFound.Org, notFound.Team)
+			}
+		})
+	}
+}
+
+// renamedTeamClient fails to list the members of the old-leads team, which was
+// renamed to leads.
+type renamedTeamClient struct {
+	listingClient
+}
+
+func (c *renamedTeamClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	if teamSlug == "old-leads" {
+		return nil, github.NewNotFound()
+	}
+	return c.listingClient.ListTeamMembersBySlug(org, teamSlug, role)
+}
+
+func TestRenamedTeamFallback(t *testing.T) {
+	testcases := []struct {
+		name             string
+		client           MilestoneMaintainersClient
+		milestone        Milestone
+		expected         bool
+		expectedLogins   []string
+		expectedNotFound bool
+	}{
+		{
+			name:           "membership check falls back to the team ID",
+			client:         &membershipClient{fakegithub.NewFakeClient()},
+			milestone:      Milestone{MaintainersTeam: "old-leads", MaintainersID: 42},
+			expected:       true,
+			expectedLogins: []string{"sig-lead"},
+		},
+		{
+			name:           "listing falls back to the team ID",
+			client:         &renamedTeamClient{listingClient{fakegithub.NewFakeClient()}},
+			milestone:      Milestone{MaintainersTeam: "old-leads", MaintainersID: 42},
+			expected:       true,
+			expectedLogins: []string{"sig-lead"},
+		},
+		{
+			name:             "a missing team without an ID is reported",
+			client:           &renamedTeamClient{listingClient{fakegithub.NewFakeClient()}},
+			milestone:        Milestone{MaintainersTeam: "old-leads"},
+			expectedNotFound: true,
+		},
+		{
+			name:             "a missing team whose ID isn't found either is reported",
+			client:           &renamedTeamClient{listingClient{fakegithub.NewFakeClient()}},
+			milestone:        Milestone{MaintainersTeam: "old-leads", MaintainersID: 7},
+			expectedNotFound: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var notFound TeamNotFoundError
+			found, err := IsMilestoneMaintainer(tc.client, tc.milestone, "org", "sig-lead")
+			if tc.expectedNotFound {
+				if !errors.As(err, &notFound) {
+					t.Errorf("expected a TeamNotFoundError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if found != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, found)
+			}
+			if _, ok := tc.client.(TeamMembershipClient); ok {
+				// the membership check doesn't list the members
+				return
+			}
+			logins, err := MilestoneMaintainerLogins(tc.client, tc.milestone, "org")
+			if err != nil {
+				t.Fatalf("unexpected error listing the maintainers: %v", err)
+			}
+			if !reflect.DeepEqual(logins, tc.expectedLogins) {
+				t.Errorf("expected the maintainers %v, got %v", tc.expectedLogins, logins)
+			}
+		})
+	}
+}
+
+func TestTeamAuthorizer(t *testing.T) {
+	authorizer := &TeamAuthorizer{Client: fakegithub.NewFakeClient()}
+	milestone := Milestone{MaintainersTeam: "leads"}
+	for login, expected := range map[string]bool{"sig-lead": true, "sig-follow": false} {
+		authorized, err := authorizer.IsAuthorized("org", login, milestone)
+		if err != nil {
+			t.Fatalf("unexpected error authorizing %s: %v", login, err)
+		}
+		if authorized != expected {
+			t.Errorf("expected %s to be authorized: %t, got %t", login, expected, authorized)
+		}
+	}
+}
+
+// emptyTeamClient lists no members for the leads team.
+type emptyTeamClient struct {
+	listingClient
+}
+
+func (c *emptyTeamClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	if teamSlug == "leads" {
+		return []github.TeamMember{}, nil
+	}
+	return c.listingClient.ListTeamMembersBySlug(org, teamSlug, role)
+}
+
+// forbiddenClient is forbidden to look up the members of the org and of its
+// teams, as a GitHub App without the members:read permission.
+type forbiddenClient struct {
+	*fakegithub.FakeClient
+}
+
+func (c *forbiddenClient) IsMember(org, user string) (bool, error) {
+	return false, github.NewForbidden()
+}
+
+func (c *forbiddenClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	return false, github.NewForbidden()
+}
+
+func TestIsMilestoneMaintainerForbidden(t *testing.T) {
+	testcases := []struct {
+		name      string
+		milestone Milestone
+	}{
+		{
+			name:      "team membership",
+			milestone: Milestone{MaintainersTeam: "leads"},
+		},
+		{
+			name:      "org membership",
+			milestone: Milestone{AllowOrgMembers: utilpointer.BoolPtr(true)},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := IsMilestoneMaintainer(&forbiddenClient{fakegithub.NewFakeClient()}, tc.milestone, "org", "sig-lead")
+			var missing MissingPermissionError
+			if !errors.As(err, &missing) {
+				t.Fatalf("expected a MissingPermissionError, got %v", err)
+			}
+			if missing.Org != "org" {
+				t.Errorf("expected the org to be org, got %s", missing.Org)
+			}
+			if !strings.Contains(err.Error(), "members:read") {
+				t.Errorf("expected the error to name the missing permission, got %q", err.Error())
+			}
+		})
+	}
+}
+
+func TestHasNoMaintainers(t *testing.T) {
+	testcases := []struct {
+		name      string
+		milestone Milestone
+		expected  bool
+	}{
+		{
+			name:      "the only team is empty",
+			milestone: Milestone{MaintainersTeam: "leads"},
+			expected:  true,
+		},
+		{
+			name:      "the team configured by ID is empty",
+			milestone: Milestone{MaintainersID: 42},
+			expected:  true,
+		},
+		{
+			name:      "another team has members",
+			milestone: Milestone{MaintainersTeams: []string{"leads", "admins"}},
+		},
+		{
+			name:      "org members are authorized",
+			milestone: Milestone{AllowOrgMembers: utilpointer.BoolPtr(true)},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := HasNoMaintainers(&emptyTeamClient{listingClient{fakegithub.NewFakeClient()}}, tc.milestone, "org")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsMilestoneMaintainerClients(t *testing.T) {
+	testcases := []struct {
+		name      string
+		client    func() MilestoneMaintainersClient
+		milestone Milestone
+		login     string
+		expected  bool
+	}{
+		{
+			name:      "membership check finds a member",
+			client:    func() MilestoneMaintainersClient { return &membershipClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersTeams: []string{"admins", "leads"}},
+			login:     "SIG-Lead",
+			expected:  true,
+		},
+		{
+			name:      "membership check rejects a non-member",
+			client:    func() MilestoneMaintainersClient { return &membershipClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersTeam: "leads"},
+			login:     "sig-follow",
+		},
+		{
+			name:      "membership check finds a member of a team configured by ID",
+			client:    func() MilestoneMaintainersClient { return &membershipClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersID: 42},
+			login:     "sig-lead",
+			expected:  true,
+		},
+		{
+			name:      "listing finds a member",
+			client:    func() MilestoneMaintainersClient { return &listingClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersTeams: []string{"admins", "leads"}},
+			login:     "SIG-Lead",
+			expected:  true,
+		},
+		{
+			name:      "listing finds a member of a nested team",
+			client:    func() MilestoneMaintainersClient { return &listingClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersTeam: "admins/leads"},
+			login:     "sig-lead",
+			expected:  true,
+		},
+		{
+			name:      "listing finds a member of a team configured by ID",
+			client:    func() MilestoneMaintainersClient { return &listingClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersID: 42},
+			login:     "sig-lead",
+			expected:  true,
+		},
+		{
+			name:      "listing rejects a non-member",
+			client:    func() MilestoneMaintainersClient { return &listingClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersTeam: "leads"},
+			login:     "sig-follow",
+		},
+		{
+			name:      "regular team members are maintainers by default",
+			client:    func() MilestoneMaintainersClient { return &rolesClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersTeam: "leads"},
+			login:     "sig-member",
+			expected:  true,
+		},
+		{
+			name:      "team maintainers are maintainers if the role is required",
+			client:    func() MilestoneMaintainersClient { return &rolesClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersTeam: "leads", RequireTeamMaintainerRole: utilpointer.BoolPtr(true)},
+			login:     "sig-lead",
+			expected:  true,
+		},
+		{
+			name:      "regular team members are rejected if the role is required",
+			client:    func() MilestoneMaintainersClient { return &rolesClient{fakegithub.NewFakeClient()} },
+			milestone: Milestone{MaintainersTeam: "leads", RequireTeamMaintainerRole: utilpointer.BoolPtr(true)},
+			login:     "sig-member",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := IsMilestoneMaintainer(tc.client(), tc.milestone, "org", tc.login)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTeamSlug(t *testing.T) {
+	testcases := map[string]string{
+		"leads":                   "leads",
+		"admins/leads":            "leads",
+		"org-admins/admins/leads": "leads",
+	}
+	for team, expected := range testcases {
+		if actual := TeamSlug(team); actual != expected {
+			t.Errorf("expected the slug of %q to be %q, got %q", team, expected, actual)
+		}
+	}
+}
+
+func TestStripQuotedText(t *testing.T) {
+	testcases := map[string]string{
+		"/milestone v1.0":                            "/milestone v1.0",
+		"> /milestone v1.0\n/milestone v1.1":         "/milestone v1.1",
+		"  > > /milestone v1.0\nLGTM":                "LGTM",
+		"```\n/milestone v1.0\n```\n/milestone v1.1": "/milestone v1.1",
+		"~~~yaml\n/status in-progress\n~~~\nLGTM":    "LGTM",
+		"Example:\n```\n/milestone v1.0":             "Example:",
+		"Use `/milestone v1.0` for this.\nThanks":    "Use `/milestone v1.0` for this.\nThanks",
+		"a > b, so\n/milestone v1.0":                 "a > b, so\n/milestone v1.0",
+	}
+	for body, expected := range testcases {
+		if actual := StripQuotedText(body); actual != expected {
+			t.Errorf("expected %q to be stripped to %q, got %q", body, expected, actual)
+		}
+	}
+}
+
+// countingTeamsClient counts the calls listing the teams of an org.
+type countingTeamsClient struct {
+	*fakegithub.FakeClient
+	calls int
+}
+
+func (c *countingTeamsClient) ListTeams(org string) ([]github.Team, error) {
+	c.calls++
+	return c.FakeClient.ListTeams(org)
+}
+
+func TestTeamIDSlugCache(t *testing.T) {
+	testcases := []struct {
+		name        string
+		id          int
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name:     "team ID resolves to its slug",
+			id:       42,
+			expected: "leads",
+		},
+		{
+			name:        "unknown team ID",
+			id:          7,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			gc := &countingTeamsClient{FakeClient: fakegithub.NewFakeClient()}
+			cache := newTeamIDSlugCache()
+			for i := 0; i < 2; i++ {
+				actual, err := cache.resolve(gc, "org", tc.id)
+				if tc.expectedErr != (err != nil) {
+					t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+				}
+				if actual != tc.expected {
+					t.Errorf("expected slug %q, got %q", tc.expected, actual)
+				}
+			}
+			expectedCalls := 1
+			if tc.expectedErr {
+				expectedCalls = 2
+			}
+			if gc.calls != expectedCalls {
+				t.Errorf("expected ListTeams to be called %d times, got %d", expectedCalls, gc.calls)
+			}
+		})
+	}
+}
+
+func TestResolveMaintainersIDs(t *testing.T) {
+	milestones := map[string]Milestone{
+		"":          {MaintainersID: 7},
+		"org":       {MaintainersID: 42},
+		"org/repo":  {MaintainersTeam: "leads"},
+		"other/foo": {MaintainersID: 7},
+	}
+	gc := &countingTeamsClient{FakeClient: fakegithub.NewFakeClient()}
+	cache := newTeamIDSlugCache()
+	err := cache.resolveAll(gc, milestones)
+	if err == nil || !strings.Contains(err.Error(), `"other/foo"`) {
+		t.Errorf("expected the unknown team ID of other/foo to be reported, got: %v", err)
+	}
+	if strings.Contains(err.Error(), `"org"`) {
+		t.Errorf("expected the team ID of org to be resolved, got: %v", err)
+	}
+	calls := gc.calls
+	slug, err := cache.resolve(gc, "org", 42)
+	if err != nil || slug != "leads" {
+		t.Errorf("expected team ID 42 to resolve to leads, got %q, %v", slug, err)
+	}
+	if gc.calls != calls {
+		t.Errorf("expected team ID 42 to have been resolved when loading the config, got %d more ListTeams calls", gc.calls-calls)
+	}
+}
@@ -119,8 +119,7 @@ func handle(gc githubClient, log *logrus.Entry, configuredMilestone string, pre
 		return err
 	}
 
-	milestoneMap := milestone.BuildMilestoneMap(milestones)
-	configuredMilestoneNumber, ok := milestoneMap[configuredMilestone]
+	configuredMilestoneNumber, ok := milestone.ResolveMilestoneTitle(milestones, configuredMilestone)
 	if !ok {
 		return fmt.Errorf("The configured milestone %s for %s branch does not exist in the %s/%s repo", configuredMilestone, pr.Base.Ref, org, repo)
 	}
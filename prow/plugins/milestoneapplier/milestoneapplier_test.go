@@ -183,3 +183,68 @@ func TestMilestoneApplier(t *testing.T) {
 		})
 	}
 }
+
+func TestConfiguredMilestoneTitleOnly(t *testing.T) {
+	testcases := []struct {
+		name                string
+		milestones          map[string]int
+		configuredMilestone string
+		expectedMilestone   int
+		expectErr           bool
+	}{
+		{
+			name:                "exact title",
+			configuredMilestone: "v1.0",
+			expectedMilestone:   1,
+		},
+		{
+			name:                "title differing in case",
+			configuredMilestone: "V1.0",
+			expectedMilestone:   1,
+		},
+		{
+			name:                "number of the milestone is not a title",
+			configuredMilestone: "#1",
+			expectErr:           true,
+		},
+		{
+			name:                "exact title among titles differing in case",
+			milestones:          map[string]int{"v1.0": 1, "V1.0": 2},
+			configuredMilestone: "V1.0",
+			expectedMilestone:   2,
+		},
+		{
+			name:                "title matching several titles differing in case",
+			milestones:          map[string]int{"v1.0": 1, "V1.0": 2},
+			configuredMilestone: "v1.0 ",
+			expectErr:           true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := github.PullRequest{
+				Number: 1,
+				Base: github.PullRequestBranch{
+					Repo: github.Repo{Owner: github.User{Login: "kubernetes"}, Name: "kubernetes", DefaultBranch: "master"},
+					Ref:  "release-1.0",
+				},
+			}
+			event := github.PullRequestEvent{Action: github.PullRequestActionOpened, Number: pr.Number, PullRequest: pr}
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.PullRequests = map[int]*github.PullRequest{pr.Number: &pr}
+			fakeClient.MilestoneMap = map[string]int{"v1.0": 1}
+			if tc.milestones != nil {
+				fakeClient.MilestoneMap = tc.milestones
+			}
+
+			err := handle(fakeClient, logrus.WithField("plugin", pluginName), tc.configuredMilestone, event)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected an error: %t, got: %v", tc.expectErr, err)
+			}
+			if fakeClient.Milestone != tc.expectedMilestone {
+				t.Errorf("expected milestone %d, got %d", tc.expectedMilestone, fakeClient.Milestone)
+			}
+		})
+	}
+}
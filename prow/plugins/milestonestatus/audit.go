@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestonestatus
+
+import (
+	"fmt"
+
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// auditingClient records the mutating calls of the plugin in the audit sink
+// instead of making them, for the repos configured to be audit-only. The calls
+// reading from GitHub are made as usual.
+type auditingClient struct {
+	githubClient
+	actor string
+}
+
+func (c *auditingClient) audit(action string, org, repo string, number int, value string) {
+	target := fmt.Sprintf("%s/%s#%d", org, repo, number)
+	plugins.Audit(plugins.AuditRecord{Plugin: pluginName, Actor: c.actor, Action: action, Target: target, Value: value})
+}
+
+func (c *auditingClient) CreateComment(owner, repo string, number int, comment string) error {
+	c.audit(plugins.AuditComment, owner, repo, number, comment)
+	return nil
+}
+
+func (c *auditingClient) AddLabel(owner, repo string, number int, label string) error {
+	c.audit(plugins.AuditAddLabel, owner, repo, number, label)
+	return nil
+}
+
+func (c *auditingClient) RemoveLabel(owner, repo string, number int, label string) error {
+	c.audit(plugins.AuditRemoveLabel, owner, repo, number, label)
+	return nil
+}
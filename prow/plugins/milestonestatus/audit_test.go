@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestonestatus
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	utilpointer "k8s.io/utils/pointer"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestAuditOnly(t *testing.T) {
+	var sink bytes.Buffer
+	defer plugins.SetAuditSink(plugins.SetAuditSink(&sink))
+
+	fakeClient := fakegithub.NewFakeClient()
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/status approved-for-milestone",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", AuditOnly: utilpointer.BoolPtr(true)}}
+
+	if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		t.Fatalf("Unexpected error from handle: %v.", err)
+	}
+	if len(fakeClient.IssueLabelsAdded) != 0 {
+		t.Errorf("Expected no labels to be added, but got %q.", fakeClient.IssueLabelsAdded)
+	}
+
+	var record plugins.AuditRecord
+	if err := json.NewDecoder(&sink).Decode(&record); err != nil {
+		t.Fatalf("Error decoding the audit record: %v.", err)
+	}
+	if record.Actor != "sig-lead" || record.Action != plugins.AuditAddLabel || record.Target != "org/repo#1" || record.Value != "status/approved-for-milestone" {
+		t.Errorf("Unexpected audit record %+v.", record)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestonestatus
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// usersAuthorizer authorizes a fixed set of users, as e.g. an LDAP group would.
+type usersAuthorizer struct {
+	users map[string]bool
+}
+
+func (a *usersAuthorizer) IsAuthorized(org, user string, milestone plugins.Milestone) (bool, error) {
+	return a.users[user], nil
+}
+
+func TestCustomAuthorizer(t *testing.T) {
+	testcases := []struct {
+		name              string
+		commenter         string
+		expectedNewLabels []string
+		expectedComments  []string
+	}{
+		{
+			name:              "Apply the status for a user the authorizer allows",
+			commenter:         "ldap-user",
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name:             "Refuse a milestone maintainer the authorizer denies",
+			commenter:        "sig-lead",
+			expectedComments: []string{fmt.Sprintf(mustBeAuthorized, teamLinks("org", []string{"leads"}), plugins.Milestone{MaintainersTeam: "leads"}.FriendlyName())},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/status in-progress",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+			authorizer := &usersAuthorizer{users: map[string]bool{"ldap-user": true}}
+
+			if err := handleWithAuthorizer(fakeClient, authorizer, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+			var comments []string
+			for _, c := range fakeClient.IssueComments[1] {
+				comments = append(comments, c.Body)
+			}
+			if !reflect.DeepEqual(tc.expectedComments, comments) {
+				t.Errorf("Expected comments %q, got %q.", tc.expectedComments, comments)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestonestatus
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// statusLabelCheckTTL is how long the status labels of a repo are considered
+// checked, so that labels deleted later on are reported again.
+const statusLabelCheckTTL = time.Hour
+
+// statusLabelChecks remembers the repos whose status labels were checked, so
+// that each repo is only checked once per statusLabelCheckTTL.
+var statusLabelChecks = newStatusLabelChecker(time.Now)
+
+// statusLabelChecker checks that the labels of the statuses exist in a repo,
+// as adding a missing label creates it with a default color and description.
+type statusLabelChecker struct {
+	lock sync.Mutex
+	now  func() time.Time
+	// checked holds when the check of each repo expires
+	checked map[string]time.Time
+}
+
+func newStatusLabelChecker(now func() time.Time) *statusLabelChecker {
+	return &statusLabelChecker{now: now, checked: map[string]time.Time{}}
+}
+
+// begin records the repo as checked and returns true, unless its check hasn't
+// expired yet.
+func (c *statusLabelChecker) begin(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	for k, expiry := range c.checked {
+		// forget the checks which expired
+		if !now.Before(expiry) {
+			delete(c.checked, k)
+		}
+	}
+	if _, ok := c.checked[key]; ok {
+		return false
+	}
+	c.checked[key] = now.Add(statusLabelCheckTTL)
+	return true
+}
+
+// forget drops the check of the repo, so that it's checked again.
+func (c *statusLabelChecker) forget(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.checked, key)
+}
+
+// check warns about the labels of the statuses which don't exist in the repo
+// and returns them, unless the repo was checked recently. The labels are
+// listed without holding the lock, so that a slow repo doesn't hold up the
+// others. Failing to list the labels is only logged, and the repo is checked
+// again the next time.
+func (c *statusLabelChecker) check(gc githubClient, log *logrus.Entry, org, repo string, statuses map[string]string) []string {
+	key := fmt.Sprintf("%s/%s", org, repo)
+	if !c.begin(key) {
+		return nil
+	}
+	labels, err := gc.GetRepoLabels(org, repo)
+	if err != nil {
+		c.forget(key)
+		log.WithError(err).Warnf("Error listing the labels of %s to check the status labels.", key)
+		return nil
+	}
+	// label names are case-insensitive on GitHub
+	existing := sets.NewString()
+	for _, label := range labels {
+		existing.Insert(strings.ToLower(label.Name))
+	}
+	var missing []string
+	for _, sLabel := range managedLabels(statuses).List() {
+		if !existing.Has(strings.ToLower(sLabel)) {
+			missing = append(missing, sLabel)
+		}
+	}
+	if len(missing) > 0 {
+		log.WithField("missingLabels", missing).Warnf("The status labels configured for %s don't all exist in the repo.", key)
+	}
+	return missing
+}
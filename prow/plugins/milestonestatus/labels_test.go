@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package milestonestatus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+// countingLabelsClient counts the calls listing the labels of a repo.
+type countingLabelsClient struct {
+	*fakegithub.FakeClient
+	calls int
+	err   error
+}
+
+func (c *countingLabelsClient) GetRepoLabels(org, repo string) ([]github.Label, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.FakeClient.GetRepoLabels(org, repo)
+}
+
+func TestStatusLabelChecker(t *testing.T) {
+	statuses := map[string]string{"blocked": "status/blocked", "in-progress": "status/in-progress"}
+	testcases := []struct {
+		name            string
+		existing        []string
+		err             error
+		expectedMissing []string
+		expectedCalls   int
+	}{
+		{
+			name:          "All the status labels exist",
+			existing:      []string{"status/blocked", "status/in-progress", "kind/bug"},
+			expectedCalls: 1,
+		},
+		{
+			name:          "Labels are compared regardless of case",
+			existing:      []string{"Status/Blocked", "status/in-progress"},
+			expectedCalls: 1,
+		},
+		{
+			name:            "Missing status labels are reported",
+			existing:        []string{"status/blocked"},
+			expectedMissing: []string{"status/in-progress"},
+			expectedCalls:   1,
+		},
+		{
+			name:          "A repo whose labels can't be listed is checked again",
+			err:           errors.New("injected error"),
+			expectedCalls: 2,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := &countingLabelsClient{FakeClient: fakegithub.NewFakeClient(), err: tc.err}
+			fakeClient.RepoLabelsExisting = tc.existing
+			checker := newStatusLabelChecker(time.Now)
+			log := logrus.WithField("plugin", pluginName)
+
+			missing := checker.check(fakeClient, log, "org", "repo", statuses)
+			if !reflect.DeepEqual(tc.expectedMissing, missing) {
+				t.Errorf("Expected the missing labels %q, got %q.", tc.expectedMissing, missing)
+			}
+			if missing := checker.check(fakeClient, log, "org", "repo", statuses); missing != nil {
+				t.Errorf("Expected no missing labels once the repo was checked, got %q.", missing)
+			}
+			if fakeClient.calls != tc.expectedCalls {
+				t.Errorf("Expected the labels to be listed %d times, got %d.", tc.expectedCalls, fakeClient.calls)
+			}
+		})
+	}
+}
+
+func TestStatusLabelCheckExpiry(t *testing.T) {
+	statuses := map[string]string{"blocked": "status/blocked"}
+	now := time.Now()
+	checker := newStatusLabelChecker(func() time.Time { return now })
+	fakeClient := &countingLabelsClient{FakeClient: fakegithub.NewFakeClient()}
+	log := logrus.WithField("plugin", pluginName)
+
+	checker.check(fakeClient, log, "org", "repo", statuses)
+	now = now.Add(statusLabelCheckTTL)
+	if missing := checker.check(fakeClient, log, "org", "other", statuses); !reflect.DeepEqual([]string{"status/blocked"}, missing) {
+		t.Errorf("Expected the missing labels of another repo to be reported, got %q.", missing)
+	}
+	if _, ok := checker.checked["org/repo"]; ok {
+		t.Error("Expected the expired check to be pruned.")
+	}
+	if missing := checker.check(fakeClient, log, "org", "repo", statuses); !reflect.DeepEqual([]string{"status/blocked"}, missing) {
+		t.Errorf("Expected the missing labels to be reported again once the check expired, got %q.", missing)
+	}
+	if fakeClient.calls != 3 {
+		t.Errorf("Expected the labels to be listed 3 times, got %d.", fakeClient.calls)
+	}
+}
+
+// blockingLabelsClient blocks listing the labels until it is released.
+type blockingLabelsClient struct {
+	*fakegithub.FakeClient
+	release chan struct{}
+}
+
+func (c *blockingLabelsClient) GetRepoLabels(org, repo string) ([]github.Label, error) {
+	<-c.release
+	return c.FakeClient.GetRepoLabels(org, repo)
+}
+
+func TestStatusLabelCheckDoesNotBlock(t *testing.T) {
+	statuses := map[string]string{"blocked": "status/blocked"}
+	checker := newStatusLabelChecker(time.Now)
+	log := logrus.WithField("plugin", pluginName)
+	slow := &blockingLabelsClient{FakeClient: fakegithub.NewFakeClient(), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		checker.check(slow, log, "org", "slow", statuses)
+		close(done)
+	}()
+
+	checked := make(chan []string)
+	go func() {
+		checked <- checker.check(fakegithub.NewFakeClient(), log, "org", "repo", statuses)
+	}()
+	select {
+	case missing := <-checked:
+		if !reflect.DeepEqual([]string{"status/blocked"}, missing) {
+			t.Errorf("Expected the missing labels %q, got %q.", []string{"status/blocked"}, missing)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Checking a repo blocked behind the check of another repo.")
+	}
+
+	close(slow.release)
+	<-done
+}
@@ -21,9 +21,14 @@ package milestonestatus
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
@@ -34,30 +39,99 @@ import (
 const pluginName = "milestonestatus"
 
 var (
-	statusRegex      = regexp.MustCompile(`(?m)^/status\s+(.+)$`)
-	mustBeAuthorized = "You must be a member of the [%s/%s](https://github.com/orgs/%s/teams/%s/members) GitHub team to add status labels. If you believe you should be able to issue the /status command, please contact your %s and have them propose you as an additional delegate for this responsibility."
-	milestoneTeamMsg = "The milestone maintainers team is the GitHub team %q"
-	statusMap        = map[string]string{
-		"approved-for-milestone": "status/approved-for-milestone",
-		"in-progress":            "status/in-progress",
-		"in-review":              "status/in-review",
-	}
+	statusRegex       = regexp.MustCompile(`(?m)^/status[ \t]+([^\r\n]+)$`)
+	mustBeAuthorized  = "You must be a member of %s to add status labels. If you believe you should be able to issue the /status command, please contact your %s and have them propose you as an additional delegate for this responsibility."
+	teamLink          = "the [%s/%s](https://github.com/orgs/%s/teams/%s/members) GitHub team"
+	orgLink           = "the [%s](https://github.com/orgs/%s/people) GitHub org"
+	milestoneTeamsMsg = "The milestone maintainers teams are the GitHub teams %s."
+	milestoneTeamMsg  = "The milestone maintainers team is the GitHub team %q"
+	statusKeywordsMsg = "The /status command accepts the keywords: %s."
+	invalidStatus     = "The provided status is not valid for this repository. Valid statuses: [%s]"
+	statusCleared     = "Cleared the status labels: [%s]"
+	noStatusToClear   = "There are no status labels to clear."
+	statusApplied     = "Applied the status labels: [%s]"
+	statusNotApplied  = "Failed to apply the status labels: [%s]"
+	conflictingStatus = "Only one status can be applied at a time, but the comment gives %s. Please pick one."
+	milestoneRequired = "A status can only be applied once a milestone is set. Please set the milestone with `/milestone` first."
+	prTooNew          = "A status can only be applied to pull requests opened at least %s ago, but this one was opened %s ago."
+	statusTracked     = "#%d: %s (applied by @%s)"
+	commandsIgnored   = "Only the first %d `/status` command(s) of a comment are handled, the other %d were ignored."
+	prOnly            = "The /status command can only be used on pull requests in this repository."
+	clearKeyword      = "clear"
+	defaultStatuses   = []string{"approved-for-milestone", "in-progress", "in-review"}
 )
 
+// defaultStatusLabelPrefix is used when no status_label_prefix is configured.
+const defaultStatusLabelPrefix = "status/"
+
+// defaultStatusCommandsPerComment is used when no status_commands_per_comment is configured.
+const defaultStatusCommandsPerComment = 1
+
+// Outcomes of a /status command, used as the outcome label of statusCommands and
+// in statusStats.
+const (
+	outcomeApplied      = "applied"
+	outcomeCleared      = "cleared"
+	outcomeUnauthorized = "unauthorized"
+	outcomeInvalid      = "invalid"
+	outcomeFailed       = "failed"
+	outcomeConflicting  = "conflicting"
+	outcomeNoMilestone  = "no_milestone"
+	outcomeTooNew       = "too_new"
+	outcomeNotPR        = "not_pr"
+)
+
+var statusCommands = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "milestonestatus_commands_total",
+	Help: "Number of /status commands handled by the milestonestatus plugin, by outcome.",
+}, []string{"org", "repo", "outcome"})
+
+// statusStats counts the /status commands by repo and outcome, and is served by
+// the debug handler of the plugin.
+var statusStats = plugins.NewCommandStats()
+
+// countCommand counts a /status command with the given outcome in org/repo.
+func countCommand(org, repo, outcome string) {
+	statusCommands.WithLabelValues(org, repo, outcome).Inc()
+	statusStats.Inc(org, repo, outcome)
+}
+
 type githubClient interface {
 	CreateComment(owner, repo string, number int, comment string) error
 	AddLabel(owner, repo string, number int, label string) error
-	ListTeamMembersBySlug(org string, id int, role string) ([]github.TeamMember, error)
+	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
+	GetIssue(org, repo string, number int) (*github.Issue, error)
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	RemoveLabel(owner, repo string, number int, label string) error
+	ListTeams(org string) ([]github.Team, error)
 	ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error)
+	IsMember(org, user string) (bool, error)
+	TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error)
+	GetRepoLabels(org, repo string) ([]github.Label, error)
 }
 
 func init() {
 	plugins.RegisterGenericCommentHandler(pluginName, handleGenericComment, helpProvider)
+	prometheus.MustRegister(statusCommands)
+	plugins.RegisterDebugHandler(pluginName, statusStats)
 }
 
 func helpProvider(config *plugins.Configuration, enabledRepos []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
 	msgForTeam := func(team plugins.Milestone) string {
-		return fmt.Sprintf(milestoneTeamMsg, team.MaintainersTeam)
+		var msg string
+		teams := team.MaintainersTeamSlugs()
+		if len(teams) > 1 {
+			msg = fmt.Sprintf(milestoneTeamsMsg, strings.Join(teams, ", "))
+		} else {
+			if len(teams) == 1 {
+				team.MaintainersTeam = teams[0]
+			}
+			msg = fmt.Sprintf(milestoneTeamMsg, team.MaintainersTeam)
+		}
+		if len(team.StatusLabels) > 0 {
+			msg += " " + fmt.Sprintf(statusKeywordsMsg, strings.Join(statusKeywords(team.StatusLabels), ", "))
+		}
+		return msg
 	}
 
 	pluginHelp := &pluginhelp.PluginHelp{
@@ -65,9 +139,8 @@ func helpProvider(config *plugins.Configuration, enabledRepos []config.OrgRepo)
 		Config: func() map[string]string {
 			configMap := make(map[string]string)
 			for _, repo := range enabledRepos {
-				team, exists := config.RepoMilestone[repo.String()]
-				if exists {
-					configMap[repo.String()] = msgForTeam(team)
+				if plugins.HasMilestoneFor(config.RepoMilestone, repo.Org, repo.Repo) {
+					configMap[repo.String()] = msgForTeam(plugins.MilestoneFor(config.RepoMilestone, repo.Org, repo.Repo))
 				}
 			}
 			configMap[""] = msgForTeam(config.RepoMilestone[""])
@@ -75,25 +148,52 @@ func helpProvider(config *plugins.Configuration, enabledRepos []config.OrgRepo)
 		}(),
 	}
 	pluginHelp.AddCommand(pluginhelp.Command{
-		Usage:       "/status (approved-for-milestone|in-progress|in-review)",
-		Description: "Applies the 'status/' label to a PR.",
+		Usage:       "/status (approved-for-milestone|in-progress|in-review|clear)",
+		Description: "Applies the 'status/' label to a PR, or removes all the 'status/' labels with 'clear'.",
 		Featured:    false,
 		WhoCanUse:   "Members of the milestone maintainers GitHub team can use the '/status' command. This team is specified in the config by providing the GitHub team's ID.",
-		Examples:    []string{"/status approved-for-milestone", "/status in-progress", "/status in-review"},
+		Examples:    []string{"/status approved-for-milestone", "/status in-progress", "/status in-review", "/status clear"},
 	})
 	return pluginHelp, nil
 }
 
 func handleGenericComment(pc plugins.Agent, e github.GenericCommentEvent) error {
-	return handle(pc.GitHubClient, pc.Logger, &e, pc.PluginConfig.RepoMilestone)
+	milestone := plugins.MilestoneFor(pc.PluginConfig.RepoMilestone, e.Repo.Owner.Login, e.Repo.Name)
+	gc := &teamsCachingClient{
+		githubClient: pc.GitHubClient,
+		teams:        pc.TeamMembershipResolver.Client(pc.GitHubClient, milestone.TeamMembershipCacheTTLDuration),
+	}
+	return handleWithAuthorizer(gc, pc.Authorizer, pc.Logger, &e, pc.PluginConfig.RepoMilestone)
+}
+
+// teamsCachingClient serves the team membership lookups from the cache of the
+// TeamMembershipResolver shared by the milestone plugins.
+type teamsCachingClient struct {
+	githubClient
+	teams *plugins.TeamMembershipCachingClient
+}
+
+func (c *teamsCachingClient) ListTeamMembersBySlug(org, teamSlug, role string) ([]github.TeamMember, error) {
+	return c.teams.ListTeamMembersBySlug(org, teamSlug, role)
+}
+
+func (c *teamsCachingClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	return c.teams.TeamBySlugHasMember(org, teamSlug, memberLogin)
 }
 
 func handle(gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent, repoMilestone map[string]plugins.Milestone) error {
-	if e.Action != github.GenericCommentActionCreated {
+	return handleWithAuthorizer(gc, nil, log, e, repoMilestone)
+}
+
+// handleWithAuthorizer handles the event, authorizing the /status command with
+// authorizer, or with a plugins.TeamAuthorizer if it is nil.
+func handleWithAuthorizer(gc githubClient, authorizer plugins.Authorizer, log *logrus.Entry, e *github.GenericCommentEvent, repoMilestone map[string]plugins.Milestone) error {
+	edited := e.Action == github.GenericCommentActionEdited
+	if e.Action != github.GenericCommentActionCreated && !edited {
 		return nil
 	}
 
-	statusMatches := statusRegex.FindAllStringSubmatch(e.Body, -1)
+	statusMatches := statusRegex.FindAllStringSubmatch(plugins.StripQuotedText(e.Body), -1)
 	if len(statusMatches) == 0 {
 		return nil
 	}
@@ -101,45 +201,289 @@ func handle(gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent, r
 	org := e.Repo.Owner.Login
 	repo := e.Repo.Name
 
-	milestone, exists := repoMilestone[fmt.Sprintf("%s/%s", org, repo)]
-	if !exists {
-		// fallback default
-		milestone = repoMilestone[""]
+	milestone := plugins.MilestoneFor(repoMilestone, org, repo)
+	if edited && !milestone.HandlesEditedComments() {
+		return nil
+	}
+	if milestone.IsAuditOnly() {
+		gc = &auditingClient{githubClient: gc, actor: e.User.Login}
 	}
 
-	milestoneMaintainers, err := determineMaintainers(gc, milestone, org)
+	if authorizer == nil {
+		authorizer = &plugins.TeamAuthorizer{Client: gc}
+	}
+	found, err := authorizer.IsAuthorized(org, e.User.Login, milestone)
 	if err != nil {
 		return err
 	}
-	found := false
-	for _, person := range milestoneMaintainers {
-		login := strings.ToLower(e.User.Login)
-		if strings.ToLower(person.Login) == login {
-			found = true
-			break
-		}
-	}
 	if !found {
 		// not in the milestone maintainers team
-		msg := fmt.Sprintf(mustBeAuthorized, org, milestone.MaintainersTeam, org, milestone.MaintainersTeam, milestone.MaintainersFriendlyName)
+		countCommand(org, repo, outcomeUnauthorized)
+		links := teamLinks(org, milestone.MaintainersTeamSlugs())
+		if milestone.AuthorizesOrgMembers() {
+			links = fmt.Sprintf(orgLink, org, org)
+		}
+		info := plugins.UnauthorizedInfo{Org: org, Teams: milestone.MaintainersTeamSlugs(), TeamLinks: links, FriendlyName: milestone.FriendlyName(), Login: e.User.Login}
+		msg, err := plugins.UnauthorizedMessage(milestone, info, fmt.Sprintf(mustBeAuthorized, links, milestone.FriendlyName()))
+		if err != nil {
+			log.WithError(err).Warn("Using the default message to reject the user.")
+		}
 		return gc.CreateComment(org, repo, e.Number, msg)
 	}
+	if !e.IsPR && !milestone.AllowsStatusOnIssues() {
+		countCommand(org, repo, outcomeNotPR)
+		return gc.CreateComment(org, repo, e.Number, prOnly)
+	}
+
+	statuses := milestone.StatusLabels
+	if len(statuses) == 0 {
+		statuses = defaultStatusLabels(milestone.StatusLabelPrefix)
+	}
+	if milestone.ValidatesStatusLabels() {
+		statusLabelChecks.check(gc, log, org, repo, statuses)
+	}
+	// conflicts are detected among all the commands, as the limit could
+	// otherwise drop the status conflicting with the applied one
+	if newLabels, keywords := requestedLabels(statusMatches, statuses, milestone.StatusSynonyms); len(newLabels) > 1 {
+		countCommand(org, repo, outcomeConflicting)
+		return gc.CreateComment(org, repo, e.Number, fmt.Sprintf(conflictingStatus, strings.Join(keywords, ", ")))
+	}
+
+	maxCommands := milestone.StatusCommandsPerComment
+	if maxCommands == 0 {
+		maxCommands = defaultStatusCommandsPerComment
+	}
+	if ignored := len(statusMatches) - maxCommands; ignored > 0 {
+		statusMatches = statusMatches[:maxCommands]
+		// the note is informational, the handled commands are applied regardless
+		if err := gc.CreateComment(org, repo, e.Number, fmt.Sprintf(commandsIgnored, maxCommands, ignored)); err != nil {
+			log.WithError(err).Warnf("Error commenting on %s/%s#%d about the ignored /status commands.", org, repo, e.Number)
+		}
+	}
 
 	for _, statusMatch := range statusMatches {
-		sLabel, validStatus := statusMap[strings.TrimSpace(statusMatch[1])]
-		if !validStatus {
+		if strings.TrimSpace(statusMatch[1]) == clearKeyword {
+			return clearStatus(gc, log, e, statuses, edited)
+		}
+	}
+
+	newLabels, _ := requestedLabels(statusMatches, statuses, milestone.StatusSynonyms)
+	if len(newLabels) == 0 {
+		countCommand(org, repo, outcomeInvalid)
+		keywords := statusKeywords(statuses)
+		for i := range keywords {
+			keywords[i] = fmt.Sprintf("`%s`", keywords[i])
+		}
+		msg := fmt.Sprintf(invalidStatus, strings.Join(keywords, ", "))
+		return gc.CreateComment(org, repo, e.Number, msg)
+	}
+
+	if milestone.RequiresMilestoneForStatus() {
+		issue, err := gc.GetIssue(org, repo, e.Number)
+		if err != nil {
+			log.WithError(err).Errorf("Error getting %s/%s#%d.", org, repo, e.Number)
+			return err
+		}
+		if issue.Milestone.Title == "" {
+			countCommand(org, repo, outcomeNoMilestone)
+			return gc.CreateComment(org, repo, e.Number, milestoneRequired)
+		}
+	}
+	if milestone.StatusMinimumPRAgeDuration > 0 && e.IsPR {
+		pr, err := gc.GetPullRequest(org, repo, e.Number)
+		if err != nil {
+			log.WithError(err).Errorf("Error getting the pull request %s/%s#%d.", org, repo, e.Number)
+			return err
+		}
+		if age := time.Since(pr.CreatedAt); age < milestone.StatusMinimumPRAgeDuration {
+			countCommand(org, repo, outcomeTooNew)
+			return gc.CreateComment(org, repo, e.Number, fmt.Sprintf(prTooNew, formatAge(milestone.StatusMinimumPRAgeDuration), formatAge(age)))
+		}
+	}
+
+	labels, err := gc.GetIssueLabels(org, repo, e.Number)
+	if err != nil {
+		log.WithError(err).Errorf("Error getting the labels of %s/%s#%d.", org, repo, e.Number)
+		return err
+	}
+	// Remove the status labels which are being replaced by the new ones.
+	managed := managedLabels(statuses)
+	applyingLabels := sets.NewString(newLabels...)
+	existingLabels := sets.NewString()
+	for _, label := range labels {
+		existingLabels.Insert(label.Name)
+		if !managed.Has(label.Name) || applyingLabels.Has(label.Name) {
 			continue
 		}
+		if err := gc.RemoveLabel(org, repo, e.Number, label.Name); err != nil {
+			log.WithError(err).Errorf("Error removing the label %q from %s/%s#%d.", label.Name, org, repo, e.Number)
+		}
+	}
+	var applied, failed []string
+	var errs []error
+	for _, sLabel := range newLabels {
+		if existingLabels.Has(sLabel) {
+			continue
+		}
+		existingLabels.Insert(sLabel)
 		if err := gc.AddLabel(org, repo, e.Number, sLabel); err != nil {
 			log.WithError(err).Errorf("Error adding the label %q to %s/%s#%d.", sLabel, org, repo, e.Number)
+			failed = append(failed, fmt.Sprintf("`%s`", sLabel))
+			errs = append(errs, fmt.Errorf("failed to add the label %q: %w", sLabel, err))
+			continue
+		}
+		applied = append(applied, fmt.Sprintf("`%s`", sLabel))
+	}
+	if len(errs) > 0 {
+		countCommand(org, repo, outcomeFailed)
+	} else {
+		countCommand(org, repo, outcomeApplied)
+	}
+	if milestone.StatusTrackingIssue != 0 && len(applied) > 0 {
+		// the tracking issue is informational, failing to update it doesn't fail the command
+		tracked := fmt.Sprintf(statusTracked, e.Number, strings.Join(applied, ", "), e.User.Login)
+		if err := gc.CreateComment(org, repo, milestone.StatusTrackingIssue, tracked); err != nil {
+			log.WithError(err).Warnf("Error updating the status tracking issue %s/%s#%d.", org, repo, milestone.StatusTrackingIssue)
+		}
+	}
+	// Applying the statuses as requested needs no confirmation, but when some
+	// failed, report which of them the issue or PR ended up with.
+	if len(failed) == 0 {
+		return nil
+	}
+	var summary []string
+	if len(applied) > 0 {
+		summary = append(summary, fmt.Sprintf(statusApplied, strings.Join(applied, ", ")))
+	}
+	if len(failed) > 0 {
+		summary = append(summary, fmt.Sprintf(statusNotApplied, strings.Join(failed, ", ")))
+	}
+	if err := gc.CreateComment(org, repo, e.Number, strings.Join(summary, "\n")); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// requestedLabels returns the distinct status labels requested by the valid
+// status commands, along with the keywords requesting them.
+func requestedLabels(statusMatches [][]string, statuses map[string]string, synonyms map[string]string) ([]string, []string) {
+	var labels, keywords []string
+	for _, statusMatch := range statusMatches {
+		keyword := strings.TrimSpace(statusMatch[1])
+		sLabel, validStatus := statuses[resolveSynonym(synonyms, keyword)]
+		if !validStatus || sets.NewString(labels...).Has(sLabel) {
+			continue
+		}
+		labels = append(labels, sLabel)
+		keywords = append(keywords, fmt.Sprintf("`%s`", keyword))
+	}
+	return labels, keywords
+}
+
+// clearStatus removes all the status labels managed by the plugin from the issue or PR.
+func clearStatus(gc githubClient, log *logrus.Entry, e *github.GenericCommentEvent, statuses map[string]string, edited bool) error {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+
+	labels, err := gc.GetIssueLabels(org, repo, e.Number)
+	if err != nil {
+		log.WithError(err).Errorf("Error getting the labels of %s/%s#%d.", org, repo, e.Number)
+		return err
+	}
+	managed := managedLabels(statuses)
+	var removed []string
+	for _, label := range labels {
+		if !managed.Has(label.Name) {
+			continue
+		}
+		if err := gc.RemoveLabel(org, repo, e.Number, label.Name); err != nil {
+			log.WithError(err).Errorf("Error removing the label %q from %s/%s#%d.", label.Name, org, repo, e.Number)
+			return err
 		}
+		removed = append(removed, fmt.Sprintf("`%s`", label.Name))
+	}
+	if len(removed) == 0 && edited {
+		// the status was already cleared, e.g. by the command before the edit
+		return nil
+	}
+	countCommand(org, repo, outcomeCleared)
+	if len(removed) == 0 {
+		return gc.CreateComment(org, repo, e.Number, noStatusToClear)
+	}
+	return gc.CreateComment(org, repo, e.Number, fmt.Sprintf(statusCleared, strings.Join(removed, ", ")))
+}
+
+// resolveSynonym returns the keyword of the status the synonym keyword stands
+// for, or keyword itself if it isn't a synonym.
+func resolveSynonym(synonyms map[string]string, keyword string) string {
+	if canonical, ok := synonyms[keyword]; ok {
+		return canonical
+	}
+	return keyword
+}
+
+// defaultStatusLabels maps the default statuses to their labels, which are the
+// statuses with the given prefix, or with defaultStatusLabelPrefix if empty.
+func defaultStatusLabels(prefix string) map[string]string {
+	if prefix == "" {
+		prefix = defaultStatusLabelPrefix
+	}
+	statuses := make(map[string]string, len(defaultStatuses))
+	for _, status := range defaultStatuses {
+		statuses[status] = prefix + status
 	}
-	return nil
+	return statuses
 }
 
-func determineMaintainers(gc githubClient, milestone plugins.Milestone, org string) ([]github.TeamMember, error) {
-	if milestone.MaintainersTeam != "" {
-		return gc.ListTeamMembersBySlug(org, milestone.MaintainersTeam, github.RoleAll)
+// managedLabels returns the labels applied by the given statuses.
+func managedLabels(statuses map[string]string) sets.String {
+	labels := sets.NewString()
+	for _, sLabel := range statuses {
+		labels.Insert(sLabel)
+	}
+	return labels
+}
+
+// statusKeywords returns the sorted keywords of the given status labels.
+func statusKeywords(statuses map[string]string) []string {
+	keywords := make([]string, 0, len(statuses))
+	for keyword := range statuses {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	return keywords
+}
+
+// teamLinks formats links to the given maintainers teams for use in mustBeAuthorized.
+func teamLinks(org string, teams []string) string {
+	if len(teams) == 0 {
+		return "the milestone maintainers GitHub team"
+	}
+	links := make([]string, 0, len(teams))
+	for _, team := range teams {
+		links = append(links, fmt.Sprintf(teamLink, org, team, org, plugins.TeamSlug(team)))
+	}
+	return strings.Join(links, " or ")
+}
+
+// formatAge formats a duration in days, hours and minutes, e.g. "3d 4h", or in
+// seconds below a minute.
+func formatAge(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	days := d / (24 * time.Hour)
+	hours := d % (24 * time.Hour) / time.Hour
+	minutes := d % time.Hour / time.Minute
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 && days == 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
 	}
-	return gc.ListTeamMembersBySlug(org, milestone.MaintainersID, github.RoleAll)
+	return strings.Join(parts, " ")
 }
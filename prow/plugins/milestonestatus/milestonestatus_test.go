@@ -17,13 +17,18 @@ limitations under the License.
 package milestonestatus
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 
+	utilpointer "k8s.io/utils/pointer"
+
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/github/fakegithub"
 	"k8s.io/test-infra/prow/plugins"
@@ -78,6 +83,13 @@ func TestMilestoneStatus(t *testing.T) {
 			commenter:         "sig-lead",
 			shouldComment:     false,
 		},
+		{
+			name:              "Label when sig-lead user with different login casing approves",
+			body:              "/status approved-for-milestone",
+			expectedNewLabels: []string{"status/approved-for-milestone"},
+			commenter:         "SIG-Lead",
+			shouldComment:     false,
+		},
 		{
 			name:              "Label when sig-lead user marks in progress",
 			body:              "/status in-progress",
@@ -97,7 +109,7 @@ func TestMilestoneStatus(t *testing.T) {
 			body:              "/status in-valid",
 			expectedNewLabels: []string{},
 			commenter:         "sig-lead",
-			shouldComment:     false,
+			shouldComment:     true,
 		},
 		{
 			name:              "Don't label when sig-lead user marks empty status",
@@ -162,3 +174,1051 @@ func TestMilestoneStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestMultipleMaintainersTeams(t *testing.T) {
+	testcases := []struct {
+		name              string
+		commenter         string
+		milestone         plugins.Milestone
+		expectedNewLabels []string
+	}{
+		{
+			name:              "Authorize a member of the second of two configured teams",
+			commenter:         "sig-lead",
+			milestone:         plugins.Milestone{MaintainersTeams: []string{"admins", "leads"}},
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name:              "Authorize a member of an additional team alongside the single team",
+			commenter:         "sig-lead",
+			milestone:         plugins.Milestone{MaintainersTeam: "admins", MaintainersTeams: []string{"leads"}},
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name:      "Don't authorize a user who is in none of the configured teams",
+			commenter: "sig-follow",
+			milestone: plugins.Milestone{MaintainersTeams: []string{"admins", "leads"}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/status in-progress",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": tc.milestone}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected issue to end with labels %q, but ended with %q.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+		})
+	}
+}
+
+func TestDefaultMaintainersTeam(t *testing.T) {
+	fakeClient := fakegithub.NewFakeClient()
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/status in-progress",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{
+		"":         {MaintainersTeam: "leads"},
+		"org/repo": {MaintainersFriendlyName: "Release Team"},
+	}
+
+	if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		t.Fatalf("Unexpected error from handle: %v.", err)
+	}
+	expectLabels := formatLabels("status/in-progress")
+	if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+		t.Errorf("Expected labels %q to be added, but %q were added.", expectLabels, fakeClient.IssueLabelsAdded)
+	}
+}
+
+func TestAllowedBots(t *testing.T) {
+	testcases := []struct {
+		name           string
+		commenter      string
+		expectedLabels []string
+	}{
+		{
+			name:           "Authorize an allowed bot",
+			commenter:      "release-bot",
+			expectedLabels: formatLabels("status/in-progress"),
+		},
+		{
+			name:      "Reject a bot which isn't allowed",
+			commenter: "other-bot",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/status in-progress",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads", AllowedBots: []string{"Release-Bot"}}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if !reflect.DeepEqual(tc.expectedLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", tc.expectedLabels, fakeClient.IssueLabelsAdded)
+			}
+		})
+	}
+}
+
+func TestStatusAllowOnIssues(t *testing.T) {
+	disallowed := false
+	testcases := []struct {
+		name            string
+		isPR            bool
+		allowOnIssues   *bool
+		commenter       string
+		expectedLabels  []string
+		expectedComment string
+	}{
+		{
+			name:           "Apply the status of an issue by default",
+			expectedLabels: formatLabels("status/in-progress"),
+		},
+		{
+			name:           "Apply the status of a PR by default",
+			isPR:           true,
+			expectedLabels: formatLabels("status/in-progress"),
+		},
+		{
+			name:            "Reject the status of an issue if only PRs have a status",
+			allowOnIssues:   &disallowed,
+			expectedComment: prOnly,
+		},
+		{
+			name:           "Apply the status of a PR if only PRs have a status",
+			isPR:           true,
+			allowOnIssues:  &disallowed,
+			expectedLabels: formatLabels("status/in-progress"),
+		},
+		{
+			name:            "Reject an unauthorized user before telling that only PRs have a status",
+			allowOnIssues:   &disallowed,
+			commenter:       "sig-follow",
+			expectedComment: fmt.Sprintf(mustBeAuthorized, teamLinks("org", []string{"leads"}), plugins.Milestone{MaintainersTeam: "leads"}.FriendlyName()),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			commenter := tc.commenter
+			if commenter == "" {
+				commenter = "sig-lead"
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/status in-progress",
+				Number: 1,
+				IsPR:   tc.isPR,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads", StatusAllowOnIssues: tc.allowOnIssues}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if !reflect.DeepEqual(tc.expectedLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", tc.expectedLabels, fakeClient.IssueLabelsAdded)
+			}
+			if tc.expectedComment == "" {
+				if len(fakeClient.IssueComments[1]) != 0 {
+					t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+				}
+				return
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || fakeClient.IssueComments[1][0].Body != tc.expectedComment {
+				t.Errorf("Expected the comment %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+// trackingIssueFailingClient fails to comment on the status tracking issue.
+type trackingIssueFailingClient struct {
+	*fakegithub.FakeClient
+}
+
+func (c *trackingIssueFailingClient) CreateComment(owner, repo string, number int, comment string) error {
+	if number == 100 {
+		return errors.New("injected error")
+	}
+	return c.FakeClient.CreateComment(owner, repo, number, comment)
+}
+
+func TestStatusTrackingIssue(t *testing.T) {
+	testcases := []struct {
+		name             string
+		body             string
+		trackingIssue    int
+		failTracking     bool
+		expectedLabels   []string
+		expectedTracking []string
+	}{
+		{
+			name:             "Track the applied status",
+			body:             "/status in-progress",
+			trackingIssue:    100,
+			expectedLabels:   formatLabels("status/in-progress"),
+			expectedTracking: []string{"#1: `status/in-progress` (applied by @sig-lead)"},
+		},
+		{
+			name:           "Don't track statuses without a tracking issue",
+			body:           "/status in-progress",
+			expectedLabels: formatLabels("status/in-progress"),
+		},
+		{
+			name:          "Don't track invalid statuses",
+			body:          "/status unknown",
+			trackingIssue: 100,
+		},
+		{
+			name:           "Apply the status even if the tracking issue can't be updated",
+			body:           "/status in-progress",
+			trackingIssue:  100,
+			failTracking:   true,
+			expectedLabels: formatLabels("status/in-progress"),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			var gc githubClient = fakeClient
+			if tc.failTracking {
+				gc = &trackingIssueFailingClient{FakeClient: fakeClient}
+			}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads", StatusTrackingIssue: tc.trackingIssue}}
+
+			if err := handle(gc, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if !reflect.DeepEqual(tc.expectedLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", tc.expectedLabels, fakeClient.IssueLabelsAdded)
+			}
+			var tracking []string
+			for _, comment := range fakeClient.IssueComments[100] {
+				tracking = append(tracking, comment.Body)
+			}
+			if !reflect.DeepEqual(tc.expectedTracking, tracking) {
+				t.Errorf("Expected the tracking comments %q, got %q.", tc.expectedTracking, tracking)
+			}
+		})
+	}
+}
+
+func TestConfiguredStatusLabels(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		statusLabels      map[string]string
+		statusLabelPrefix string
+		expectedNewLabels []string
+	}{
+		{
+			name:              "Apply the label of a configured keyword",
+			body:              "/status blocked",
+			statusLabels:      map[string]string{"blocked": "status/blocked", "needs-design": "status/needs-design"},
+			expectedNewLabels: []string{"status/blocked"},
+		},
+		{
+			name:         "Ignore an unknown keyword",
+			body:         "/status unknown",
+			statusLabels: map[string]string{"blocked": "status/blocked"},
+		},
+		{
+			name:         "Ignore a default keyword which isn't configured",
+			body:         "/status in-progress",
+			statusLabels: map[string]string{"blocked": "status/blocked"},
+		},
+		{
+			name:              "Fall back to the default keywords when none are configured",
+			body:              "/status in-progress",
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name:              "Prefix the labels of the default keywords",
+			body:              "/status in-review",
+			statusLabelPrefix: "sig/status-",
+			expectedNewLabels: []string{"sig/status-in-review"},
+		},
+		{
+			name:              "Don't prefix the labels of configured keywords",
+			body:              "/status blocked",
+			statusLabels:      map[string]string{"blocked": "status/blocked"},
+			statusLabelPrefix: "sig/status-",
+			expectedNewLabels: []string{"status/blocked"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", StatusLabels: tc.statusLabels, StatusLabelPrefix: tc.statusLabelPrefix}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected issue to end with labels %q, but ended with %q.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+		})
+	}
+}
+
+func TestStatusSynonyms(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		statusLabels      map[string]string
+		expectedNewLabels []string
+	}{
+		{
+			name:              "Apply the label of the status a synonym stands for",
+			body:              "/status wip",
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name:              "Apply the label of another synonym",
+			body:              "/status review",
+			expectedNewLabels: []string{"status/in-review"},
+		},
+		{
+			name:              "Keep accepting the canonical keyword",
+			body:              "/status in-progress",
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name:         "Ignore a synonym of a status which isn't configured",
+			body:         "/status wip",
+			statusLabels: map[string]string{"blocked": "status/blocked"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			synonyms := map[string]string{"wip": "in-progress", "review": "in-review"}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", StatusLabels: tc.statusLabels, StatusSynonyms: synonyms}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected issue to end with labels %q, but ended with %q.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+		})
+	}
+}
+
+func TestQuotedStatusCommands(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		expectedNewLabels []string
+	}{
+		{
+			name:              "Command between other lines",
+			body:              "Picking this up.\n/status in-progress\nThanks!",
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name: "Quoted command is ignored",
+			body: "> /status in-progress\n\nAre you sure?",
+		},
+		{
+			name: "Command in a code fence is ignored",
+			body: "Use:\n```\n/status in-progress\n```",
+		},
+		{
+			name:              "Command after a quoted reply is handled",
+			body:              "> /status in-progress\n/status in-review",
+			expectedNewLabels: []string{"status/in-review"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected issue to end with labels %q, but ended with %q.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+			if len(fakeClient.IssueComments[1]) != 0 {
+				t.Errorf("Expected no comments, got %v.", fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestInvalidStatus(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		expectedNewLabels []string
+		expectedComment   string
+	}{
+		{
+			name:            "Comment with the valid statuses when all statuses are invalid",
+			body:            "/status in-valid\n/status also-invalid",
+			expectedComment: fmt.Sprintf(invalidStatus, "`approved-for-milestone`, `in-progress`, `in-review`"),
+		},
+		{
+			name:              "Don't comment when at least one status is valid",
+			body:              "/status in-valid\n/status in-review",
+			expectedNewLabels: []string{"status/in-review"},
+		},
+		{
+			name:              "Don't comment when the same valid status is repeated",
+			body:              "/status in-review\n/status in-review",
+			expectedNewLabels: []string{"status/in-review"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", StatusCommandsPerComment: 2}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected issue to end with labels %q, but ended with %q.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+			var comments []string
+			for _, c := range fakeClient.IssueComments[1] {
+				comments = append(comments, c.Body)
+			}
+			var expectedComments []string
+			if tc.expectedComment != "" {
+				expectedComments = []string{tc.expectedComment}
+			}
+			if !reflect.DeepEqual(expectedComments, comments) {
+				t.Errorf("Expected comments %q, got %q.", expectedComments, comments)
+			}
+		})
+	}
+}
+
+func TestStatusCommandsPerComment(t *testing.T) {
+	testcases := []struct {
+		name               string
+		body               string
+		commandsPerComment int
+		expectedNewLabels  []string
+		expectedComments   []string
+	}{
+		{
+			name:              "Handle only the first command by default",
+			body:              "/status in-progress\n/status in-progress\n/status in-progress",
+			expectedNewLabels: []string{"status/in-progress"},
+			expectedComments:  []string{fmt.Sprintf(commandsIgnored, 1, 2)},
+		},
+		{
+			name:               "Handle the configured number of commands",
+			body:               "/status in-review\n/status in-review\n/status in-review",
+			commandsPerComment: 2,
+			expectedNewLabels:  []string{"status/in-review"},
+			expectedComments:   []string{fmt.Sprintf(commandsIgnored, 2, 1)},
+		},
+		{
+			name:              "Don't comment when no command is ignored",
+			body:              "/status in-review",
+			expectedNewLabels: []string{"status/in-review"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", StatusCommandsPerComment: tc.commandsPerComment}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+			var comments []string
+			for _, c := range fakeClient.IssueComments[1] {
+				comments = append(comments, c.Body)
+			}
+			if !reflect.DeepEqual(tc.expectedComments, comments) {
+				t.Errorf("Expected comments %q, got %q.", tc.expectedComments, comments)
+			}
+		})
+	}
+}
+
+func TestReplaceStatusLabels(t *testing.T) {
+	testcases := []struct {
+		name                  string
+		body                  string
+		existingLabels        []string
+		expectedNewLabels     []string
+		expectedRemovedLabels []string
+	}{
+		{
+			name:                  "Remove the previous status when applying a new one",
+			body:                  "/status in-review",
+			existingLabels:        []string{"status/in-progress", "kind/bug"},
+			expectedNewLabels:     []string{"status/in-review"},
+			expectedRemovedLabels: []string{"status/in-progress"},
+		},
+		{
+			name:              "Leave unrelated status labels intact",
+			body:              "/status in-review",
+			existingLabels:    []string{"status/unmanaged", "kind/bug"},
+			expectedNewLabels: []string{"status/in-review"},
+		},
+		{
+			name:           "Don't re-apply the current status",
+			body:           "/status in-review",
+			existingLabels: []string{"status/in-review"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.IssueLabelsExisting = formatLabels(tc.existingLabels...)
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+			expectRemoved := formatLabels(tc.expectedRemovedLabels...)
+			if !reflect.DeepEqual(expectRemoved, fakeClient.IssueLabelsRemoved) {
+				t.Errorf("Expected labels %q to be removed, but %q were removed.", expectRemoved, fakeClient.IssueLabelsRemoved)
+			}
+		})
+	}
+}
+
+func TestConflictingStatuses(t *testing.T) {
+	testcases := []struct {
+		name               string
+		body               string
+		commandsPerComment int
+		repoLabels         []string
+		expectedNewLabels  []string
+		expectedComments   []string
+		expectErr          bool
+	}{
+		{
+			name:               "Apply a single status without comment",
+			body:               "/status in-progress",
+			commandsPerComment: 2,
+			expectedNewLabels:  []string{"status/in-progress"},
+		},
+		{
+			name:               "Reject distinct statuses, applying none",
+			body:               "/status in-progress\n/status in-review",
+			commandsPerComment: 2,
+			expectedComments:   []string{fmt.Sprintf(conflictingStatus, "`in-progress`, `in-review`")},
+		},
+		{
+			name:             "Reject distinct statuses beyond the default limit, applying none",
+			body:             "/status in-progress\n/status in-review",
+			expectedComments: []string{fmt.Sprintf(conflictingStatus, "`in-progress`, `in-review`")},
+		},
+		{
+			name:               "Report a status which failed to apply",
+			body:               "/status in-progress",
+			commandsPerComment: 2,
+			repoLabels:         []string{"kind/bug"},
+			expectedComments:   []string{fmt.Sprintf(statusNotApplied, "`status/in-progress`")},
+			expectErr:          true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.RepoLabelsExisting = tc.repoLabels
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", StatusCommandsPerComment: tc.commandsPerComment}}
+
+			err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone)
+			if tc.expectErr && err == nil {
+				t.Error("Expected an error from handle, got none.")
+			} else if !tc.expectErr && err != nil {
+				t.Errorf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+			var comments []string
+			for _, c := range fakeClient.IssueComments[1] {
+				comments = append(comments, c.Body)
+			}
+			if !reflect.DeepEqual(tc.expectedComments, comments) {
+				t.Errorf("Expected comments %q, got %q.", tc.expectedComments, comments)
+			}
+		})
+	}
+}
+
+func TestRequireMilestoneForStatus(t *testing.T) {
+	testcases := []struct {
+		name              string
+		milestone         github.Milestone
+		expectedNewLabels []string
+		expectedComments  []string
+	}{
+		{
+			name:              "Apply the status to an issue with a milestone",
+			milestone:         github.Milestone{Title: "v1.0", Number: 1},
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name:             "Refuse to apply the status to an issue without a milestone",
+			expectedComments: []string{milestoneRequired},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.Issues[1] = &github.Issue{Number: 1, Milestone: tc.milestone}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/status in-progress",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", RequireMilestoneForStatus: utilpointer.BoolPtr(true)}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+			var comments []string
+			for _, c := range fakeClient.IssueComments[1] {
+				comments = append(comments, c.Body)
+			}
+			if !reflect.DeepEqual(tc.expectedComments, comments) {
+				t.Errorf("Expected comments %q, got %q.", tc.expectedComments, comments)
+			}
+		})
+	}
+}
+
+func TestStatusMinimumPRAge(t *testing.T) {
+	testcases := []struct {
+		name              string
+		age               time.Duration
+		isPR              bool
+		expectedNewLabels []string
+		expectedComments  []string
+	}{
+		{
+			name:              "Apply the status to a PR old enough",
+			age:               4 * 24 * time.Hour,
+			isPR:              true,
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name:             "Refuse to apply the status to a PR too new",
+			age:              26 * time.Hour,
+			isPR:             true,
+			expectedComments: []string{fmt.Sprintf(prTooNew, "3d", "1d 2h")},
+		},
+		{
+			name:              "Apply the status to an issue regardless of its age",
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.PullRequests = map[int]*github.PullRequest{1: {Number: 1, CreatedAt: time.Now().Add(-tc.age)}}
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/status in-progress",
+				Number: 1,
+				IsPR:   tc.isPR,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", StatusMinimumPRAgeDuration: 72 * time.Hour}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+			var comments []string
+			for _, c := range fakeClient.IssueComments[1] {
+				comments = append(comments, c.Body)
+			}
+			if !reflect.DeepEqual(tc.expectedComments, comments) {
+				t.Errorf("Expected comments %q, got %q.", tc.expectedComments, comments)
+			}
+		})
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	testcases := []struct {
+		age      time.Duration
+		expected string
+	}{
+		{age: 30 * time.Second, expected: "30s"},
+		{age: 90 * time.Minute, expected: "1h 30m"},
+		{age: 72 * time.Hour, expected: "3d"},
+		{age: 26*time.Hour + 5*time.Minute, expected: "1d 2h"},
+	}
+	for _, tc := range testcases {
+		if actual := formatAge(tc.age); actual != tc.expected {
+			t.Errorf("Expected %v to be formatted as %q, got %q.", tc.age, tc.expected, actual)
+		}
+	}
+}
+
+func TestEditedComments(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		handleEdited      bool
+		existingLabels    []string
+		expectedNewLabels []string
+		expectedComments  []string
+	}{
+		{
+			name: "Ignore an edited comment by default",
+			body: "/status in-progress",
+		},
+		{
+			name:              "Apply the status of a corrected command",
+			body:              "/status in-progress",
+			handleEdited:      true,
+			expectedNewLabels: []string{"status/in-progress"},
+		},
+		{
+			name:           "Don't add the status again when it is already applied",
+			body:           "/status in-progress",
+			handleEdited:   true,
+			existingLabels: []string{"status/in-progress"},
+		},
+		{
+			name:             "Clear the status of a corrected command",
+			body:             "/status clear",
+			handleEdited:     true,
+			existingLabels:   []string{"status/in-progress"},
+			expectedComments: []string{fmt.Sprintf(statusCleared, "`status/in-progress`")},
+		},
+		{
+			name:         "Don't comment when the status is already cleared",
+			body:         "/status clear",
+			handleEdited: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.IssueLabelsExisting = formatLabels(tc.existingLabels...)
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionEdited,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads", HandleEditedComments: utilpointer.BoolPtr(tc.handleEdited)}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			expectLabels := formatLabels(tc.expectedNewLabels...)
+			if !reflect.DeepEqual(expectLabels, fakeClient.IssueLabelsAdded) {
+				t.Errorf("Expected labels %q to be added, but %q were added.", expectLabels, fakeClient.IssueLabelsAdded)
+			}
+			var comments []string
+			for _, c := range fakeClient.IssueComments[1] {
+				comments = append(comments, c.Body)
+			}
+			if !reflect.DeepEqual(tc.expectedComments, comments) {
+				t.Errorf("Expected comments %q, got %q.", tc.expectedComments, comments)
+			}
+		})
+	}
+}
+
+func TestClearStatus(t *testing.T) {
+	testcases := []struct {
+		name                  string
+		existingLabels        []string
+		expectedRemovedLabels []string
+		expectedComment       string
+	}{
+		{
+			name:                  "Clear a single status label",
+			existingLabels:        []string{"status/in-progress", "kind/bug"},
+			expectedRemovedLabels: []string{"status/in-progress"},
+			expectedComment:       fmt.Sprintf(statusCleared, "`status/in-progress`"),
+		},
+		{
+			name:                  "Clear multiple status labels",
+			existingLabels:        []string{"status/approved-for-milestone", "status/in-review", "status/unmanaged"},
+			expectedRemovedLabels: []string{"status/approved-for-milestone", "status/in-review"},
+			expectedComment:       fmt.Sprintf(statusCleared, "`status/approved-for-milestone`, `status/in-review`"),
+		},
+		{
+			name:            "Clear when there are no status labels",
+			existingLabels:  []string{"kind/bug"},
+			expectedComment: noStatusToClear,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			fakeClient.IssueLabelsExisting = formatLabels(tc.existingLabels...)
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   "/status clear",
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				User:   github.User{Login: "sig-lead"},
+			}
+			repoMilestone := map[string]plugins.Milestone{"org/repo": {MaintainersTeam: "leads"}}
+
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			if len(fakeClient.IssueLabelsAdded) != 0 {
+				t.Errorf("Expected no labels to be added, but %q were added.", fakeClient.IssueLabelsAdded)
+			}
+			expectRemoved := formatLabels(tc.expectedRemovedLabels...)
+			if !reflect.DeepEqual(expectRemoved, fakeClient.IssueLabelsRemoved) {
+				t.Errorf("Expected labels %q to be removed, but %q were removed.", expectRemoved, fakeClient.IssueLabelsRemoved)
+			}
+			if len(fakeClient.IssueComments[1]) != 1 || fakeClient.IssueComments[1][0].Body != tc.expectedComment {
+				t.Errorf("Expected the comment %q, got %v.", tc.expectedComment, fakeClient.IssueComments[1])
+			}
+		})
+	}
+}
+
+func TestStatusCommandsMetric(t *testing.T) {
+	testcases := []struct {
+		name            string
+		body            string
+		commenter       string
+		expectedOutcome string
+	}{
+		{
+			name:            "Count an applied status",
+			body:            "/status in-review",
+			commenter:       "sig-lead",
+			expectedOutcome: outcomeApplied,
+		},
+		{
+			name:            "Count a cleared status",
+			body:            "/status clear",
+			commenter:       "sig-lead",
+			expectedOutcome: outcomeCleared,
+		},
+		{
+			name:            "Count an unauthorized command",
+			body:            "/status in-review",
+			commenter:       "sig-follow",
+			expectedOutcome: outcomeUnauthorized,
+		},
+		{
+			name:            "Count an invalid status",
+			body:            "/status in-valid",
+			commenter:       "sig-lead",
+			expectedOutcome: outcomeInvalid,
+		},
+		{
+			name:            "Count conflicting statuses",
+			body:            "/status in-progress\n/status in-review",
+			commenter:       "sig-lead",
+			expectedOutcome: outcomeConflicting,
+		},
+	}
+
+	outcomes := []string{outcomeApplied, outcomeCleared, outcomeUnauthorized, outcomeInvalid, outcomeFailed, outcomeConflicting, outcomeNoMilestone}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := fakegithub.NewFakeClient()
+			e := &github.GenericCommentEvent{
+				Action: github.GenericCommentActionCreated,
+				Body:   tc.body,
+				Number: 1,
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "metrics-repo"},
+				User:   github.User{Login: tc.commenter},
+			}
+			repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads", StatusCommandsPerComment: 2}}
+
+			before := map[string]float64{}
+			beforeStats := map[string]int{}
+			for _, outcome := range outcomes {
+				before[outcome] = testutil.ToFloat64(statusCommands.WithLabelValues("org", "metrics-repo", outcome))
+				beforeStats[outcome] = statusStats.Count("org", "metrics-repo", outcome)
+			}
+			if err := handle(fakeClient, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+				t.Fatalf("Unexpected error from handle: %v.", err)
+			}
+			for _, outcome := range outcomes {
+				expected, expectedStats := before[outcome], beforeStats[outcome]
+				if outcome == tc.expectedOutcome {
+					expected++
+					expectedStats++
+				}
+				if actual := testutil.ToFloat64(statusCommands.WithLabelValues("org", "metrics-repo", outcome)); actual != expected {
+					t.Errorf("Expected %v commands with outcome %q, got %v.", expected, outcome, actual)
+				}
+				if actual := statusStats.Count("org", "metrics-repo", outcome); actual != expectedStats {
+					t.Errorf("Expected %d commands with outcome %q in the stats, got %d.", expectedStats, outcome, actual)
+				}
+			}
+		})
+	}
+}
+
+// membershipCountingClient counts the calls checking the membership of a team.
+type membershipCountingClient struct {
+	*fakegithub.FakeClient
+	calls int
+}
+
+func (c *membershipCountingClient) TeamBySlugHasMember(org string, teamSlug string, memberLogin string) (bool, error) {
+	c.calls++
+	return c.FakeClient.TeamBySlugHasMember(org, teamSlug, memberLogin)
+}
+
+func TestSharedTeamMembershipResolver(t *testing.T) {
+	resolver := plugins.NewTeamMembershipResolver()
+	// the milestone plugin checks the membership with its own client first
+	milestone := &membershipCountingClient{FakeClient: fakegithub.NewFakeClient()}
+	if _, err := plugins.IsMilestoneMaintainer(resolver.Client(milestone, 0), plugins.Milestone{MaintainersTeam: "leads"}, "org", "sig-lead"); err != nil {
+		t.Fatalf("Unexpected error checking the membership: %v.", err)
+	}
+
+	fakeClient := &membershipCountingClient{FakeClient: fakegithub.NewFakeClient()}
+	gc := &teamsCachingClient{githubClient: fakeClient, teams: resolver.Client(fakeClient, 0)}
+	e := &github.GenericCommentEvent{
+		Action: github.GenericCommentActionCreated,
+		Body:   "/status in-progress",
+		Number: 1,
+		Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		User:   github.User{Login: "sig-lead"},
+	}
+	repoMilestone := map[string]plugins.Milestone{"": {MaintainersTeam: "leads"}}
+	if err := handle(gc, logrus.WithField("plugin", pluginName), e, repoMilestone); err != nil {
+		t.Fatalf("Unexpected error from handle: %v.", err)
+	}
+	if expected := formatLabels("status/in-progress"); !reflect.DeepEqual(expected, fakeClient.IssueLabelsAdded) {
+		t.Errorf("Expected the labels %q to be added, got %q.", expected, fakeClient.IssueLabelsAdded)
+	}
+	if milestone.calls != 1 || fakeClient.calls != 0 {
+		t.Errorf("Expected the membership to be checked once and then served from the shared cache, got %d and %d calls.", milestone.calls, fakeClient.calls)
+	}
+}
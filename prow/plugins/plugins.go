@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -62,6 +63,7 @@ var (
 	reviewEventHandlers        = map[string]ReviewEventHandler{}
 	reviewCommentEventHandlers = map[string]ReviewCommentEventHandler{}
 	statusEventHandlers        = map[string]StatusEventHandler{}
+	debugHandlers              = map[string]http.Handler{}
 	// CommentMap is used by many plugins for printing help messages defined in
 	// config.go.
 	CommentMap, _ = genyaml.NewCommentMap(nil)
@@ -95,6 +97,22 @@ func HelpProviders() map[string]HelpProvider {
 	return pluginHelp
 }
 
+// RegisterDebugHandler registers a handler serving debugging information about
+// the plugin, e.g. in-memory statistics. It is served by hook at
+// /plugin-debug/<name> on the pprof port, which isn't exposed.
+func RegisterDebugHandler(name string, h http.Handler) {
+	debugHandlers[name] = h
+}
+
+// DebugHandlers returns the debug handlers of the plugins, by plugin name.
+func DebugHandlers() map[string]http.Handler {
+	hs := map[string]http.Handler{}
+	for k, v := range debugHandlers {
+		hs[k] = v
+	}
+	return hs
+}
+
 // IssueHandler defines the function contract for a github.IssueEvent handler.
 type IssueHandler func(Agent, github.IssueEvent) error
 
@@ -185,6 +203,15 @@ type Agent struct {
 
 	OwnersClient repoowners.Interface
 
+	// TeamMembershipResolver resolves the members of the milestone
+	// maintainers teams for the milestone plugins. It may be nil, in which
+	// case a default resolver is used.
+	TeamMembershipResolver *TeamMembershipResolver
+	// Authorizer authorizes the commands of the milestone plugins. It may be
+	// nil, in which case the milestone maintainers are authorized with a
+	// TeamAuthorizer.
+	Authorizer Authorizer
+
 	// Metrics exposes metrics that can be updated by plugins
 	Metrics *Metrics
 
@@ -220,6 +247,8 @@ func NewAgent(configAgent *config.Agent, pluginConfigAgent *ConfigAgent, clientA
 		OwnersClient:              clientAgent.OwnersClient.WithFields(logger.Data).WithGitHubClient(gitHubClient).ForPlugin(plugin),
 		BugzillaClient:            clientAgent.BugzillaClient.WithFields(logger.Data).ForPlugin(plugin),
 		JiraClient:                jiraClient,
+		TeamMembershipResolver:    clientAgent.TeamMembershipResolver,
+		Authorizer:                clientAgent.Authorizer,
 		Metrics:                   metrics,
 		Config:                    prowConfig,
 		PluginConfig:              pluginConfig,
@@ -265,6 +294,8 @@ type ClientAgent struct {
 	OwnersClient              repoowners.Interface
 	BugzillaClient            bugzilla.Client
 	JiraClient                jira.Client
+	TeamMembershipResolver    *TeamMembershipResolver
+	Authorizer                Authorizer
 }
 
 // ConfigAgent contains the agent mutex and the Agent configuration.